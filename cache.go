@@ -1,8 +1,15 @@
 package jet
 
-import "sync"
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
 
 // Cache is the interface Jet uses to store and retrieve parsed templates.
+//
+// A Cache may also implement CacheDeleter, CachePurger and CacheSizer; Set.Invalidate and Set.PurgeCache use
+// them when available, and do nothing to caches that don't implement them.
 type Cache interface {
 
 	// Get fetches a template from the cache. If Get returns nil, the same path with a different extension will be tried.
@@ -13,13 +20,36 @@ type Cache interface {
 	Put(templatePath string, t *Template)
 }
 
+// CacheDeleter is implemented by caches that can remove a single template.
+type CacheDeleter interface {
+	Delete(templatePath string)
+}
+
+// CachePurger is implemented by caches that can remove all their templates at once.
+type CachePurger interface {
+	Purge()
+}
+
+// CacheSizer is implemented by caches that can report the number of templates they hold.
+type CacheSizer interface {
+	Len() int
+}
+
+// EvictingCache is a Cache supporting all the optional cache operations.
+type EvictingCache interface {
+	Cache
+	CacheDeleter
+	CachePurger
+	CacheSizer
+}
+
 // cache is the cache used by default in a new Set.
 type cache struct {
 	m sync.Map
 }
 
-// compile-time check that cache implements Cache
-var _ Cache = (*cache)(nil)
+// compile-time check that cache implements EvictingCache
+var _ EvictingCache = (*cache)(nil)
 
 func (c *cache) Get(templatePath string) *Template {
 	_t, ok := c.m.Load(templatePath)
@@ -32,3 +62,210 @@ func (c *cache) Get(templatePath string) *Template {
 func (c *cache) Put(templatePath string, t *Template) {
 	c.m.Store(templatePath, t)
 }
+
+func (c *cache) Delete(templatePath string) {
+	c.m.Delete(templatePath)
+}
+
+func (c *cache) Purge() {
+	c.m.Clear()
+}
+
+func (c *cache) Len() int {
+	n := 0
+	c.m.Range(func(_, _ any) bool {
+		n++
+		return true
+	})
+	return n
+}
+
+// LRUCache is a Cache holding a bounded number of templates. When full, it drops the least recently used template.
+// It is safe for concurrent use.
+type LRUCache struct {
+	entries      *lru[string, *Template]
+	hits, misses atomic.Uint64
+}
+
+// compile-time check that LRUCache implements EvictingCache
+var _ EvictingCache = (*LRUCache)(nil)
+
+// NewLRUCache returns an LRUCache holding at most size templates. NewLRUCache panics if size is not positive.
+func NewLRUCache(size int) *LRUCache {
+	if size <= 0 {
+		panic("jet: NewLRUCache() must be called with a positive size")
+	}
+	return &LRUCache{entries: newLRU[string, *Template](size)}
+}
+
+func (c *LRUCache) Get(templatePath string) *Template {
+	t, ok := c.entries.get(templatePath)
+	if !ok {
+		c.misses.Add(1)
+		return nil
+	}
+	c.hits.Add(1)
+	return t
+}
+
+func (c *LRUCache) Put(templatePath string, t *Template) {
+	c.entries.put(templatePath, t)
+}
+
+func (c *LRUCache) Delete(templatePath string) {
+	c.entries.delete(templatePath)
+}
+
+func (c *LRUCache) Purge() {
+	c.entries.purge()
+}
+
+func (c *LRUCache) Len() int {
+	return c.entries.len()
+}
+
+// Stats returns the usage statistics of the cache. Since the Set looks a path up with each configured extension,
+// a template found under its second extension counts one miss and one hit.
+func (c *LRUCache) Stats() CacheStats {
+	return CacheStats{
+		Hits:     c.hits.Load(),
+		Misses:   c.misses.Load(),
+		Len:      c.entries.len(),
+		Capacity: c.entries.capacity,
+	}
+}
+
+// TTLCache is a Cache dropping templates a fixed duration after they were put in the cache, so that they are
+// parsed again from the Set's Loader. It is safe for concurrent use.
+type TTLCache struct {
+	ttl     time.Duration
+	lock    sync.Mutex
+	entries map[string]ttlEntry
+}
+
+type ttlEntry struct {
+	t       *Template
+	expires time.Time
+}
+
+// compile-time check that TTLCache implements EvictingCache
+var _ EvictingCache = (*TTLCache)(nil)
+
+// NewTTLCache returns a TTLCache keeping templates for ttl. NewTTLCache panics if ttl is not positive.
+func NewTTLCache(ttl time.Duration) *TTLCache {
+	if ttl <= 0 {
+		panic("jet: NewTTLCache() must be called with a positive ttl")
+	}
+	return &TTLCache{ttl: ttl, entries: map[string]ttlEntry{}}
+}
+
+func (c *TTLCache) Get(templatePath string) *Template {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	entry, ok := c.entries[templatePath]
+	if !ok {
+		return nil
+	}
+	if time.Now().After(entry.expires) {
+		delete(c.entries, templatePath)
+		return nil
+	}
+	return entry.t
+}
+
+func (c *TTLCache) Put(templatePath string, t *Template) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.entries[templatePath] = ttlEntry{t: t, expires: time.Now().Add(c.ttl)}
+}
+
+func (c *TTLCache) Delete(templatePath string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	delete(c.entries, templatePath)
+}
+
+func (c *TTLCache) Purge() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	clear(c.entries)
+}
+
+// Len returns the number of templates that have not expired yet; expired templates are dropped.
+func (c *TTLCache) Len() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	now := time.Now()
+	for templatePath, entry := range c.entries {
+		if now.After(entry.expires) {
+			delete(c.entries, templatePath)
+		}
+	}
+	return len(c.entries)
+}
+
+// TieredCache combines a small, fast front cache with a larger back cache: templates missing from the front are
+// looked up in the back and promoted to the front. Put stores templates in both caches; Delete and Purge are
+// forwarded to each cache supporting them, so both must implement CacheDeleter for Set.Invalidate to work.
+type TieredCache struct {
+	front, back Cache
+}
+
+// compile-time check that TieredCache implements EvictingCache
+var _ EvictingCache = (*TieredCache)(nil)
+
+// NewTieredCache returns a TieredCache in front of back. NewTieredCache panics if either cache is nil.
+func NewTieredCache(front, back Cache) *TieredCache {
+	if front == nil || back == nil {
+		panic("jet: NewTieredCache() must not be called with a nil cache")
+	}
+	return &TieredCache{front: front, back: back}
+}
+
+func (c *TieredCache) Get(templatePath string) *Template {
+	if t := c.front.Get(templatePath); t != nil {
+		return t
+	}
+	t := c.back.Get(templatePath)
+	if t != nil {
+		c.front.Put(templatePath, t)
+	}
+	return t
+}
+
+func (c *TieredCache) Put(templatePath string, t *Template) {
+	c.front.Put(templatePath, t)
+	c.back.Put(templatePath, t)
+}
+
+func (c *TieredCache) Delete(templatePath string) {
+	deleteFromCache(c.front, templatePath)
+	deleteFromCache(c.back, templatePath)
+}
+
+func (c *TieredCache) Purge() {
+	if p, ok := c.front.(CachePurger); ok {
+		p.Purge()
+	}
+	if p, ok := c.back.(CachePurger); ok {
+		p.Purge()
+	}
+}
+
+// Len returns the number of templates in the back cache, or in the front cache if only that one can report it.
+func (c *TieredCache) Len() int {
+	if s, ok := c.back.(CacheSizer); ok {
+		return s.Len()
+	}
+	if s, ok := c.front.(CacheSizer); ok {
+		return s.Len()
+	}
+	return 0
+}
+
+// deleteFromCache removes templatePath from c, if c supports it.
+func deleteFromCache(c Cache, templatePath string) {
+	if d, ok := c.(CacheDeleter); ok {
+		d.Delete(templatePath)
+	}
+}
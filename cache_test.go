@@ -0,0 +1,114 @@
+package jet
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// cachedNames returns, for each of names, the name of the template c holds under it or "" if none.
+func cachedNames(c Cache, names ...string) []string {
+	var result []string
+	for _, name := range names {
+		if t := c.Get(name); t != nil {
+			result = append(result, t.Name)
+		} else {
+			result = append(result, "")
+		}
+	}
+	return result
+}
+
+func TestLRUCache(t *testing.T) {
+	c := NewLRUCache(3)
+	for _, name := range []string{"/a", "/b", "/c"} {
+		c.Put(name, &Template{Name: name})
+	}
+	c.Get("/a")                        // /b is now the least recently used
+	c.Put("/d", &Template{Name: "/d"}) // evicts /b
+	if got, want := cachedNames(c, "/a", "/b", "/c", "/d"), []string{"/a", "", "/c", "/d"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("after evicting /b: got %q, want %q", got, want)
+	}
+	c.Put("/c", &Template{Name: "/c2"}) // replaces /c, which becomes the most recently used
+	c.Put("/e", &Template{Name: "/e"})  // evicts /a
+	if got, want := cachedNames(c, "/a", "/c", "/d", "/e"), []string{"", "/c2", "/d", "/e"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("after evicting /a: got %q, want %q", got, want)
+	}
+	if got, want := c.Stats(), (CacheStats{Hits: 7, Misses: 2, Len: 3, Capacity: 3}); got != want {
+		t.Errorf("stats: got %+v, want %+v", got, want)
+	}
+
+	c.Delete("/d")
+	if got := c.Len(); got != 2 {
+		t.Errorf("len after Delete: got %d, want 2", got)
+	}
+	c.Purge()
+	if got := c.Stats(); got.Len != 0 || got.Capacity != 3 {
+		t.Errorf("stats after Purge: got %+v", got)
+	}
+}
+
+func TestTTLCache(t *testing.T) {
+	const ttl = 200 * time.Millisecond
+	c := NewTTLCache(ttl)
+	c.Put("/a", &Template{Name: "/a"})
+	time.Sleep(ttl / 2)
+	c.Put("/b", &Template{Name: "/b"})
+	if got, want := cachedNames(c, "/a", "/b"), []string{"/a", "/b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("before expiry: got %q, want %q", got, want)
+	}
+
+	time.Sleep(ttl/2 + ttl/4) // /a expired, /b didn't
+	if got := c.Len(); got != 1 {
+		t.Errorf("len after /a expired: got %d, want 1", got)
+	}
+	if got, want := cachedNames(c, "/a", "/b"), []string{"", "/b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("after /a expired: got %q, want %q", got, want)
+	}
+
+	c.Put("/a", &Template{Name: "/a"})
+	time.Sleep(ttl / 2) // /b expired, the new /a didn't
+	if got, want := cachedNames(c, "/a", "/b"), []string{"/a", ""}; !reflect.DeepEqual(got, want) {
+		t.Errorf("after /b expired: got %q, want %q", got, want)
+	}
+	c.Delete("/a")
+	if got := c.Len(); got != 0 {
+		t.Errorf("len after Delete: got %d, want 0", got)
+	}
+}
+
+func TestTieredCache(t *testing.T) {
+	front, back := NewLRUCache(1), NewLRUCache(10)
+	c := NewTieredCache(front, back)
+	c.Put("/a", &Template{Name: "/a"})
+	c.Put("/b", &Template{Name: "/b"}) // evicts /a from the front only
+	if got, want := cachedNames(front, "/a", "/b"), []string{"", "/b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("front after Put: got %q, want %q", got, want)
+	}
+
+	if got := cachedNames(c, "/a"); got[0] != "/a" {
+		t.Errorf("/a was not found in the back cache")
+	}
+	if got, want := cachedNames(front, "/a", "/b"), []string{"/a", ""}; !reflect.DeepEqual(got, want) {
+		t.Errorf("front after promoting /a: got %q, want %q", got, want)
+	}
+	if got := back.Stats(); got.Hits != 1 {
+		t.Errorf("the back cache was hit %d times, want 1", got.Hits)
+	}
+	cachedNames(c, "/a")
+	if got := back.Stats(); got.Hits != 1 {
+		t.Errorf("a promoted template is looked up in the back cache again")
+	}
+
+	c.Delete("/a")
+	if got, want := cachedNames(c, "/a", "/b"), []string{"", "/b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("after Delete: got %q, want %q", got, want)
+	}
+	if front.Len() != 1 || back.Len() != 1 {
+		t.Errorf("after Delete: front holds %d templates and back %d, want 1 each", front.Len(), back.Len())
+	}
+	c.Purge()
+	if front.Len() != 0 || back.Len() != 0 || c.Len() != 0 {
+		t.Errorf("after Purge: front holds %d templates and back %d", front.Len(), back.Len())
+	}
+}
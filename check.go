@@ -0,0 +1,789 @@
+package jet
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/oarkflow/jet/utils/e"
+)
+
+// CheckErrors lists the problems Set.Check found in a template, in the order they were found.
+type CheckErrors []e.Error
+
+func (errs CheckErrors) Error() string {
+	messages := make([]string, len(errs))
+	for i, err := range errs {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "\n")
+}
+
+// Check loads the template at templatePath and checks it against the type of the context (the data passed to
+// Execute) and the types of the variables, without executing it. It follows the types of fields, methods,
+// indexes, calls and range variables from the context and the variables through the template, the templates it
+// extends and includes, and the blocks it yields, and reports:
+//
+//   - fields and methods that don't exist, or are unexported, and identifiers that aren't declared, passed in vars,
+//     globals or default variables
+//   - calls of functions, methods and globals with the wrong number of arguments
+//   - range over values that can't be ranged over
+//   - yields of blocks that don't exist
+//
+// A nil contextType, or a nil type in vars, stands for a value whose type is only known at runtime: nothing is
+// reported about what is read from it, and neither is anything read from interface values, through `?.` or inside
+// isset(). Arithmetic results are not typed either.
+//
+// Check returns the error of loading the template, if any, or CheckErrors, whose positions hold the line and column
+// of each problem.
+func (s *Set) Check(templatePath string, contextType reflect.Type, vars map[string]reflect.Type) error {
+	t, err := s.GetTemplate(templatePath)
+	if err != nil {
+		return err
+	}
+	c := &checker{
+		set:     s,
+		vars:    vars,
+		texts:   map[string]string{},
+		visited: map[checkVisit]bool{},
+		seen:    map[string]bool{},
+	}
+	c.template(t, contextType)
+	if len(c.errors) == 0 {
+		return nil
+	}
+	return c.errors
+}
+
+// checkScope holds the types of the variables declared in a scope; a nil type is unknown.
+type checkScope map[string]reflect.Type
+
+// checkVisit identifies a walk of a list with the types of its context and of its parameters, so that recursive
+// blocks and includes are walked once.
+type checkVisit struct {
+	list      *ListNode
+	context   reflect.Type
+	signature string
+}
+
+type checker struct {
+	set     *Set
+	vars    map[string]reflect.Type
+	texts   map[string]string // source of the walked templates, to find columns
+	blocks  map[string]*BlockNode
+	scopes  []checkScope
+	context reflect.Type
+	lenient int // > 0 while checking arguments of isset, where missing values are expected
+	visited map[checkVisit]bool
+	seen    map[string]bool
+	errors  CheckErrors
+}
+
+// template walks t as Execute runs it, with the given context.
+func (c *checker) template(t *Template, context reflect.Type) {
+	c.addTexts(t)
+	blocks := c.blocks
+	c.blocks = t.processedBlocks
+	defer func() { c.blocks = blocks }()
+
+	for t.extends != nil {
+		t = t.extends
+	}
+	c.visit(t.Root, context, "")
+}
+
+func (c *checker) addTexts(t *Template) {
+	if _, ok := c.texts[t.Name]; ok {
+		return
+	}
+	c.texts[t.Name] = t.text
+	if t.extends != nil {
+		c.addTexts(t.extends)
+	}
+	for _, _import := range t.imports {
+		c.addTexts(_import)
+	}
+}
+
+// visit walks list with the given context in a new scope, unless it was walked with the same types already.
+func (c *checker) visit(list *ListNode, context reflect.Type, signature string) {
+	key := checkVisit{list: list, context: context, signature: signature}
+	if list == nil || c.visited[key] {
+		return
+	}
+	c.visited[key] = true
+
+	outer := c.context
+	c.context = context
+	c.push()
+	c.list(list)
+	c.pop()
+	c.context = outer
+}
+
+func (c *checker) push() {
+	c.scopes = append(c.scopes, checkScope{})
+}
+
+func (c *checker) pop() {
+	c.scopes = c.scopes[:len(c.scopes)-1]
+}
+
+func (c *checker) declare(name string, typ reflect.Type) {
+	c.scopes[len(c.scopes)-1][name] = typ
+}
+
+// declareLoop declares the loop variable in the body of a range, unless a variable hides it.
+func (c *checker) declareLoop() {
+	for _, scope := range c.scopes {
+		if _, ok := scope[loopVariable]; ok {
+			return
+		}
+	}
+	if _, ok := c.vars[loopVariable]; ok {
+		return
+	}
+	c.declare(loopVariable, loopIterationType)
+}
+
+// lookup returns the type of the named value, and false if it is not declared anywhere.
+func (c *checker) lookup(name string) (reflect.Type, bool) {
+	for i := len(c.scopes) - 1; i >= 0; i-- {
+		if typ, ok := c.scopes[i][name]; ok {
+			return typ, true
+		}
+	}
+	if typ, ok := c.vars[name]; ok {
+		return typ, true
+	}
+	c.set.gmx.RLock()
+	v, ok := c.set.globals[name]
+	c.set.gmx.RUnlock()
+	if !ok {
+		v, ok = defaultVariables[name]
+	}
+	if !ok {
+		return nil, false
+	}
+	if !v.IsValid() {
+		return nil, true
+	}
+	return dynamic(v.Type()), true
+}
+
+// report records a problem found at node, once.
+func (c *checker) report(node Node, reason e.Reason, format string, args ...interface{}) {
+	if c.lenient > 0 {
+		return
+	}
+	line, column := c.position(node)
+	err := e.Build(reason, node.templatePath(), fmt.Sprintf(format, args...), &e.Position{L: line, C: column})
+	if c.seen[err.Error()] {
+		return
+	}
+	c.seen[err.Error()] = true
+	c.errors = append(c.errors, err)
+}
+
+// position returns the line and column of node in the source of its template.
+func (c *checker) position(node Node) (line, column int) {
+	text := c.texts[node.templatePath()]
+	pos := int(node.Position())
+	if pos > len(text) {
+		return node.line(), 0
+	}
+	line = 1 + strings.Count(text[:pos], "\n")
+	column = pos - strings.LastIndexByte(text[:pos], '\n')
+	return line, column
+}
+
+func (c *checker) list(list *ListNode) {
+	if list == nil {
+		return
+	}
+	for _, node := range list.Nodes {
+		switch node := node.(type) {
+		case *ActionNode:
+			if node.Set != nil {
+				c.setList(node.Set)
+			}
+			if node.Pipe != nil {
+				c.pipeline(node.Pipe)
+			}
+		case *IfNode:
+			c.push()
+			if node.Set != nil {
+				c.setList(node.Set)
+			}
+			c.expr(node.Expression)
+			c.visitList(node.List, c.context)
+			c.visitList(node.ElseList, c.context)
+			c.pop()
+		case *SwitchNode:
+			if node.Expression != nil {
+				c.expr(node.Expression)
+			}
+			for _, caseNode := range node.Cases {
+				for _, value := range caseNode.Values {
+					c.expr(value)
+				}
+				c.visitList(caseNode.List, c.context)
+			}
+			c.visitList(node.Default, c.context)
+		case *LoopControlNode:
+			if node.Condition != nil {
+				c.expr(node.Condition)
+			}
+		case *RangeNode:
+			c.rangeNode(node)
+		case *TryNode:
+			c.visitList(node.List, c.context)
+			if node.Catch != nil {
+				c.push()
+				if node.Catch.Err != nil {
+					c.declare(node.Catch.Err.Ident, nil)
+				}
+				c.visitList(node.Catch.List, c.context)
+				c.pop()
+			}
+		case *YieldNode:
+			c.yield(node)
+		case *BlockNode:
+			block, ok := c.blocks[node.Name]
+			if !ok {
+				block = node
+			}
+			c.visitList(node.Content, c.context)
+			c.block(block, block.Parameters, block.Expression)
+		case *IncludeNode:
+			c.include(node)
+		case *ReturnNode:
+			c.expr(node.Value)
+		case *TransNode:
+			if node.Key != nil {
+				c.expr(node.Key)
+			}
+			for _, arg := range node.Args {
+				c.expr(arg)
+			}
+			for _, arg := range node.Named {
+				c.expr(arg.Value)
+			}
+			c.visitList(node.List, c.context)
+		}
+	}
+}
+
+// visitList walks a nested list, which sees the variables of the enclosing lists.
+func (c *checker) visitList(list *ListNode, context reflect.Type) {
+	if list == nil {
+		return
+	}
+	outer := c.context
+	c.context = context
+	c.push()
+	c.list(list)
+	c.pop()
+	c.context = outer
+}
+
+func (c *checker) setList(set *SetNode) {
+	if set.IndexExprGetLookup {
+		typ := c.expr(set.Right[0])
+		c.assign(set.Left[0], typ, set.Let)
+		c.assign(set.Left[1], reflect.TypeOf(false), set.Let)
+		return
+	}
+	for i := range set.Left {
+		c.assign(set.Left[i], c.expr(set.Right[i]), set.Let)
+	}
+}
+
+func (c *checker) assign(left Expression, typ reflect.Type, let bool) {
+	ident, ok := left.(*IdentifierNode)
+	if !ok {
+		if left.Type() != NodeUnderscore {
+			c.expr(left)
+		}
+		return
+	}
+	if let {
+		c.declare(ident.Ident, typ)
+		return
+	}
+	for i := len(c.scopes) - 1; i >= 0; i-- {
+		if _, ok := c.scopes[i][ident.Ident]; ok {
+			c.scopes[i][ident.Ident] = commonType(c.scopes[i][ident.Ident], typ)
+			return
+		}
+	}
+	c.expr(ident)
+}
+
+func (c *checker) rangeNode(node *RangeNode) {
+	var target Expression = node.Expression
+	if node.Set != nil {
+		target = node.Set.Right[0]
+	}
+	typ := c.expr(target)
+	key, value, providesIndex, ok := rangeTypes(typ)
+	if !ok {
+		c.report(target, e.InvalidValueReason, "cannot range over %s (type %s)", target, typ)
+	}
+
+	c.push()
+	c.declareLoop()
+	context := c.context
+	if node.Set == nil {
+		context = value
+	} else {
+		left := node.Set.Left
+		switch {
+		case len(left) > 1 && !providesIndex:
+			c.report(node, e.InvalidValueReason, "two-var range over %s (type %s), which does not provide an index", target, typ)
+		case len(left) > 1:
+			c.assign(left[0], key, node.Set.Let)
+			c.assign(left[1], value, node.Set.Let)
+		case providesIndex:
+			// a single variable gets the index, the element becomes the context
+			c.assign(left[0], key, node.Set.Let)
+			context = value
+		default:
+			c.assign(left[0], value, node.Set.Let)
+		}
+	}
+	c.visitList(node.List, context)
+	c.visitList(node.ElseList, c.context)
+	c.pop()
+}
+
+// rangeTypes returns the types of the index and the elements of a range over a value of type typ, whether the
+// range provides an index and whether typ can be ranged over (true when typ is unknown).
+func rangeTypes(typ reflect.Type) (key, value reflect.Type, providesIndex, ok bool) {
+	if typ == nil || typ.Implements(rangerType) {
+		return nil, nil, true, true
+	}
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	switch typ.Kind() {
+	case reflect.Interface:
+		return nil, nil, true, true
+	case reflect.Slice, reflect.Array:
+		return reflect.TypeOf(0), dynamic(typ.Elem()), true, true
+	case reflect.Map:
+		return dynamic(typ.Key()), dynamic(typ.Elem()), true, true
+	case reflect.Chan:
+		return nil, dynamic(typ.Elem()), false, true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return reflect.TypeOf(int64(0)), reflect.TypeOf(int64(0)), true, true
+	case reflect.Float32, reflect.Float64:
+		// number literals are floats; ranging over one is an error when it isn't a whole number
+		return reflect.TypeOf(int64(0)), reflect.TypeOf(int64(0)), true, true
+	case reflect.Func:
+		if typ.CanSeq2() {
+			yield := typ.In(0)
+			return dynamic(yield.In(0)), dynamic(yield.In(1)), true, true
+		}
+		if typ.CanSeq() {
+			if yield := typ.In(0); yield.NumIn() > 0 {
+				return nil, dynamic(yield.In(0)), false, true
+			}
+			return nil, nil, false, true
+		}
+		if typ.NumIn() == 1 && typ.NumOut() == 0 {
+			// func(yield func() bool)
+			yield := typ.In(0)
+			if yield.Kind() == reflect.Func && yield.NumIn() == 0 && yield.NumOut() == 1 && yield.Out(0).Kind() == reflect.Bool {
+				return nil, nil, false, true
+			}
+		}
+	}
+	return nil, nil, true, false
+}
+
+func (c *checker) yield(node *YieldNode) {
+	if node.IsContent {
+		if node.Expression != nil {
+			c.expr(node.Expression)
+		}
+		return
+	}
+	block, ok := c.blocks[node.Name]
+	if !ok || block == nil {
+		c.report(node, "unresolved.block", "unresolved block %q", node.Name)
+		return
+	}
+	c.visitList(node.Content, c.context)
+	c.block(block, node.Parameters, node.Expression)
+}
+
+// block walks the body of block as executeYieldBlock runs it, with the parameters passed in params.
+func (c *checker) block(block *BlockNode, params *BlockParameterList, expression Expression) {
+	context := c.context
+	if expression != nil {
+		context = c.expr(expression)
+	}
+	scope := checkScope{}
+	var signature []string
+	for _, p := range params.List {
+		if p.Expression != nil {
+			scope[p.Identifier] = c.expr(p.Expression)
+		}
+	}
+	c.scopes = append(c.scopes, scope)
+	for _, p := range block.Parameters.List {
+		if _, ok := scope[p.Identifier]; !ok {
+			if p.Expression == nil {
+				scope[p.Identifier] = reflect.TypeOf(false)
+			} else {
+				scope[p.Identifier] = c.expr(p.Expression)
+			}
+		}
+		signature = append(signature, fmt.Sprint(scope[p.Identifier]))
+	}
+	c.visit(block.List, context, strings.Join(signature, ","))
+	c.pop()
+}
+
+func (c *checker) include(node *IncludeNode) {
+	c.expr(node.Name)
+	context := c.context
+	if node.Context != nil {
+		context = c.expr(node.Context)
+	}
+	name, ok := node.Name.(*StringNode)
+	if !ok {
+		return
+	}
+	t, err := c.set.getSiblingTemplate(name.Text, node.TemplatePath, true)
+	if err != nil {
+		c.report(node, e.TemplateErrorReason, "%s", err)
+		return
+	}
+	c.template(t, context)
+}
+
+// pipeline returns the type of the value of a pipeline.
+func (c *checker) pipeline(node *PipeNode) reflect.Type {
+	typ := c.command(node.Cmds[0], false)
+	for _, cmd := range node.Cmds[1:] {
+		typ = c.command(cmd, true)
+	}
+	return typ
+}
+
+func (c *checker) command(node *CommandNode, isPiped bool) reflect.Type {
+	if node.filter.IsValid() {
+		return c.call(node.BaseExpr, node.filter.Type(), node.CallArgs, true)
+	}
+	if node.Exprs == nil && !isPiped {
+		return c.expr(node.BaseExpr)
+	}
+	base := c.expr(node.BaseExpr)
+	return c.call(node.BaseExpr, base, node.CallArgs, isPiped)
+}
+
+// call returns the type of the result of calling a value of type fn, and checks the number of arguments.
+func (c *checker) call(base Expression, fn reflect.Type, args CallArgs, piped bool) reflect.Type {
+	isset := false
+	if ident, ok := base.(*IdentifierNode); ok && ident.Ident == "isset" {
+		if _, declared := c.lookupLocal(ident.Ident); !declared {
+			isset = true
+		}
+	}
+	if isset {
+		c.lenient++
+	}
+	for _, arg := range args.Exprs {
+		if arg.Type() != NodeUnderscore {
+			c.expr(arg)
+		}
+	}
+	if isset {
+		c.lenient--
+		return reflect.TypeOf(false)
+	}
+
+	if fn == nil {
+		return nil
+	}
+	if fn.Kind() != reflect.Func {
+		c.report(base, e.InvalidValueReason, "cannot call %s (type %s)", base, fn)
+		return nil
+	}
+	if fn == safeWriterType {
+		return nil
+	}
+	if funcType.AssignableTo(fn) {
+		if ident, ok := base.(*IdentifierNode); ok && ident.Ident == "len" {
+			return reflect.TypeOf(0)
+		}
+		return nil
+	}
+
+	numArgs := len(args.Exprs)
+	if !args.HasPipeSlot && piped {
+		numArgs++
+	}
+	if fn.IsVariadic() {
+		if numArgs < fn.NumIn()-1 {
+			c.report(base, e.InvalidNumberOfArgumentsReason, "not enough arguments in call to %s: want at least %d, have %d", base, fn.NumIn()-1, numArgs)
+		}
+	} else if numArgs != fn.NumIn() {
+		c.report(base, e.InvalidNumberOfArgumentsReason, "wrong number of arguments in call to %s: want %d, have %d", base, fn.NumIn(), numArgs)
+	}
+	if fn.NumOut() == 0 {
+		return nil
+	}
+	return dynamic(fn.Out(0))
+}
+
+// lookupLocal looks a name up in the scopes declared by the template and in vars.
+func (c *checker) lookupLocal(name string) (reflect.Type, bool) {
+	for i := len(c.scopes) - 1; i >= 0; i-- {
+		if typ, ok := c.scopes[i][name]; ok {
+			return typ, true
+		}
+	}
+	typ, ok := c.vars[name]
+	return typ, ok
+}
+
+// expr returns the type of the value of an expression, or nil if it is only known at runtime.
+func (c *checker) expr(node Expression) reflect.Type {
+	switch node := node.(type) {
+	case *NilNode, *UnderscoreNode:
+		return nil
+	case *BoolNode:
+		return reflect.TypeOf(false)
+	case *StringNode:
+		return reflect.TypeOf("")
+	case *NumberNode:
+		switch {
+		case node.IsFloat:
+			return reflect.TypeOf(node.Float64)
+		case node.IsInt:
+			return reflect.TypeOf(node.Int64)
+		case node.IsUint:
+			return reflect.TypeOf(node.Uint64)
+		}
+		return nil
+	case *IdentifierNode:
+		if node.Ident == "." {
+			return c.context
+		}
+		typ, ok := c.lookup(node.Ident)
+		if !ok {
+			c.report(node, "not_available.identifier", "undefined: %s", node.Ident)
+		}
+		return typ
+	case *FieldNode:
+		typ := c.context
+		for _, ident := range node.Idents {
+			typ = c.field(node, typ, ident)
+		}
+		return typ
+	case *ChainNode:
+		typ := c.expr(node.Node)
+		for _, ident := range node.Field {
+			typ = c.field(node, typ, ident)
+		}
+		return typ
+	case *PipeNode:
+		return c.pipeline(node)
+	case *CommandNode:
+		return c.command(node, false)
+	case *CallExprNode:
+		return c.call(node.BaseExpr, c.expr(node.BaseExpr), node.CallArgs, false)
+	case *IndexExprNode:
+		return c.index(node)
+	case *SliceExprNode:
+		typ := c.expr(node.Base)
+		if node.Index != nil {
+			c.expr(node.Index)
+		}
+		if node.EndIndex != nil {
+			c.expr(node.EndIndex)
+		}
+		return typ
+	case *AdditiveExprNode:
+		var left reflect.Type
+		if node.Left != nil {
+			left = c.expr(node.Left)
+		}
+		right := c.expr(node.Right)
+		if left != nil && left.Kind() == reflect.String && right != nil && right.Kind() == reflect.String {
+			return reflect.TypeOf("")
+		}
+		return nil
+	case *MultiplicativeExprNode:
+		c.expr(node.Left)
+		c.expr(node.Right)
+		return nil
+	case *ComparativeExprNode:
+		c.expr(node.Left)
+		c.expr(node.Right)
+		return reflect.TypeOf(false)
+	case *NumericComparativeExprNode:
+		c.expr(node.Left)
+		c.expr(node.Right)
+		return reflect.TypeOf(false)
+	case *LogicalExprNode:
+		c.expr(node.Left)
+		c.expr(node.Right)
+		return reflect.TypeOf(false)
+	case *CoalesceExprNode:
+		// the left side may be missing, like the arguments of isset
+		c.lenient++
+		left := c.expr(node.Left)
+		c.lenient--
+		return commonType(left, c.expr(node.Right))
+	case *NotExprNode:
+		c.expr(node.Expr)
+		return reflect.TypeOf(false)
+	case *TernaryExprNode:
+		c.expr(node.Boolean)
+		return commonType(c.expr(node.Left), c.expr(node.Right))
+	}
+	return nil
+}
+
+// field returns the type of a field or method of a value of type typ, as resolveIndex finds it.
+func (c *checker) field(node Node, typ reflect.Type, ident Ident) reflect.Type {
+	if typ == nil {
+		return nil
+	}
+	if typ == loopIterationType {
+		field, ok := loopFields[ident.name]
+		if !ok && !ident.lax {
+			c.report(node, e.NotFoundFieldOrMethodReason, "%s undefined (%s has no field %s)", node, loopVariable, ident.name)
+		}
+		return field
+	}
+	if method, ok := methodType(typ, ident.name); ok {
+		return method
+	}
+	base := typ
+	for base.Kind() == reflect.Ptr {
+		base = base.Elem()
+	}
+	switch base.Kind() {
+	case reflect.Interface:
+		return nil
+	case reflect.Struct:
+		if c.set.jsonTags {
+			index, ok := structFields(base, true)[ident.name]
+			if !ok {
+				if !ident.lax {
+					c.report(node, e.NotFoundFieldOrMethodReason, "%s undefined (type %s has no field or method %s)", node, typ, ident.name)
+				}
+				return nil
+			}
+			return dynamic(base.FieldByIndex(index).Type)
+		}
+		field, ok := base.FieldByName(ident.name)
+		if !ok {
+			if !ident.lax {
+				c.report(node, e.NotFoundFieldOrMethodReason, "%s undefined (type %s has no field or method %s)", node, typ, ident.name)
+			}
+			return nil
+		}
+		if field.PkgPath != "" {
+			c.report(node, e.InvalidIndexReason, "%s undefined (%s is an unexported field of type %s)", node, ident.name, typ)
+			return nil
+		}
+		return dynamic(field.Type)
+	case reflect.Map:
+		if !reflect.TypeOf("").ConvertibleTo(base.Key()) {
+			c.report(node, e.InvalidIndexReason, "%s undefined (can't use %s as key of type %s)", node, ident.name, typ)
+			return nil
+		}
+		return dynamic(base.Elem())
+	}
+	if !ident.lax {
+		c.report(node, e.NotFoundFieldOrMethodReason, "%s undefined (type %s has no field or method %s)", node, typ, ident.name)
+	}
+	return nil
+}
+
+// methodType returns the type of the method value named name of values of type typ, with or without pointer
+// receiver.
+func methodType(typ reflect.Type, name string) (reflect.Type, bool) {
+	if typ.Kind() == reflect.Interface {
+		if method, ok := typ.MethodByName(name); ok {
+			return method.Type, true
+		}
+		return nil, false
+	}
+	ptr := typ
+	if ptr.Kind() != reflect.Ptr {
+		ptr = reflect.PointerTo(typ)
+	}
+	method, ok := ptr.MethodByName(name)
+	if !ok {
+		return nil, false
+	}
+	in := make([]reflect.Type, method.Type.NumIn()-1)
+	for i := range in {
+		in[i] = method.Type.In(i + 1)
+	}
+	out := make([]reflect.Type, method.Type.NumOut())
+	for i := range out {
+		out[i] = method.Type.Out(i)
+	}
+	return reflect.FuncOf(in, out, method.Type.IsVariadic()), true
+}
+
+func (c *checker) index(node *IndexExprNode) reflect.Type {
+	typ := c.expr(node.Base)
+	index := c.expr(node.Index)
+	if typ == nil {
+		return nil
+	}
+	if str, ok := node.Index.(*StringNode); ok {
+		return c.field(node, typ, Ident{name: str.Text, lax: node.Nullable})
+	}
+	base := typ
+	for base.Kind() == reflect.Ptr {
+		base = base.Elem()
+	}
+	switch base.Kind() {
+	case reflect.Interface:
+		return nil
+	case reflect.Slice, reflect.Array:
+		return dynamic(base.Elem())
+	case reflect.String:
+		return reflect.TypeOf(byte(0))
+	case reflect.Map:
+		if index != nil && !index.ConvertibleTo(base.Key()) {
+			c.report(node, e.InvalidIndexReason, "invalid index %s (type %s) of type %s", node.Index, index, typ)
+		}
+		return dynamic(base.Elem())
+	case reflect.Struct:
+		if index == nil || index.Kind() == reflect.String {
+			return nil
+		}
+	}
+	if !node.Nullable {
+		c.report(node, e.InvalidIndexReason, "cannot index %s (type %s)", node.Base, typ)
+	}
+	return nil
+}
+
+// dynamic returns typ, or nil if values of type typ hold values of other types, which are only known at runtime.
+func dynamic(typ reflect.Type) reflect.Type {
+	if typ.Kind() == reflect.Interface && typ.NumMethod() == 0 {
+		return nil
+	}
+	return typ
+}
+
+// commonType returns the type of a value that is either of type a or b.
+func commonType(a, b reflect.Type) reflect.Type {
+	if a == b {
+		return a
+	}
+	return nil
+}
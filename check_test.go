@@ -0,0 +1,99 @@
+package jet
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type checkUser struct {
+	Name    string
+	Tags    []string
+	Friends map[string]*checkUser
+	secret  string
+}
+
+func (u checkUser) Greet(greeting string) string { return greeting + " " + u.Name }
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name, template string
+		want           []string
+	}{
+		{"valid", `{{ .Name }}{{ .Greet("hi") }}{{ user.Friends["a"].Tags[0] }}{{ len(.Tags) }}{{ upper(.Name) }}`, nil},
+		{"unknown field", `{{ .Nme }}`, []string{
+			"not_found.field_or_method /main.jet:1:4 .Nme undefined (type jet.checkUser has no field or method Nme)",
+		}},
+		{"unexported field", `{{ .secret }}`, []string{
+			"invalid.index /main.jet:1:4 .secret undefined (secret is an unexported field of type jet.checkUser)",
+		}},
+		{"pointer field", `{{ user.Age }}`, []string{
+			"not_found.field_or_method /main.jet:1:4 user.Age undefined (type *jet.checkUser has no field or method Age)",
+		}},
+		{"arguments", `{{ .Greet() }}`, []string{
+			"invalid.number_of_arguments /main.jet:1:4 wrong number of arguments in call to .Greet: want 1, have 0",
+		}},
+		{"undeclared", `{{ missing }}`, []string{"not_available.identifier /main.jet:1:4 undefined: missing"}},
+		{"declared", `{{ x := .Friends["a"] }}{{ x.Name }}{{ x.Nme }}`, []string{
+			"not_found.field_or_method /main.jet:1:40 x.Nme undefined (type *jet.checkUser has no field or method Nme)",
+		}},
+		{"range context", `{{ range .Tags }}{{ .Foo }}{{ end }}`, []string{
+			"not_found.field_or_method /main.jet:1:21 .Foo undefined (type string has no field or method Foo)",
+		}},
+		{"range key", `{{ range k := .Friends }}{{ k.Name }}{{ .Name }}{{ end }}`, []string{
+			"not_found.field_or_method /main.jet:1:29 k.Name undefined (type string has no field or method Name)",
+		}},
+		{"range value", `{{ range k, f := .Friends }}{{ k }}{{ f.Name }}{{ end }}`, nil},
+		{"range invalid", `{{ range .Name }}{{ end }}`, []string{
+			"invalid.value /main.jet:1:10 cannot range over .Name (type string)",
+		}},
+		{"loop", `{{ range .Tags }}{{ loop.index }}{{ loop.idx }}{{ end }}{{ loop.index }}`, []string{
+			"not_found.field_or_method /main.jet:1:37 loop.idx undefined (loop has no field idx)",
+			"not_available.identifier /main.jet:1:60 undefined: loop",
+		}},
+		{"unknown types", `{{ anything.X.Y }}{{ isset(.Nope) }}{{ .Nope ?? "x" }}`, nil},
+		{"yield", `{{ yield nope() }}`, []string{`unresolved.block /main.jet:1:10 unresolved block "nope"`}},
+		{"block parameters", `{{ block card(u=user) }}{{ u.Name }}{{ u.Nme }}{{ end }}{{ yield card(u=user.Friends["a"]) }}`, []string{
+			"not_found.field_or_method /main.jet:1:40 u.Nme undefined (type *jet.checkUser has no field or method Nme)",
+		}},
+		{"include", `{{ include "/partial.jet" user }}`, []string{
+			"not_found.field_or_method /partial.jet:1:15 .Age undefined (type *jet.checkUser has no field or method Age)",
+		}},
+		{"extends", `{{ extends "/layout.jet" }}{{ block body() }}{{ .Nme }}{{ end }}`, []string{
+			"not_found.field_or_method /layout.jet:1:15 .Titl undefined (type jet.checkUser has no field or method Titl)",
+			"not_found.field_or_method /main.jet:1:49 .Nme undefined (type jet.checkUser has no field or method Nme)",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loader := NewInMemLoader()
+			loader.Set("/main.jet", tt.template)
+			loader.Set("/partial.jet", `{{ .Name }}{{ .Age }}`)
+			loader.Set("/layout.jet", `{{ .Name }}{{ .Titl }}{{ yield body() }}`)
+			err := NewSet(loader).Check("/main.jet", reflect.TypeOf(checkUser{}), map[string]reflect.Type{
+				"user":     reflect.TypeOf(&checkUser{}),
+				"anything": nil,
+			})
+			var got []string
+			if err != nil {
+				var errs CheckErrors
+				if !errors.As(err, &errs) {
+					t.Fatalf("unexpected error %v", err)
+				}
+				for _, err := range errs {
+					got = append(got, err.Error())
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckLoadError(t *testing.T) {
+	err := NewSet(NewInMemLoader()).Check("/missing.jet", nil, nil)
+	if _, ok := err.(CheckErrors); err == nil || ok {
+		t.Errorf("got %v, want the error of loading the template", err)
+	}
+}
@@ -0,0 +1,898 @@
+package jet
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"sync/atomic"
+
+	"github.com/oarkflow/jet/fastprinter"
+	"github.com/oarkflow/jet/utils/e"
+)
+
+// WithClosureCompilation returns an option function that makes the Set compile every template it parses (or
+// decodes with UnmarshalBinary) into Go closures, executed in place of walking the tree. Literals and constant
+// subexpressions are evaluated once at compile time and node dispatch happens once instead of on every render;
+// values are still handled through reflection, and identifiers are still looked up at runtime since variables,
+// globals and block parameters are only known then. Output and errors are the same as without the option.
+func WithClosureCompilation() Option {
+	return func(s *Set) {
+		s.closures = true
+	}
+}
+
+// listFunc executes a compiled list of nodes, like Runtime.executeList.
+type listFunc func(rt *Runtime) (reflect.Value, e.Error)
+
+// exprFunc evaluates a compiled expression, like Runtime.evalPrimaryExpressionGroup.
+type exprFunc func(rt *Runtime) (reflect.Value, e.Error)
+
+// pipeFunc evaluates a compiled pipeline, like Runtime.evalPipelineExpression.
+type pipeFunc func(rt *Runtime) (value reflect.Value, safeWriter bool, err e.Error)
+
+// listRun holds the state shared by the nodes of a list during its execution.
+type listRun struct {
+	returnValue reflect.Value
+	err         e.Error
+	inNewScope  bool
+}
+
+// stepFunc executes a node of a list; a non-nil error stops the list and is its result.
+type stepFunc func(rt *Runtime, l listRun) (listRun, e.Error)
+
+// listStep is a compiled node of a list: text is written as is, other nodes run their step function.
+type listStep struct {
+	node     Node
+	text     []byte
+	fn       stepFunc
+	declares bool // the node declares variables in the list's scope, created by the first of them
+}
+
+// compileClosures compiles every list of the template's tree. It fails on nodes it can't compile rather than
+// leaving them out of the output.
+func (t *Template) compileClosures() e.Error {
+	c := &closureCompiler{}
+	c.list(t.Root)
+	for _, block := range t.passedBlocks {
+		c.list(block.List)
+		c.list(block.Content)
+	}
+	return c.err
+}
+
+type closureCompiler struct {
+	err e.Error // the first node that can't be compiled
+}
+
+// list compiles list, storing the result in the list so that executeList runs it, and returns it.
+func (c *closureCompiler) list(list *ListNode) listFunc {
+	if list == nil {
+		return nil
+	}
+	if list.exec != nil {
+		return list.exec
+	}
+	steps := make([]listStep, len(list.Nodes))
+	declares := false // whether an action declares variables in the list's scope
+	for i, node := range list.Nodes {
+		steps[i].node = node
+		switch node := node.(type) {
+		case *TextNode:
+			steps[i].text = node.Text
+		case *ActionNode:
+			steps[i].declares = node.Set != nil && node.Set.Let
+			declares = declares || steps[i].declares
+			steps[i].fn = c.step(node)
+		default:
+			steps[i].fn = c.step(node)
+		}
+	}
+	if !declares {
+		list.exec = func(rt *Runtime) (reflect.Value, e.Error) {
+			var l listRun
+			if err := runSteps(rt, steps, &l); err != nil {
+				return reflect.Value{}, err
+			}
+			return l.returnValue, l.err
+		}
+		return list.exec
+	}
+	list.exec = func(rt *Runtime) (reflect.Value, e.Error) {
+		var l listRun
+		defer func() {
+			// the scope is released even when a node panics, like executeList does
+			if l.inNewScope {
+				rt.releaseScope()
+			}
+		}()
+		if err := runSteps(rt, steps, &l); err != nil {
+			return reflect.Value{}, err
+		}
+		return l.returnValue, l.err
+	}
+	return list.exec
+}
+
+// runSteps executes the steps of a list, keeping its state in l.
+func runSteps(rt *Runtime, steps []listStep, l *listRun) (err e.Error) {
+	for i := range steps {
+		step := &steps[i]
+		if err := rt.canceled(step.node); err != nil {
+			return err
+		}
+		if err := rt.limits.step(step.node); err != nil {
+			return err
+		}
+		if step.fn == nil {
+			if step.text != nil {
+				if _, err := rt.Writer.Write(step.text); err != nil {
+					return step.node.error("", err.Error())
+				}
+			}
+			continue
+		}
+		if step.declares && !l.inNewScope {
+			// one scope for all the declarations of the list
+			rt.newScope()
+			l.inNewScope = true
+		}
+		if *l, err = step.fn(rt, *l); err != nil {
+			return err
+		}
+		if rt.loop != loopNone {
+			// a break or continue skips the rest of the lists up to the innermost range
+			return nil
+		}
+	}
+	return nil
+}
+
+// step compiles a node of a list other than text. The steps mirror the cases of executeList.
+func (c *closureCompiler) step(node Node) stepFunc {
+	switch node := node.(type) {
+	case *ActionNode:
+		return c.action(node)
+	case *IfNode:
+		return c.ifNode(node)
+	case *SwitchNode:
+		return c.switchNode(node)
+	case *RangeNode:
+		return c.rangeNode(node)
+	case *TryNode:
+		c.list(node.List)
+		if node.Catch != nil {
+			c.list(node.Catch.List)
+		}
+		return func(rt *Runtime, l listRun) (listRun, e.Error) {
+			l.returnValue, l.err = rt.executeTry(node)
+			return l, nil
+		}
+	case *YieldNode:
+		c.list(node.Content)
+		if node.IsContent {
+			return func(rt *Runtime, l listRun) (listRun, e.Error) {
+				if rt.content != nil {
+					l.err = rt.content(rt, node.Expression)
+				}
+				return l, nil
+			}
+		}
+		return func(rt *Runtime, l listRun) (listRun, e.Error) {
+			block, has := rt.getBlock(node.Name)
+			if has == false || block == nil {
+				return l, node.error("unresolved.block", fmt.Sprintf("unresolved block %q!!", node.Name))
+			}
+			l.err = rt.executeYieldBlock(block, block.Parameters, node.Parameters, node.Expression, node.Content)
+			return l, nil
+		}
+	case *BlockNode:
+		c.list(node.List)
+		c.list(node.Content)
+		return func(rt *Runtime, l listRun) (listRun, e.Error) {
+			block, has := rt.getBlock(node.Name)
+			if has == false {
+				block = node
+			}
+			l.err = rt.executeYieldBlock(block, block.Parameters, block.Parameters, block.Expression, block.Content)
+			return l, nil
+		}
+	case *IncludeNode:
+		return func(rt *Runtime, l listRun) (listRun, e.Error) {
+			l.returnValue, l.err = rt.executeInclude(node)
+			return l, nil
+		}
+	case *ReturnNode:
+		value := c.expr(node.Value)
+		return func(rt *Runtime, l listRun) (listRun, e.Error) {
+			l.returnValue, l.err = value(rt)
+			return l, nil
+		}
+	case *TransNode:
+		c.list(node.List)
+		return func(rt *Runtime, l listRun) (listRun, e.Error) {
+			l.err = rt.executeTrans(node)
+			return l, nil
+		}
+	case *LoopControlNode:
+		control := loopBreak
+		if node.Type() == NodeContinue {
+			control = loopContinue
+		}
+		var condition exprFunc
+		if node.Condition != nil {
+			condition = c.expr(node.Condition)
+		}
+		return func(rt *Runtime, l listRun) (listRun, e.Error) {
+			if condition != nil {
+				v, err := condition(rt)
+				if err != nil {
+					return l, err
+				}
+				if !isTrue(v) {
+					return l, nil
+				}
+			}
+			rt.loop = control
+			return l, nil
+		}
+	}
+	if c.err == nil {
+		c.err = node.error(e.UnexpectedNodeReason, fmt.Sprintf("can't compile %T %s", node, node))
+	}
+	return nil
+}
+
+func (c *closureCompiler) action(node *ActionNode) stepFunc {
+	var set func(rt *Runtime) e.Error
+	if node.Set != nil {
+		set = c.setList(node.Set)
+	}
+	var pipe pipeFunc
+	var value exprFunc // the value of a pipeline made of a single command without arguments
+	if node.Pipe != nil {
+		if cmd := node.Pipe.Cmds[0]; len(node.Pipe.Cmds) == 1 && cmd.Exprs == nil {
+			value = c.expr(cmd.BaseExpr)
+		} else {
+			pipe = c.pipeline(node.Pipe)
+		}
+	}
+	renderers := &rendererCheck{}
+	return func(rt *Runtime, l listRun) (listRun, e.Error) {
+		if set != nil {
+			// lists create the scope of declarations before running their step
+			l.err = set(rt)
+		}
+		if pipe == nil && value == nil {
+			return l, nil
+		}
+		rt.missing = false
+		var v reflect.Value
+		var safeWriter bool
+		var err e.Error
+		if value != nil {
+			v, err = value(rt)
+		} else {
+			v, safeWriter, err = pipe(rt)
+		}
+		if rt.missing {
+			rt.missing = false
+			if rt.set.missingKey == MissingKeyKeep {
+				if _, err := io.WriteString(rt.Writer, node.src); err != nil {
+					return l, node.error("", err.Error())
+				}
+				return l, nil
+			}
+			if err != nil {
+				// MissingKeyZero: a failure caused by the missing value renders nothing
+				return l, nil
+			}
+		}
+		if err != nil {
+			return l, err
+		}
+		if !safeWriter {
+			if v.IsValid() && renderers.implements(v.Type()) {
+				v.Interface().(Renderer).Render(rt)
+			} else if rt.binder != nil {
+				if err := rt.binder.bind(rt.Writer, node, v); err != nil {
+					return l, node.error("", err.Error())
+				}
+			} else if v.IsValid() {
+				if _, err := fastprinter.PrintValue(rt.escapeeWriter, v); err != nil {
+					return l, node.error("", err.Error())
+				}
+			}
+		}
+		return l, nil
+	}
+}
+
+// rendererCheck remembers whether the first type an action printed implements Renderer, since actions mostly
+// print values of the same type again and again.
+type rendererCheck struct {
+	first atomic.Pointer[checkedType]
+}
+
+type checkedType struct {
+	t        reflect.Type
+	renderer bool
+}
+
+func (c *rendererCheck) implements(t reflect.Type) bool {
+	first := c.first.Load()
+	if first != nil && first.t == t {
+		return first.renderer
+	}
+	renderer := t.Implements(rendererType)
+	if first == nil {
+		c.first.CompareAndSwap(nil, &checkedType{t: t, renderer: renderer})
+	}
+	return renderer
+}
+
+// setList compiles the declarations of a let list; assignments and lookups with an ok value run as usual.
+func (c *closureCompiler) setList(set *SetNode) func(rt *Runtime) e.Error {
+	if !set.Let || set.IndexExprGetLookup {
+		if set.Let {
+			return func(rt *Runtime) e.Error { return rt.executeLetList(set) }
+		}
+		return func(rt *Runtime) e.Error { return rt.executeSetList(set) }
+	}
+	right := make([]exprFunc, len(set.Right))
+	names := make([]string, len(set.Left))
+	for i := range set.Left {
+		right[i] = c.expr(set.Right[i])
+		if set.Left[i].Type() != NodeUnderscore {
+			names[i] = set.Left[i].(*IdentifierNode).Ident
+		}
+	}
+	return func(rt *Runtime) e.Error {
+		for i, name := range names {
+			value, err := right[i](rt)
+			if err != nil {
+				return err
+			}
+			if name != "" {
+				rt.variables[name] = value
+			}
+		}
+		return nil
+	}
+}
+
+func (c *closureCompiler) switchNode(node *SwitchNode) stepFunc {
+	type compiledCase struct {
+		values []exprFunc
+		list   listFunc
+	}
+	var subject exprFunc
+	if node.Expression != nil {
+		subject = c.expr(node.Expression)
+	}
+	cases := make([]compiledCase, len(node.Cases))
+	for i, caseNode := range node.Cases {
+		cases[i].list = c.list(caseNode.List)
+		for _, value := range caseNode.Values {
+			cases[i].values = append(cases[i].values, c.expr(value))
+		}
+	}
+	defaultList := c.list(node.Default)
+	return func(rt *Runtime, l listRun) (listRun, e.Error) {
+		var expression reflect.Value
+		if subject != nil {
+			var err e.Error
+			if expression, err = subject(rt); err != nil {
+				return l, err
+			}
+		}
+		list := defaultList
+	match:
+		for _, caseNode := range cases {
+			for _, value := range caseNode.values {
+				v, err := value(rt)
+				if err != nil {
+					return l, err
+				}
+				if subject == nil && isTrue(v) || subject != nil && checkEquality(expression, v) {
+					list = caseNode.list
+					break match
+				}
+			}
+		}
+		// errors of the case are dropped, as executeList does
+		if list != nil {
+			l.returnValue, _ = list(rt)
+		}
+		return l, nil
+	}
+}
+
+func (c *closureCompiler) ifNode(node *IfNode) stepFunc {
+	var set func(rt *Runtime) e.Error
+	if node.Set != nil {
+		set = c.setList(node.Set)
+	}
+	isLet := node.Set != nil && node.Set.Let
+	condition := c.expr(node.Expression)
+	list := c.list(node.List)
+	elseList := c.list(node.ElseList)
+	return func(rt *Runtime, l listRun) (listRun, e.Error) {
+		if set != nil {
+			if isLet {
+				rt.newScope()
+			}
+			l.err = set(rt)
+		}
+		expression, err := condition(rt)
+		if err != nil {
+			return l, err
+		}
+		// errors of the branches are dropped, as executeList does
+		if isTrue(expression) {
+			l.returnValue, _ = list(rt)
+		} else if elseList != nil {
+			l.returnValue, _ = elseList(rt)
+		}
+		if isLet {
+			rt.releaseScope()
+		}
+		return l, nil
+	}
+}
+
+func (c *closureCompiler) rangeNode(node *RangeNode) stepFunc {
+	isSet := node.Set != nil
+	isLet := isSet && node.Set.Let
+	var expression exprFunc
+	if isSet {
+		expression = c.expr(node.Set.Right[0])
+	} else {
+		expression = c.expr(node.Expression)
+	}
+	list := c.list(node.List)
+	elseList := c.list(node.ElseList)
+	return func(rt *Runtime, l listRun) (listRun, e.Error) {
+		keyVarSlot := 0
+		valVarSlot := -1
+		context := rt.context
+
+		var value reflect.Value
+		value, l.err = expression(rt)
+		if l.err != nil {
+			return l, l.err
+		}
+		if isSet {
+			if len(node.Set.Left) > 1 {
+				valVarSlot = 1
+			}
+			if isLet {
+				rt.newScope()
+			}
+		}
+
+		ranger, cleanup, err := getRanger(value)
+		if err != nil {
+			return l, node.error("", err.Error())
+		}
+		if _, ok := ranger.(*funcRanger); ok {
+			// stop the iterator even when the body panics; stopping it twice is harmless
+			defer cleanup()
+		}
+		if cr, ok := ranger.(*chanRanger); ok {
+			cr.done = rt.done
+		}
+		if !ranger.ProvidesIndex() {
+			if isSet && len(node.Set.Left) > 1 {
+				// two-vars assignment with ranger that doesn't provide an index
+				cleanup()
+				return l, node.error("", "two-var range over ranger that does not provide an index")
+			} else if isSet {
+				keyVarSlot, valVarSlot = -1, 0
+			}
+		}
+
+		var stop e.Error // set when the execution is canceled or exceeds its limits
+		iteration := newLoopIteration(ranger, rt.iteration)
+		indexValue, rangeValue, end := iteration.next()
+		if !end {
+			rt.iteration = iteration
+			for !end && !l.returnValue.IsValid() {
+				if stop = rt.canceled(node); stop != nil {
+					break
+				}
+				if stop = rt.limits.iterate(node); stop != nil {
+					break
+				}
+				if isSet {
+					if isLet {
+						if keyVarSlot >= 0 {
+							rt.variables[node.Set.Left[keyVarSlot].String()] = indexValue
+						}
+						if valVarSlot >= 0 {
+							rt.variables[node.Set.Left[valVarSlot].String()] = rangeValue
+						}
+					} else {
+						if keyVarSlot >= 0 {
+							rt.executeSet(node.Set.Left[keyVarSlot], indexValue)
+						}
+						if valVarSlot >= 0 {
+							rt.executeSet(node.Set.Left[valVarSlot], rangeValue)
+						}
+					}
+				}
+				if valVarSlot < 0 {
+					rt.context = rangeValue
+				}
+				l.returnValue, _ = list(rt)
+				if control := rt.loop; control != loopNone {
+					rt.loop = loopNone
+					if control == loopBreak {
+						break
+					}
+				}
+				indexValue, rangeValue, end = iteration.next()
+			}
+			rt.iteration = iteration.parent
+		} else if stop = rt.canceled(node); stop == nil && elseList != nil {
+			// a channel range that ends because the context is done is not empty
+			l.returnValue, _ = elseList(rt)
+		}
+		if stop == nil {
+			// a channel range ends early when the context is done
+			stop = rt.canceled(node)
+		}
+		cleanup()
+		rt.context = context
+		if isLet {
+			rt.releaseScope()
+		}
+		if stop != nil {
+			return l, stop
+		}
+		return l, nil
+	}
+}
+
+// pipeline compiles a pipeline like evalPipelineExpression evaluates it.
+func (c *closureCompiler) pipeline(node *PipeNode) pipeFunc {
+	first := c.command(node.Cmds[0])
+	rest := make([]func(rt *Runtime, value reflect.Value) (reflect.Value, bool, e.Error), len(node.Cmds)-1)
+	for i, cmd := range node.Cmds[1:] {
+		rest[i] = c.pipeCommand(cmd)
+	}
+	return func(rt *Runtime) (reflect.Value, bool, e.Error) {
+		value, safeWriter, err := first(rt)
+		if err != nil {
+			return reflect.Value{}, false, err
+		}
+		for i, cmd := range rest {
+			if safeWriter {
+				next := node.Cmds[i+1]
+				return reflect.Value{}, false, next.error(e.UnexpectedCommandReason, fmt.Sprintf("unexpected command %s, writer command should be the last command", next))
+			}
+			value, safeWriter, err = cmd(rt, value)
+			if err != nil {
+				return reflect.Value{}, false, err
+			}
+		}
+		return value, safeWriter, err
+	}
+}
+
+// command compiles the first command of a pipeline like evalCommandExpression evaluates it.
+func (c *closureCompiler) command(node *CommandNode) pipeFunc {
+	base := c.expr(node.BaseExpr)
+	if node.Exprs == nil {
+		return func(rt *Runtime) (reflect.Value, bool, e.Error) {
+			term, err := base(rt)
+			return term, false, err
+		}
+	}
+	return func(rt *Runtime) (reflect.Value, bool, e.Error) {
+		term, err := base(rt)
+		if err != nil {
+			return reflect.Value{}, false, err
+		}
+		if !term.IsValid() {
+			return term, false, nil
+		}
+		if term.Kind() == reflect.Func {
+			if term.Type() == safeWriterType {
+				return reflect.Value{}, true, rt.evalSafeWriter(term, node)
+			}
+			ret, err := rt.evalCallExpression(term, node.CallArgs)
+			if err != nil {
+				return reflect.Value{}, false, node.BaseExpr.error("", err.Error())
+			}
+			return ret, false, nil
+		}
+		return reflect.Value{}, false, node.Exprs[0].error("", fmt.Sprintf("command %q has arguments but is %s, not a function", node.Exprs[0], term.Type()))
+	}
+}
+
+// pipeCommand compiles a piped command like evalCommandPipeExpression evaluates it.
+func (c *closureCompiler) pipeCommand(node *CommandNode) func(rt *Runtime, value reflect.Value) (reflect.Value, bool, e.Error) {
+	base := c.expr(node.BaseExpr)
+	return func(rt *Runtime, value reflect.Value) (reflect.Value, bool, e.Error) {
+		if node.filter.IsValid() {
+			ret, err := rt.evalFilter(node, value)
+			return ret, false, err
+		}
+		term, err := base(rt)
+		if err != nil {
+			return reflect.Value{}, false, err
+		}
+		if !term.IsValid() {
+			return reflect.Value{}, false, node.error(e.InvalidValueReason, "base expression of command pipe node is invalid value")
+		}
+		if term.Kind() != reflect.Func {
+			return reflect.Value{}, false, node.BaseExpr.error("", fmt.Sprintf("pipe command %q must be a function, but is %s", node.BaseExpr, term.Type()))
+		}
+		if term.Type() == safeWriterType {
+			return reflect.Value{}, true, rt.evalSafeWriter(term, node, value)
+		}
+		ret, err := rt.evalPipeCallExpression(term, node.CallArgs, &value)
+		if err != nil {
+			return reflect.Value{}, false, node.BaseExpr.error("", err.Error())
+		}
+		return ret, false, nil
+	}
+}
+
+// constant returns an exprFunc always returning v. The returned flag marks constants for folding.
+func constant(v reflect.Value) (exprFunc, bool) {
+	return func(*Runtime) (reflect.Value, e.Error) { return v, nil }, true
+}
+
+// expr compiles an expression like evalPrimaryExpressionGroup evaluates it.
+func (c *closureCompiler) expr(node Expression) exprFunc {
+	fn, _ := c.compileExpr(node)
+	return fn
+}
+
+// compileExpr compiles an expression and reports whether its value is constant. Operations on constants are
+// evaluated at compile time, unless they fail: failures are left to happen at runtime, with the same error.
+func (c *closureCompiler) compileExpr(node Expression) (exprFunc, bool) {
+	switch node := node.(type) {
+	case *AdditiveExprNode:
+		if node.Left == nil {
+			right, isConst := c.compileExpr(node.Right)
+			fn := func(rt *Runtime) (reflect.Value, e.Error) {
+				value, err := right(rt)
+				if err != nil {
+					return reflect.Value{}, err
+				}
+				return unaryAddition(node, value)
+			}
+			return fold(fn, isConst)
+		}
+		left, leftConst := c.compileExpr(node.Left)
+		right, rightConst := c.compileExpr(node.Right)
+		return fold(binaryOp(left, right, func(l, r reflect.Value) (reflect.Value, e.Error) {
+			return addition(node, l, r)
+		}), leftConst && rightConst)
+	case *MultiplicativeExprNode:
+		left, leftConst := c.compileExpr(node.Left)
+		right, rightConst := c.compileExpr(node.Right)
+		return fold(binaryOp(left, right, func(l, r reflect.Value) (reflect.Value, e.Error) {
+			return multiplication(node, l, r)
+		}), leftConst && rightConst)
+	case *ComparativeExprNode:
+		left, leftConst := c.compileExpr(node.Left)
+		right, rightConst := c.compileExpr(node.Right)
+		return fold(binaryOp(left, right, func(l, r reflect.Value) (reflect.Value, e.Error) {
+			return comparison(node, l, r), nil
+		}), leftConst && rightConst)
+	case *NumericComparativeExprNode:
+		left, leftConst := c.compileExpr(node.Left)
+		right, rightConst := c.compileExpr(node.Right)
+		return fold(binaryOp(left, right, func(l, r reflect.Value) (reflect.Value, e.Error) {
+			return numericComparison(node, l, r)
+		}), leftConst && rightConst)
+	case *LogicalExprNode:
+		left, leftConst := c.compileExpr(node.Left)
+		right, rightConst := c.compileExpr(node.Right)
+		return fold(binaryOp(left, right, func(l, r reflect.Value) (reflect.Value, e.Error) {
+			return logical(node, isTrue(l), r), nil
+		}), leftConst && rightConst)
+	case *CoalesceExprNode:
+		// the left side is looked up like isset does, by the interpreter
+		right := c.expr(node.Right)
+		return func(rt *Runtime) (reflect.Value, e.Error) {
+			left, err := rt.evalIfSet(node.Left)
+			if err != nil || left.IsValid() {
+				return left, err
+			}
+			return right(rt)
+		}, false
+	case *NotExprNode:
+		expr, isConst := c.compileExpr(node.Expr)
+		return fold(func(rt *Runtime) (reflect.Value, e.Error) {
+			value, err := expr(rt)
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			return reflect.ValueOf(!isTrue(value)), nil
+		}, isConst)
+	case *TernaryExprNode:
+		boolean := c.expr(node.Boolean)
+		left := c.expr(node.Left)
+		right := c.expr(node.Right)
+		return func(rt *Runtime) (reflect.Value, e.Error) {
+			value, err := boolean(rt)
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			if isTrue(value) {
+				return left(rt)
+			}
+			return right(rt)
+		}, false
+	case *CallExprNode:
+		base := c.base(node.BaseExpr)
+		return func(rt *Runtime) (reflect.Value, e.Error) {
+			baseExpr, err := base(rt)
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			if baseExpr.Kind() != reflect.Func {
+				return reflect.Value{}, node.error("invalid.node", fmt.Sprintf("node %q is not func kind %q", node.BaseExpr, baseExpr.Type()))
+			}
+			ret, err := rt.evalCallExpression(baseExpr, node.CallArgs)
+			if err != nil {
+				return reflect.Value{}, node.error("", err.Error())
+			}
+			return ret, nil
+		}, false
+	case *IndexExprNode:
+		base := c.expr(node.Base)
+		index := c.expr(node.Index)
+		return func(rt *Runtime) (reflect.Value, e.Error) {
+			baseValue, err := base(rt)
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			indexValue, err := index(rt)
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			return rt.indexOf(node, baseValue, indexValue)
+		}, false
+	case *SliceExprNode:
+		base := c.expr(node.Base)
+		var index, end exprFunc
+		if node.Index != nil {
+			index = c.expr(node.Index)
+		}
+		if node.EndIndex != nil {
+			end = c.expr(node.EndIndex)
+		}
+		return func(rt *Runtime) (reflect.Value, e.Error) {
+			baseValue, err := base(rt)
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			var from, to int
+			if index != nil {
+				value, err := index(rt)
+				if err != nil {
+					return reflect.Value{}, err
+				}
+				if from, err = sliceBound(node.Index, value); err != nil {
+					return reflect.Value{}, err
+				}
+			}
+			if end != nil {
+				value, err := end(rt)
+				if err != nil {
+					return reflect.Value{}, err
+				}
+				if to, err = sliceBound(node.EndIndex, value); err != nil {
+					return reflect.Value{}, err
+				}
+			} else {
+				to = baseValue.Len()
+			}
+			return baseValue.Slice(from, to), nil
+		}, false
+	}
+	return c.compileBase(node)
+}
+
+// base compiles an expression like evalBaseExpressionGroup evaluates it.
+func (c *closureCompiler) base(node Node) exprFunc {
+	fn, _ := c.compileBase(node)
+	return fn
+}
+
+func (c *closureCompiler) compileBase(node Node) (exprFunc, bool) {
+	switch node := node.(type) {
+	case *NilNode:
+		return constant(reflect.ValueOf(nil))
+	case *BoolNode:
+		if node.True {
+			return constant(valueBoolTRUE)
+		}
+		return constant(valueBoolFALSE)
+	case *StringNode:
+		return constant(reflect.ValueOf(&node.Text).Elem())
+	case *IdentifierNode:
+		return func(rt *Runtime) (reflect.Value, e.Error) {
+			val, err := rt.resolve(node.Ident)
+			if err != nil {
+				return rt.missingKey(node.error(err.Reason(), err.Message()))
+			}
+			return val, nil
+		}, false
+	case *FieldNode:
+		return func(rt *Runtime) (reflect.Value, e.Error) {
+			return rt.evalBaseExpressionGroup(node)
+		}, false
+	case *ChainNode:
+		inner := c.expr(node.Node)
+		names := make([]reflect.Value, len(node.Field))
+		for i, field := range node.Field {
+			names[i] = reflect.ValueOf(field.name)
+		}
+		return func(rt *Runtime) (reflect.Value, e.Error) {
+			resolved, err := inner(rt)
+			if err == nil {
+				resolved, err = rt.chainFields(node, resolved, names)
+			}
+			if err != nil {
+				return reflect.Value{}, node.error(err.Reason(), err.Message())
+			}
+			return resolved, nil
+		}, false
+	case *NumberNode:
+		if node.IsFloat {
+			return constant(reflect.ValueOf(&node.Float64).Elem())
+		}
+		if node.IsInt {
+			return constant(reflect.ValueOf(&node.Int64).Elem())
+		}
+		if node.IsUint {
+			return constant(reflect.ValueOf(&node.Uint64).Elem())
+		}
+	}
+	return func(rt *Runtime) (reflect.Value, e.Error) {
+		return rt.evalBaseExpressionGroup(node)
+	}, false
+}
+
+// binaryOp returns an exprFunc evaluating both operands, then combining them.
+func binaryOp(left, right exprFunc, combine func(l, r reflect.Value) (reflect.Value, e.Error)) exprFunc {
+	return func(rt *Runtime) (reflect.Value, e.Error) {
+		l, err := left(rt)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		r, err := right(rt)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		return combine(l, r)
+	}
+}
+
+// fold evaluates fn at compile time when its operands are constant and the evaluation succeeds.
+func fold(fn exprFunc, isConst bool) (exprFunc, bool) {
+	if !isConst {
+		return fn, false
+	}
+	value, err := evalConstant(fn)
+	if err != nil {
+		return fn, false
+	}
+	return constant(value)
+}
+
+// evalConstant runs fn, which doesn't use the runtime, turning panics into errors.
+func evalConstant(fn exprFunc) (value reflect.Value, err e.Error) {
+	defer func() {
+		if recover() != nil {
+			err = e.New()
+		}
+	}()
+	return fn(nil)
+}
@@ -0,0 +1,185 @@
+package jet
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+type closureUser struct {
+	Name  string
+	Tags  []string
+	Admin bool
+}
+
+func (u closureUser) Greet(greeting string) string { return greeting + ", " + u.Name }
+
+// closureTemplates covers every kind of node, each template being rendered by both execution engines.
+var closureTemplates = map[string]string{
+	"/text.jet":       `plain text`,
+	"/action.jet":     `{{ .Name }} {{ .Greet("hi") }} {{ 1 + 2 * 3 }} {{ "a" + "b" }} {{ -n }} {{ !.Admin }} {{ n > 1 ? "big" : "small" }} {{ n == 2 && .Admin || true }}`,
+	"/escape.jet":     `{{ "<b>" }}{{ "<i>" | raw }}{{ raw: "<u>" }}{{ "x y" | url }}{{ "ab" | repeat(_, 2) }}`,
+	"/let.jet":        `{{ a := 1 }}{{ b := a + 1 }}{{ a = 5 }}{{ a }}{{ b }}{{ m := map("k", 1) }}{{ m["k"] }}{{ v, ok := m["z"] }}{{ ok }}`,
+	"/index.jet":      `{{ .Tags[0] }}{{ .Tags[1:] }}{{ len(.Tags) }}{{ s := slice(1, 2, 3) }}{{ s[:2] }}{{ .Tags?[9] }}`,
+	"/if.jet":         `{{ if n > 5 }}a{{ else if n == 2 }}b{{ else }}c{{ end }}{{ if x := n; x }}{{ x }}{{ end }}`,
+	"/range.jet":      `{{ range i, t := .Tags }}{{ i }}={{ t }};{{ end }}{{ range nothing }}x{{ else }}empty{{ end }}{{ range 3 }}{{ . }}{{ end }}`,
+	"/loopctl.jet":    `{{ range i := 10 }}{{ continue if i % 2 == 0 }}{{ break if i > 6 }}{{ i }}{{ end }}`,
+	"/loopvar.jet":    `{{ range .Tags }}{{ loop.index1 }}/{{ loop.length }}{{ if !loop.last }},{{ end }}{{ end }}`,
+	"/switch.jet":     `{{ switch n }}{{ case 1, 2 }}low{{ case 3 }}three{{ default }}other{{ end }}{{ switch }}{{ case .Admin }}admin{{ default }}user{{ end }}`,
+	"/try.jet":        `{{ try }}{{ boom() }}{{ catch err }}caught{{ end }}{{ try }}ok{{ end }}`,
+	"/coalesce.jet":   `{{ nil ?? "nil" }}{{ missing ?? "dflt" }}{{ .Tags?[5] ?? "none" }}{{ n ?? 0 }}`,
+	"/filters.jet":    `{{ .Name | upper | truncate: 2 }}{{ "" | default("n/a") }}{{ .Name | wrap: "[", "]" }}`,
+	"/isset.jet":      `{{ isset(missing) }}{{ isset(n) }}{{ isset(.Name) }}`,
+	"/blocks.jet":     `{{ block box(title="t") }}<{{ title }}:{{ yield content }}>{{ end }}{{ yield box(title="u") content }}inner{{ end }}`,
+	"/extends.jet":    `{{ extends "./layout.jet" }}{{ block body() }}child {{ .Name }}{{ end }}`,
+	"/layout.jet":     `[{{ block body() }}base{{ end }}]`,
+	"/import.jet":     `{{ import "./lib.jet" }}{{ yield item(v=1) }}{{ yield item(v=2) }}`,
+	"/lib.jet":        `{{ block item(v=0) }}({{ v }}){{ end }}`,
+	"/include.jet":    `{{ include "./partial.jet" }}|{{ include "./partial.jet" "ctx" }}`,
+	"/partial.jet":    `partial {{ . }}`,
+	"/exec.jet":       `{{ exec("./return.jet") }}{{ r := exec("./return.jet") }}{{ r }}`,
+	"/return.jet":     `{{ return "returned" }}`,
+	"/trans.jet":      `{{ trans "hello" .Name }}{{ msg "bye" }}bye {{ .Name }}{{ end }}`,
+	"/whitespace.jet": "a  {{- 1 -}}  b\n{{ \"c\" }}",
+}
+
+func newClosureSet(closures bool) *Set {
+	loader := NewInMemLoader()
+	for path, text := range closureTemplates {
+		loader.Set(path, text)
+	}
+	var opts []Option
+	if closures {
+		opts = append(opts, WithClosureCompilation())
+	}
+	s := NewSet(loader, opts...)
+	s.AddGlobal("boom", func() string { panic(errors.New("boom")) })
+	s.RegisterFilter("wrap", func(v, left, right string) string { return left + v + right })
+	return s
+}
+
+func renderBoth(t *testing.T, path string, vars VarMap, context any) (interpreted, compiled string) {
+	t.Helper()
+	var outputs [2]string
+	for i, closures := range []bool{false, true} {
+		tmpl, err := newClosureSet(closures).GetTemplate(path)
+		if err != nil {
+			t.Fatalf("%s (closures %v): %v", path, closures, err)
+		}
+		var out strings.Builder
+		if err := tmpl.Execute(&out, vars, context); err != nil {
+			outputs[i] = "error: " + err.Error()
+		} else {
+			outputs[i] = out.String()
+		}
+	}
+	return outputs[0], outputs[1]
+}
+
+func TestClosures(t *testing.T) {
+	tests := map[string]string{
+		"/text.jet":       "plain text",
+		"/action.jet":     "ann hi, ann 7 ab -2 false big true",
+		"/escape.jet":     "&lt;b&gt;<i><u>x+yabab",
+		"/let.jet":        "521false",
+		"/index.jet":      "a[b]2[1 2]",
+		"/if.jet":         "b2",
+		"/range.jet":      "0=a;1=b;empty012",
+		"/loopctl.jet":    "135",
+		"/loopvar.jet":    "1/2,2/2",
+		"/switch.jet":     "lowadmin",
+		"/try.jet":        "caughtok",
+		"/coalesce.jet":   "nildfltnone2",
+		"/filters.jet":    "AN...n/a[ann]",
+		"/isset.jet":      "falsetruetrue",
+		"/blocks.jet":     "<t:><u:inner>",
+		"/extends.jet":    "[child ann]",
+		"/layout.jet":     "[base]",
+		"/import.jet":     "(1)(2)",
+		"/lib.jet":        "(0)",
+		"/include.jet":    "partial {ann [a b] true}|partial ctx",
+		"/partial.jet":    "partial {ann [a b] true}",
+		"/exec.jet":       "returnedreturned",
+		"/return.jet":     "",
+		"/trans.jet":      "hellobye ann",
+		"/whitespace.jet": "a1b\nc",
+	}
+	vars := VarMap{}.Set("n", 2).Set("nothing", []int{})
+	user := closureUser{Name: "ann", Tags: []string{"a", "b"}, Admin: true}
+	for path := range closureTemplates {
+		want, ok := tests[path]
+		if !ok {
+			t.Errorf("%s: no expected output", path)
+			continue
+		}
+		interpreted, compiled := renderBoth(t, path, vars, user)
+		if interpreted != want {
+			t.Errorf("%s: the interpreter rendered %q, want %q", path, interpreted, want)
+		}
+		if compiled != want {
+			t.Errorf("%s: closures rendered %q, want %q", path, compiled, want)
+		}
+	}
+}
+
+func TestClosuresErrors(t *testing.T) {
+	tests := []struct {
+		template, want string
+	}{
+		{`{{ missing }}`, `not_available.identifier identifier "missing" not available in current (map[]) or parent scope, global, or default variables`},
+		{`{{ .Nope }}`, `invalid.index can't use 'Nope' as field name in struct type jet.closureUser`},
+		{`{{ upper("a", "b") }}`, `jet.runtime.error invalid.call call expression: invalid.number_of_arguments func(string) string needs at least 1 arguments, but have 2`},
+		{`{{ range 1.5 }}{{ end }}`, `jet.runtime.error cannot range over 1.5 (type float64): not an integer`},
+	}
+	for _, closures := range []bool{false, true} {
+		s := newClosureSet(closures)
+		for _, tt := range tests {
+			tmpl, err := s.parseString(tt.template)
+			if err != nil {
+				t.Fatalf("%s: %v", tt.template, err)
+			}
+			err = tmpl.Execute(&strings.Builder{}, nil, closureUser{})
+			if err == nil || err.Error() != tt.want {
+				t.Errorf("closures %v, %s: got %v, want %s", closures, tt.template, err, tt.want)
+			}
+		}
+	}
+}
+
+// unknownNode is a node the closure compiler doesn't know.
+type unknownNode struct {
+	NodeBase
+}
+
+func (n *unknownNode) String() string { return "unknown" }
+
+func TestClosuresRejectUnknownNodes(t *testing.T) {
+	tmpl := &Template{Name: "/unknown.jet", Root: &ListNode{NodeBase: NodeBase{NodeType: NodeList}, Nodes: []Node{&unknownNode{}}}}
+	if err := tmpl.compileClosures(); err == nil {
+		t.Error("compileClosures accepted an unknown node")
+	}
+}
+
+func BenchmarkExecute(b *testing.B) {
+	vars := VarMap{}.Set("n", 2).Set("nothing", []int{})
+	user := closureUser{Name: "ann", Tags: []string{"a", "b", "c", "d"}, Admin: true}
+	for _, engine := range []struct {
+		name     string
+		closures bool
+	}{{"interpreter", false}, {"closures", true}} {
+		b.Run(engine.name, func(b *testing.B) {
+			tmpl, err := newClosureSet(engine.closures).GetTemplate("/action.jet")
+			if err != nil {
+				b.Fatal(err)
+			}
+			var out strings.Builder
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				out.Reset()
+				if err := tmpl.Execute(&out, vars, user); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
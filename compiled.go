@@ -0,0 +1,964 @@
+package jet
+
+import (
+	"bufio"
+	"encoding"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"sort"
+)
+
+// compiledMagic starts every compiled template; compiledVersion changes whenever the encoding of the tree does, so
+// that templates compiled by another version of Jet are rejected (and, in a DiskCache, never looked up).
+const (
+	compiledMagic   = "JETC"
+	compiledVersion = 4
+
+	bundleMagic = "JETB"
+)
+
+var errCompiledFormat = errors.New("jet: invalid compiled template")
+
+// MarshalBinary implements encoding.BinaryMarshaler. It encodes the parsed tree of the template together with the
+// names of the templates it extends and imports; UnmarshalBinary decodes it without parsing.
+func (t *Template) MarshalBinary() ([]byte, error) {
+	enc := &encoder{strings: map[string]uint64{}, blocks: map[*BlockNode]uint64{}}
+	enc.buf = append(enc.buf, compiledMagic...)
+	enc.uint(compiledVersion)
+
+	enc.string(t.Name)
+	enc.string(t.ParseName)
+	enc.string(t.text)
+	enc.string(t.version)
+	if t.extends != nil {
+		enc.bool(true)
+		enc.string(t.extends.Name)
+	} else {
+		enc.bool(false)
+	}
+	enc.uint(uint64(len(t.imports)))
+	for _, _import := range t.imports {
+		enc.string(_import.Name)
+	}
+	enc.uint(uint64(len(t.dependencies)))
+	for _, dep := range t.dependencies {
+		enc.string(dep.Path)
+		enc.uint(uint64(dep.Kind))
+		enc.int(int64(dep.Line))
+	}
+	enc.uint(uint64(len(t.placeholders)))
+	for _, p := range t.placeholders {
+		enc.string(p.Path)
+		enc.uint(uint64(p.Root))
+		enc.string(p.Range)
+		enc.stringList(p.Funcs)
+		enc.int(int64(p.Line))
+	}
+
+	enc.node(t.Root)
+	names := make([]string, 0, len(t.passedBlocks))
+	for name := range t.passedBlocks {
+		names = append(names, name)
+	}
+	sort.Strings(names) // keep the output reproducible
+	enc.uint(uint64(len(names)))
+	for _, name := range names {
+		enc.string(name)
+		enc.node(t.passedBlocks[name])
+	}
+	if enc.err != nil {
+		return nil, enc.err
+	}
+	return enc.buf, nil
+}
+
+// compile time checks that Template implements the encoding interfaces
+var (
+	_ encoding.BinaryMarshaler   = (*Template)(nil)
+	_ encoding.BinaryUnmarshaler = (*Template)(nil)
+)
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler. It decodes a template encoded by MarshalBinary into t,
+// for the Set t was parsed or decoded with, or the default Set when t is a zero Template; see Set.UnmarshalTemplate
+// to decode for another Set.
+func (t *Template) UnmarshalBinary(data []byte) error {
+	s := t.set
+	if s == nil {
+		s = defaultSet
+	}
+	decoded, err := s.UnmarshalTemplate(data)
+	if err != nil {
+		return err
+	}
+	*t = *decoded
+	return nil
+}
+
+// UnmarshalTemplate decodes a template encoded by Template.MarshalBinary for s. The templates it extends or imports
+// are taken from the Set like GetTemplate does, so they must be in the cache (e.g. loaded first by LoadCompiled) or
+// be available from the Set's Loader.
+func (s *Set) UnmarshalTemplate(data []byte) (*Template, error) {
+	d := &decoder{set: s, data: data}
+	if len(data) < len(compiledMagic) || string(data[:len(compiledMagic)]) != compiledMagic {
+		return nil, errCompiledFormat
+	}
+	d.off = len(compiledMagic)
+	if version := d.uint(); version != compiledVersion {
+		return nil, fmt.Errorf("jet: compiled template has version %d, expected %d", version, compiledVersion)
+	}
+
+	t := &Template{set: s}
+	t.Name = d.string()
+	t.ParseName = d.string()
+	t.text = d.string()
+	t.version = d.string()
+	if d.bool() {
+		name := d.string()
+		if d.err != nil {
+			return nil, d.err
+		}
+		extends, err := s.getSiblingTemplate(name, "/", true)
+		if err != nil {
+			return nil, err
+		}
+		t.extends = extends
+	}
+	for i, n := 0, d.count(); i < n; i++ {
+		name := d.string()
+		if d.err != nil {
+			return nil, d.err
+		}
+		_import, err := s.getSiblingTemplate(name, "/", true)
+		if err != nil {
+			return nil, err
+		}
+		t.imports = append(t.imports, _import)
+	}
+	for i, n := 0, d.count(); i < n; i++ {
+		t.dependencies = append(t.dependencies, Dependency{Path: d.string(), Kind: DependencyKind(d.uint()), Line: int(d.int())})
+	}
+	for i, n := 0, d.count(); i < n; i++ {
+		t.placeholders = append(t.placeholders, Placeholder{Path: d.string(), Root: PlaceholderRoot(d.uint()), Range: d.string(), Funcs: d.stringList(), Line: int(d.int())})
+	}
+
+	t.Root = decodeNode[*ListNode](d)
+	n := d.count()
+	t.passedBlocks = make(map[string]*BlockNode, n)
+	for i := 0; i < n; i++ {
+		name := d.string()
+		t.passedBlocks[name] = decodeNode[*BlockNode](d)
+	}
+	if d.err != nil {
+		return nil, d.err
+	}
+	if t.Root == nil || d.off != len(d.data) {
+		return nil, errCompiledFormat
+	}
+	if s.closures {
+		if err := t.compileClosures(); err != nil {
+			return nil, err
+		}
+	}
+
+	if t.extends != nil {
+		t.addBlocks(t.extends.processedBlocks)
+	}
+	for _, _import := range t.imports {
+		t.addBlocks(_import.processedBlocks)
+	}
+	t.addBlocks(t.passedBlocks)
+	return t, nil
+}
+
+// Compile writes the templates at templatePaths, and all the templates they depend on (see Template.Dependencies),
+// in the format read by LoadCompiled. Dependencies are written before the templates using them.
+func (s *Set) Compile(w io.Writer, templatePaths ...string) error {
+	bw := bufio.NewWriter(w)
+	if _, err := bw.WriteString(bundleMagic); err != nil {
+		return err
+	}
+	seen := map[string]bool{}
+	var write func(t *Template) error
+	write = func(t *Template) error {
+		if seen[t.Name] {
+			return nil
+		}
+		seen[t.Name] = true
+		for _, dep := range t.dependencies {
+			tt, err := s.GetTemplate(dep.Path)
+			if err != nil {
+				return fmt.Errorf("%s:%d: %s: %w", t.Name, dep.Line, dep.Kind, err)
+			}
+			if err := write(tt); err != nil {
+				return err
+			}
+		}
+		data, err := t.MarshalBinary()
+		if err != nil {
+			return err
+		}
+		bw.Write(binary.AppendUvarint(nil, uint64(len(data))))
+		_, err = bw.Write(data)
+		return err
+	}
+	for _, templatePath := range templatePaths {
+		t, err := s.GetTemplate(templatePath)
+		if err != nil {
+			return err
+		}
+		if err := write(t); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// LoadCompiled reads templates written by Compile and puts them in the cache, so that GetTemplate finds them
+// without parsing. It has no effect on later lookups in development mode, which bypasses the cache.
+func (s *Set) LoadCompiled(r io.Reader) error {
+	br := bufio.NewReader(r)
+	magic := make([]byte, len(bundleMagic))
+	if _, err := io.ReadFull(br, magic); err != nil || string(magic) != bundleMagic {
+		return errCompiledFormat
+	}
+	for {
+		size, err := binary.ReadUvarint(br)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		data := make([]byte, size)
+		if _, err := io.ReadFull(br, data); err != nil {
+			return err
+		}
+		t, err := s.UnmarshalTemplate(data)
+		if err != nil {
+			return err
+		}
+		s.cache.Put(t.Name, t)
+		s.dependents.add(t.Name, t)
+	}
+}
+
+// encoder writes a tree in the compiled format: varints, length-prefixed strings interned in a table, and nodes
+// tagged by their NodeType.
+type encoder struct {
+	buf     []byte
+	strings map[string]uint64
+	blocks  map[*BlockNode]uint64
+	err     error
+}
+
+func (enc *encoder) uint(v uint64) {
+	enc.buf = binary.AppendUvarint(enc.buf, v)
+}
+
+func (enc *encoder) int(v int64) {
+	enc.buf = binary.AppendVarint(enc.buf, v)
+}
+
+func (enc *encoder) bool(b bool) {
+	if b {
+		enc.buf = append(enc.buf, 1)
+	} else {
+		enc.buf = append(enc.buf, 0)
+	}
+}
+
+// string writes 0 followed by the string the first time it is written, and its position in the table plus one
+// afterwards.
+func (enc *encoder) string(s string) {
+	if i, ok := enc.strings[s]; ok {
+		enc.uint(i + 1)
+		return
+	}
+	enc.strings[s] = uint64(len(enc.strings))
+	enc.uint(0)
+	enc.bytes([]byte(s))
+}
+
+func (enc *encoder) stringList(list []string) {
+	enc.uint(uint64(len(list)))
+	for _, s := range list {
+		enc.string(s)
+	}
+}
+
+func (enc *encoder) bytes(b []byte) {
+	enc.uint(uint64(len(b)))
+	enc.buf = append(enc.buf, b...)
+}
+
+func (enc *encoder) base(n *NodeBase) {
+	enc.string(n.TemplatePath)
+	enc.int(int64(n.Line))
+	enc.uint(uint64(n.NodeType))
+	enc.int(int64(n.Pos))
+}
+
+func (enc *encoder) idents(idents Idents) {
+	enc.uint(uint64(len(idents)))
+	for _, id := range idents {
+		enc.string(id.name)
+		enc.bool(id.lax)
+	}
+}
+
+// nodes writes the length of nodes plus one, or 0 if nodes is nil, since a call without arguments is told apart
+// from a command by a non-nil, empty argument list.
+func (enc *encoder) nodes(nodes []Expression) {
+	if nodes == nil {
+		enc.uint(0)
+		return
+	}
+	enc.uint(uint64(len(nodes)) + 1)
+	for _, n := range nodes {
+		enc.node(n)
+	}
+}
+
+func (enc *encoder) callExpr(n *CallExprNode) {
+	enc.base(&n.NodeBase)
+	enc.node(n.BaseExpr)
+	enc.nodes(n.Exprs)
+	enc.bool(n.HasPipeSlot)
+}
+
+func (enc *encoder) branch(n *BranchNode) {
+	enc.base(&n.NodeBase)
+	enc.node(n.Set)
+	enc.node(n.Expression)
+	enc.node(n.List)
+	enc.node(n.ElseList)
+}
+
+func (enc *encoder) binaryExpr(n *binaryExprNode) {
+	enc.base(&n.NodeBase)
+	enc.uint(uint64(n.Operator.typ))
+	enc.int(int64(n.Operator.pos))
+	enc.string(n.Operator.val)
+	enc.node(n.Left)
+	enc.node(n.Right)
+}
+
+func (enc *encoder) parameters(n *BlockParameterList) {
+	if n == nil {
+		enc.bool(false)
+		return
+	}
+	enc.bool(true)
+	enc.base(&n.NodeBase)
+	enc.uint(uint64(len(n.List)))
+	for _, p := range n.List {
+		enc.string(p.Identifier)
+		enc.node(p.Expression)
+	}
+}
+
+// node writes the tag of n, 0 for nil or NodeType+1, followed by its fields.
+func (enc *encoder) node(n Node) {
+	if n == nil || reflect.ValueOf(n).IsNil() {
+		enc.uint(0)
+		return
+	}
+	enc.uint(uint64(n.Type()) + 1)
+	switch n := n.(type) {
+	case *ListNode:
+		enc.base(&n.NodeBase)
+		enc.uint(uint64(len(n.Nodes)))
+		for _, child := range n.Nodes {
+			enc.node(child)
+		}
+	case *TextNode:
+		enc.base(&n.NodeBase)
+		enc.bytes(n.Text)
+	case *PipeNode:
+		enc.base(&n.NodeBase)
+		enc.uint(uint64(len(n.Cmds)))
+		for _, cmd := range n.Cmds {
+			enc.node(cmd)
+		}
+	case *ActionNode:
+		enc.base(&n.NodeBase)
+		enc.node(n.Set)
+		enc.node(n.Pipe)
+		enc.string(n.src)
+	case *CommandNode:
+		enc.base(&n.NodeBase)
+		enc.callExpr(&n.CallExprNode)
+		enc.bool(n.Colon)
+		enc.bool(n.filter.IsValid())
+	case *IdentifierNode:
+		enc.base(&n.NodeBase)
+		enc.string(n.Ident)
+	case *UnderscoreNode:
+		enc.base(&n.NodeBase)
+	case *NilNode:
+		enc.base(&n.NodeBase)
+	case *FieldNode:
+		enc.base(&n.NodeBase)
+		enc.idents(n.Idents)
+	case *ChainNode:
+		enc.base(&n.NodeBase)
+		enc.node(n.Node)
+		enc.idents(n.Field)
+	case *BoolNode:
+		enc.base(&n.NodeBase)
+		enc.bool(n.True)
+	case *NumberNode:
+		enc.base(&n.NodeBase)
+		enc.bool(n.IsInt)
+		enc.bool(n.IsUint)
+		enc.bool(n.IsFloat)
+		enc.bool(n.IsComplex)
+		enc.int(n.Int64)
+		enc.uint(n.Uint64)
+		enc.uint(math.Float64bits(n.Float64))
+		enc.uint(math.Float64bits(real(n.Complex128)))
+		enc.uint(math.Float64bits(imag(n.Complex128)))
+		enc.string(n.Text)
+	case *StringNode:
+		enc.base(&n.NodeBase)
+		enc.string(n.Quoted)
+		enc.string(n.Text)
+	case *SetNode:
+		enc.base(&n.NodeBase)
+		enc.bool(n.Let)
+		enc.bool(n.IndexExprGetLookup)
+		enc.nodes(n.Left)
+		enc.nodes(n.Right)
+	case *IfNode:
+		enc.branch(&n.BranchNode)
+	case *RangeNode:
+		enc.branch(&n.BranchNode)
+	case *BlockNode:
+		// blocks are referenced from the tree and from the template's blocks: write each one once
+		if i, ok := enc.blocks[n]; ok {
+			enc.uint(i + 1)
+			return
+		}
+		enc.blocks[n] = uint64(len(enc.blocks))
+		enc.uint(0)
+		enc.base(&n.NodeBase)
+		enc.string(n.Name)
+		enc.parameters(n.Parameters)
+		enc.node(n.Expression)
+		enc.node(n.List)
+		enc.node(n.Content)
+	case *YieldNode:
+		enc.base(&n.NodeBase)
+		enc.string(n.Name)
+		enc.parameters(n.Parameters)
+		enc.node(n.Expression)
+		enc.node(n.Content)
+		enc.bool(n.IsContent)
+	case *IncludeNode:
+		enc.base(&n.NodeBase)
+		enc.node(n.Name)
+		enc.node(n.Context)
+	case *AdditiveExprNode:
+		enc.binaryExpr(&n.binaryExprNode)
+	case *MultiplicativeExprNode:
+		enc.binaryExpr(&n.binaryExprNode)
+	case *ComparativeExprNode:
+		enc.binaryExpr(&n.binaryExprNode)
+	case *NumericComparativeExprNode:
+		enc.binaryExpr(&n.binaryExprNode)
+	case *LogicalExprNode:
+		enc.binaryExpr(&n.binaryExprNode)
+	case *CoalesceExprNode:
+		enc.binaryExpr(&n.binaryExprNode)
+	case *NotExprNode:
+		enc.base(&n.NodeBase)
+		enc.node(n.Expr)
+	case *CallExprNode:
+		enc.callExpr(n)
+	case *TernaryExprNode:
+		enc.base(&n.NodeBase)
+		enc.node(n.Boolean)
+		enc.node(n.Left)
+		enc.node(n.Right)
+	case *IndexExprNode:
+		enc.base(&n.NodeBase)
+		enc.node(n.Base)
+		enc.node(n.Index)
+		enc.bool(n.Nullable)
+	case *SliceExprNode:
+		enc.base(&n.NodeBase)
+		enc.node(n.Base)
+		enc.node(n.Index)
+		enc.node(n.EndIndex)
+	case *ReturnNode:
+		enc.base(&n.NodeBase)
+		enc.node(n.Value)
+	case *TryNode:
+		enc.base(&n.NodeBase)
+		enc.node(n.List)
+		enc.node(n.Catch)
+	case *catchNode:
+		enc.base(&n.NodeBase)
+		enc.node(n.Err)
+		enc.node(n.List)
+	case *TransNode:
+		enc.base(&n.NodeBase)
+		enc.node(n.Key)
+		enc.nodes(n.Args)
+		enc.uint(uint64(len(n.Named)))
+		for _, arg := range n.Named {
+			enc.string(arg.Name)
+			enc.node(arg.Value)
+		}
+		enc.node(n.List)
+	case *SwitchNode:
+		enc.base(&n.NodeBase)
+		enc.node(n.Expression)
+		enc.uint(uint64(len(n.Cases)))
+		for _, c := range n.Cases {
+			enc.node(c)
+		}
+		enc.node(n.Default)
+	case *CaseNode:
+		enc.base(&n.NodeBase)
+		enc.nodes(n.Values)
+		enc.node(n.List)
+	case *LoopControlNode:
+		enc.base(&n.NodeBase)
+		enc.node(n.Condition)
+	default:
+		if enc.err == nil {
+			enc.err = fmt.Errorf("jet: can't encode node of type %T", n)
+		}
+	}
+}
+
+// decoder reads what encoder writes. The first error is kept in err; from then on, reads return zero values.
+type decoder struct {
+	set     *Set // resolves filters
+	data    []byte
+	off     int
+	strings []string
+	blocks  []*BlockNode
+	err     error
+}
+
+// filter resolves the filter applied by n in the Set the template is decoded for, like the parser does.
+func (d *decoder) filter(n *CommandNode) {
+	ident, ok := n.BaseExpr.(*IdentifierNode)
+	if !ok {
+		d.fail()
+		return
+	}
+	if n.filter, ok = d.set.commandFilter(ident.Ident); !ok {
+		d.fail()
+	}
+}
+
+func (d *decoder) fail() {
+	if d.err == nil {
+		d.err = errCompiledFormat
+	}
+}
+
+func (d *decoder) uint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(d.data[d.off:])
+	if n <= 0 {
+		d.fail()
+		return 0
+	}
+	d.off += n
+	return v
+}
+
+func (d *decoder) int() int64 {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Varint(d.data[d.off:])
+	if n <= 0 {
+		d.fail()
+		return 0
+	}
+	d.off += n
+	return v
+}
+
+func (d *decoder) bool() bool {
+	return d.uint() != 0
+}
+
+// count reads a length, which can't exceed the number of bytes left since every element takes at least one.
+func (d *decoder) count() int {
+	n := d.uint()
+	if n > uint64(len(d.data)-d.off) {
+		d.fail()
+		return 0
+	}
+	return int(n)
+}
+
+func (d *decoder) bytes() []byte {
+	n := d.count()
+	if d.err != nil {
+		return nil
+	}
+	b := d.data[d.off : d.off+n : d.off+n]
+	d.off += n
+	return b
+}
+
+func (d *decoder) string() string {
+	i := d.uint()
+	if i > 0 {
+		if i > uint64(len(d.strings)) {
+			d.fail()
+			return ""
+		}
+		return d.strings[i-1]
+	}
+	s := string(d.bytes())
+	if d.err == nil {
+		d.strings = append(d.strings, s)
+	}
+	return s
+}
+
+func (d *decoder) stringList() []string {
+	var list []string
+	for i, n := 0, d.count(); i < n; i++ {
+		list = append(list, d.string())
+	}
+	return list
+}
+
+func (d *decoder) base(n *NodeBase) {
+	n.TemplatePath = d.string()
+	n.Line = int(d.int())
+	n.NodeType = NodeType(d.uint())
+	n.Pos = Pos(d.int())
+}
+
+func (d *decoder) idents() Idents {
+	var idents Idents
+	for i, n := 0, d.count(); i < n; i++ {
+		idents = append(idents, Ident{name: d.string(), lax: d.bool()})
+	}
+	return idents
+}
+
+func (d *decoder) nodes() []Expression {
+	n := d.uint()
+	if n == 0 {
+		return nil
+	}
+	if n-1 > uint64(len(d.data)-d.off) {
+		d.fail()
+		return nil
+	}
+	nodes := make([]Expression, 0, n-1)
+	for i := uint64(1); i < n; i++ {
+		nodes = append(nodes, d.node())
+	}
+	return nodes
+}
+
+func (d *decoder) callExpr(n *CallExprNode) {
+	d.base(&n.NodeBase)
+	n.BaseExpr = d.node()
+	n.Exprs = d.nodes()
+	n.HasPipeSlot = d.bool()
+}
+
+func (d *decoder) branch(n *BranchNode) {
+	d.base(&n.NodeBase)
+	n.Set = decodeNode[*SetNode](d)
+	n.Expression = d.node()
+	n.List = decodeNode[*ListNode](d)
+	n.ElseList = decodeNode[*ListNode](d)
+}
+
+func (d *decoder) binaryExpr(n *binaryExprNode) {
+	d.base(&n.NodeBase)
+	n.Operator = item{typ: itemType(d.uint()), pos: Pos(d.int()), val: d.string()}
+	n.Left = d.node()
+	n.Right = d.node()
+}
+
+func (d *decoder) parameters() *BlockParameterList {
+	if !d.bool() {
+		return nil
+	}
+	n := &BlockParameterList{}
+	d.base(&n.NodeBase)
+	for i, count := 0, d.count(); i < count; i++ {
+		n.List = append(n.List, BlockParameter{Identifier: d.string(), Expression: d.node()})
+	}
+	return n
+}
+
+// decodeNode reads a node of type T, which may be nil.
+func decodeNode[T Node](d *decoder) T {
+	var zero T
+	n := d.node()
+	if n == nil {
+		return zero
+	}
+	t, ok := n.(T)
+	if !ok {
+		d.fail()
+		return zero
+	}
+	return t
+}
+
+func (d *decoder) node() Node {
+	tag := d.uint()
+	if tag == 0 || d.err != nil {
+		return nil
+	}
+	switch NodeType(tag - 1) {
+	case NodeList:
+		n := &ListNode{}
+		d.base(&n.NodeBase)
+		for i, count := 0, d.count(); i < count; i++ {
+			n.Nodes = append(n.Nodes, d.node())
+		}
+		return n
+	case NodeText:
+		n := &TextNode{}
+		d.base(&n.NodeBase)
+		n.Text = d.bytes()
+		return n
+	case NodePipe:
+		n := &PipeNode{}
+		d.base(&n.NodeBase)
+		for i, count := 0, d.count(); i < count; i++ {
+			n.Cmds = append(n.Cmds, decodeNode[*CommandNode](d))
+		}
+		return n
+	case NodeAction:
+		n := &ActionNode{}
+		d.base(&n.NodeBase)
+		n.Set = decodeNode[*SetNode](d)
+		n.Pipe = decodeNode[*PipeNode](d)
+		n.src = d.string()
+		return n
+	case NodeCommand:
+		n := &CommandNode{}
+		d.base(&n.NodeBase)
+		d.callExpr(&n.CallExprNode)
+		n.Colon = d.bool()
+		if d.bool() {
+			d.filter(n)
+		}
+		return n
+	case NodeIdentifier:
+		n := &IdentifierNode{}
+		d.base(&n.NodeBase)
+		n.Ident = d.string()
+		return n
+	case NodeUnderscore:
+		n := &UnderscoreNode{}
+		d.base(&n.NodeBase)
+		return n
+	case NodeNil:
+		n := &NilNode{}
+		d.base(&n.NodeBase)
+		return n
+	case NodeField:
+		n := &FieldNode{}
+		d.base(&n.NodeBase)
+		n.Idents = d.idents()
+		return n
+	case NodeChain:
+		n := &ChainNode{}
+		d.base(&n.NodeBase)
+		n.Node = d.node()
+		n.Field = d.idents()
+		return n
+	case NodeBool:
+		n := &BoolNode{}
+		d.base(&n.NodeBase)
+		n.True = d.bool()
+		return n
+	case NodeNumber:
+		n := &NumberNode{}
+		d.base(&n.NodeBase)
+		n.IsInt = d.bool()
+		n.IsUint = d.bool()
+		n.IsFloat = d.bool()
+		n.IsComplex = d.bool()
+		n.Int64 = d.int()
+		n.Uint64 = d.uint()
+		n.Float64 = math.Float64frombits(d.uint())
+		re := math.Float64frombits(d.uint())
+		n.Complex128 = complex(re, math.Float64frombits(d.uint()))
+		n.Text = d.string()
+		return n
+	case NodeString:
+		n := &StringNode{}
+		d.base(&n.NodeBase)
+		n.Quoted = d.string()
+		n.Text = d.string()
+		return n
+	case NodeSet:
+		n := &SetNode{}
+		d.base(&n.NodeBase)
+		n.Let = d.bool()
+		n.IndexExprGetLookup = d.bool()
+		n.Left = d.nodes()
+		n.Right = d.nodes()
+		return n
+	case NodeIf:
+		n := &IfNode{}
+		d.branch(&n.BranchNode)
+		return n
+	case NodeRange:
+		n := &RangeNode{}
+		d.branch(&n.BranchNode)
+		return n
+	case NodeBlock:
+		if ref := d.uint(); ref > 0 {
+			if ref > uint64(len(d.blocks)) {
+				d.fail()
+				return nil
+			}
+			return d.blocks[ref-1]
+		}
+		n := &BlockNode{}
+		d.blocks = append(d.blocks, n)
+		d.base(&n.NodeBase)
+		n.Name = d.string()
+		n.Parameters = d.parameters()
+		n.Expression = d.node()
+		n.List = decodeNode[*ListNode](d)
+		n.Content = decodeNode[*ListNode](d)
+		return n
+	case NodeYield:
+		n := &YieldNode{}
+		d.base(&n.NodeBase)
+		n.Name = d.string()
+		n.Parameters = d.parameters()
+		n.Expression = d.node()
+		n.Content = decodeNode[*ListNode](d)
+		n.IsContent = d.bool()
+		return n
+	case NodeInclude:
+		n := &IncludeNode{}
+		d.base(&n.NodeBase)
+		n.Name = d.node()
+		n.Context = d.node()
+		return n
+	case NodeAdditiveExpr:
+		n := &AdditiveExprNode{}
+		d.binaryExpr(&n.binaryExprNode)
+		return n
+	case NodeMultiplicativeExpr:
+		n := &MultiplicativeExprNode{}
+		d.binaryExpr(&n.binaryExprNode)
+		return n
+	case NodeComparativeExpr:
+		n := &ComparativeExprNode{}
+		d.binaryExpr(&n.binaryExprNode)
+		return n
+	case NodeNumericComparativeExpr:
+		n := &NumericComparativeExprNode{}
+		d.binaryExpr(&n.binaryExprNode)
+		return n
+	case NodeLogicalExpr:
+		n := &LogicalExprNode{}
+		d.binaryExpr(&n.binaryExprNode)
+		return n
+	case NodeCoalesceExpr:
+		n := &CoalesceExprNode{}
+		d.binaryExpr(&n.binaryExprNode)
+		return n
+	case NodeNotExpr:
+		n := &NotExprNode{}
+		d.base(&n.NodeBase)
+		n.Expr = d.node()
+		return n
+	case NodeCallExpr:
+		n := &CallExprNode{}
+		d.callExpr(n)
+		return n
+	case NodeTernaryExpr:
+		n := &TernaryExprNode{}
+		d.base(&n.NodeBase)
+		n.Boolean = d.node()
+		n.Left = d.node()
+		n.Right = d.node()
+		return n
+	case NodeIndexExpr:
+		n := &IndexExprNode{}
+		d.base(&n.NodeBase)
+		n.Base = d.node()
+		n.Index = d.node()
+		n.Nullable = d.bool()
+		return n
+	case NodeSliceExpr:
+		n := &SliceExprNode{}
+		d.base(&n.NodeBase)
+		n.Base = d.node()
+		n.Index = d.node()
+		n.EndIndex = d.node()
+		return n
+	case NodeReturn:
+		n := &ReturnNode{}
+		d.base(&n.NodeBase)
+		n.Value = d.node()
+		return n
+	case NodeTry:
+		n := &TryNode{}
+		d.base(&n.NodeBase)
+		n.List = decodeNode[*ListNode](d)
+		n.Catch = decodeNode[*catchNode](d)
+		return n
+	case nodeCatch:
+		n := &catchNode{}
+		d.base(&n.NodeBase)
+		n.Err = decodeNode[*IdentifierNode](d)
+		n.List = decodeNode[*ListNode](d)
+		return n
+	case NodeTrans:
+		n := &TransNode{}
+		d.base(&n.NodeBase)
+		n.Key = d.node()
+		n.Args = d.nodes()
+		for i, count := 0, d.count(); i < count; i++ {
+			n.Named = append(n.Named, TransArgument{Name: d.string(), Value: d.node()})
+		}
+		n.List = decodeNode[*ListNode](d)
+		return n
+	case NodeSwitch:
+		n := &SwitchNode{}
+		d.base(&n.NodeBase)
+		n.Expression = d.node()
+		for i, count := 0, d.count(); i < count; i++ {
+			n.Cases = append(n.Cases, decodeNode[*CaseNode](d))
+		}
+		n.Default = decodeNode[*ListNode](d)
+		return n
+	case NodeCase:
+		n := &CaseNode{}
+		d.base(&n.NodeBase)
+		n.Values = d.nodes()
+		n.List = decodeNode[*ListNode](d)
+		return n
+	case NodeBreak, NodeContinue:
+		n := &LoopControlNode{}
+		d.base(&n.NodeBase)
+		n.Condition = d.node()
+		return n
+	}
+	d.fail()
+	return nil
+}
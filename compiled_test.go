@@ -0,0 +1,174 @@
+package jet
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// nodeTypes collects the types of node and of the nodes below it.
+func nodeTypes(node reflect.Value, seen map[NodeType]bool) {
+	switch node.Kind() {
+	case reflect.Interface, reflect.Ptr:
+		if node.IsNil() {
+			return
+		}
+		if n, ok := node.Interface().(Node); ok && node.Kind() == reflect.Ptr {
+			seen[n.Type()] = true
+		}
+		nodeTypes(node.Elem(), seen)
+	case reflect.Struct:
+		for i := 0; i < node.NumField(); i++ {
+			if node.Type().Field(i).Type != reflect.TypeOf(reflect.Value{}) {
+				nodeTypes(node.Field(i), seen)
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < node.Len(); i++ {
+			nodeTypes(node.Index(i), seen)
+		}
+	}
+}
+
+func TestCompiledRoundTrip(t *testing.T) {
+	s := newClosureSet(false)
+	vars := VarMap{}.Set("n", 2).Set("nothing", []int{})
+	user := closureUser{Name: "ann", Tags: []string{"a", "b"}, Admin: true}
+	seen := map[NodeType]bool{}
+	for path := range closureTemplates {
+		tmpl, err := s.GetTemplate(path)
+		if err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		nodeTypes(reflect.ValueOf(tmpl.Root), seen)
+		for _, block := range tmpl.passedBlocks {
+			nodeTypes(reflect.ValueOf(block), seen)
+		}
+
+		data, err := tmpl.MarshalBinary()
+		if err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		decoded, err := s.UnmarshalTemplate(data)
+		if err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		if !reflect.DeepEqual(decoded.Root, tmpl.Root) {
+			t.Errorf("%s: decoded tree %s differs from %s", path, decoded.Root, tmpl.Root)
+		}
+		again, err := decoded.MarshalBinary()
+		if err != nil || !bytes.Equal(again, data) {
+			t.Errorf("%s: encoding the decoded template gives different bytes (%v)", path, err)
+		}
+
+		var want, got strings.Builder
+		if err := tmpl.Execute(&want, vars, user); err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		if err := decoded.Execute(&got, vars, user); err != nil {
+			t.Fatalf("%s: decoded: %v", path, err)
+		}
+		if got.String() != want.String() {
+			t.Errorf("%s: decoded template rendered %q, want %q", path, got.String(), want.String())
+		}
+	}
+
+	all := []NodeType{
+		NodeText, NodeAction, NodeChain, NodeCommand, NodeField, NodeIdentifier, NodeUnderscore, NodeList, NodePipe,
+		NodeSet, NodeInclude, NodeBlock, NodeYield, NodeIf, NodeRange, NodeTry, NodeReturn, NodeTrans, NodeSwitch,
+		NodeCase, NodeBreak, NodeContinue, NodeString, NodeNil, NodeNumber, NodeBool, NodeAdditiveExpr,
+		NodeMultiplicativeExpr, NodeComparativeExpr, NodeNumericComparativeExpr, NodeLogicalExpr, NodeCallExpr,
+		NodeNotExpr, NodeTernaryExpr, NodeIndexExpr, NodeSliceExpr, NodeCoalesceExpr,
+	}
+	for _, typ := range all {
+		if !seen[typ] {
+			t.Errorf("no template has a node of type %d", typ)
+		}
+	}
+}
+
+func TestCompiledFilters(t *testing.T) {
+	s := newClosureSet(false)
+	tmpl, err := s.GetTemplate("/filters.jet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := tmpl.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := s.UnmarshalTemplate(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded.String() != tmpl.String() {
+		t.Errorf("decoded %q, want %q", decoded.String(), tmpl.String())
+	}
+	truncate := decoded.Root.Nodes[0].(*ActionNode).Pipe.Cmds[2]
+	if !truncate.filter.IsValid() || !truncate.Colon {
+		t.Errorf("%s lost its filter or its syntax", truncate)
+	}
+}
+
+func TestCompiledVersion(t *testing.T) {
+	tmpl, err := NewSet(NewInMemLoader()).parseString(`{{ 1 }}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := tmpl.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data[len(compiledMagic)]++
+	if _, err := NewSet(NewInMemLoader()).UnmarshalTemplate(data); err == nil {
+		t.Error("decoded a template of another version")
+	}
+	if _, err := NewSet(NewInMemLoader()).UnmarshalTemplate(data[:len(data)/2]); err == nil {
+		t.Error("decoded a truncated template")
+	}
+}
+
+func TestTemplateUnmarshalBinary(t *testing.T) {
+	tmpl, err := NewTemplate(`{{ range i := 3 }}{{ i }}{{ end }}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := tmpl.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded Template
+	if err := decoded.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	got, err := decoded.ParseMap(nil)
+	if err != nil || got != "012" {
+		t.Errorf("got %q, %v, want %q", got, err, "012")
+	}
+}
+
+func TestCompileBundle(t *testing.T) {
+	var bundle bytes.Buffer
+	if err := newClosureSet(false).Compile(&bundle, "/extends.jet", "/import.jet"); err != nil {
+		t.Fatal(err)
+	}
+	// the templates come from the bundle only
+	s := NewSet(NewInMemLoader())
+	if err := s.LoadCompiled(&bundle); err != nil {
+		t.Fatal(err)
+	}
+	for path, want := range map[string]string{"/extends.jet": "[child ann]", "/import.jet": "(1)(2)"} {
+		tmpl, err := s.GetTemplate(path)
+		if err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		var out strings.Builder
+		if err := tmpl.Execute(&out, nil, closureUser{Name: "ann"}); err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		if out.String() != want {
+			t.Errorf("%s: got %q, want %q", path, out.String(), want)
+		}
+	}
+}
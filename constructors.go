@@ -52,6 +52,10 @@ func (t *Template) newComparativeExpr(pos Pos, line int, left, right Expression,
 	return &ComparativeExprNode{binaryExprNode{NodeBase: NodeBase{TemplatePath: t.Name, NodeType: NodeComparativeExpr, Pos: pos, Line: line}, Operator: item, Left: left, Right: right}}
 }
 
+func (t *Template) newCoalesceExpr(pos Pos, line int, left, right Expression, item item) *CoalesceExprNode {
+	return &CoalesceExprNode{binaryExprNode{NodeBase: NodeBase{TemplatePath: t.Name, NodeType: NodeCoalesceExpr, Pos: pos, Line: line}, Operator: item, Left: left, Right: right}}
+}
+
 func (t *Template) newLogicalExpr(pos Pos, line int, left, right Expression, item item) *LogicalExprNode {
 	return &LogicalExprNode{binaryExprNode{NodeBase: NodeBase{TemplatePath: t.Name, NodeType: NodeLogicalExpr, Pos: pos, Line: line}, Operator: item, Left: left, Right: right}}
 }
@@ -162,6 +166,26 @@ func (t *Template) newCatch(pos Pos, line int, errVar *IdentifierNode, list *Lis
 	return &catchNode{NodeBase: NodeBase{TemplatePath: t.Name, NodeType: nodeCatch, Pos: pos, Line: line}, Err: errVar, List: list}
 }
 
+func (t *Template) newSwitch(pos Pos, line int, expression Expression) *SwitchNode {
+	return &SwitchNode{NodeBase: NodeBase{TemplatePath: t.Name, NodeType: NodeSwitch, Pos: pos, Line: line}, Expression: expression}
+}
+
+func (t *Template) newCase(pos Pos, line int, values []Expression) *CaseNode {
+	return &CaseNode{NodeBase: NodeBase{TemplatePath: t.Name, NodeType: NodeCase, Pos: pos, Line: line}, Values: values}
+}
+
+func (t *Template) newDefault(pos Pos, line int) *defaultNode {
+	return &defaultNode{NodeBase: NodeBase{TemplatePath: t.Name, NodeType: nodeDefault, Pos: pos, Line: line}}
+}
+
+func (t *Template) newLoopControl(pos Pos, line int, typ NodeType, condition Expression) *LoopControlNode {
+	return &LoopControlNode{NodeBase: NodeBase{TemplatePath: t.Name, NodeType: typ, Pos: pos, Line: line}, Condition: condition}
+}
+
+func (t *Template) newTrans(pos Pos, line int, key Expression, args []Expression, named []TransArgument, list *ListNode) *TransNode {
+	return &TransNode{NodeBase: NodeBase{TemplatePath: t.Name, NodeType: NodeTrans, Pos: pos, Line: line}, Key: key, Args: args, Named: named, List: list}
+}
+
 func (t *Template) newNumber(pos Pos, text string, typ itemType) (*NumberNode, error) {
 	n := &NumberNode{NodeBase: NodeBase{TemplatePath: t.Name, NodeType: NodeNumber, Pos: pos}, Text: text}
 	// todo: optimize
@@ -0,0 +1,75 @@
+package jet
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"reflect"
+
+	"github.com/oarkflow/jet/utils/e"
+)
+
+// CanceledError is returned by ExecuteContext when the context is done before the template finished rendering.
+// It unwraps to the context's error, so errors.Is(err, context.Canceled) and
+// errors.Is(err, context.DeadlineExceeded) work as expected.
+type CanceledError struct {
+	*e.Builder
+	Template string // path of the template being rendered when execution stopped
+	Line     int    // line of the node that was about to be executed
+	Cause    error  // the context's error
+}
+
+func (err *CanceledError) Unwrap() error {
+	return err.Cause
+}
+
+// ExecuteContext executes the template like Execute, but stops as soon as ctx is done: cancellation is checked
+// before every node and every range iteration, and ranges over channels stop waiting for a value. The context is
+// available to functions through Arguments.Runtime().ExecutionContext().
+func (t *Template) ExecuteContext(ctx context.Context, w io.Writer, variables VarMap, data interface{}) error {
+	return t.execute(w, variables, data, func(rt *Runtime) {
+		rt.ctx = ctx
+		rt.done = ctx.Done()
+	})
+}
+
+// ExecutionContext returns the context passed to ExecuteContext, or context.Background() if the template is
+// executed without one.
+func (rt *Runtime) ExecutionContext() context.Context {
+	if rt.ctx == nil {
+		return context.Background()
+	}
+	return rt.ctx
+}
+
+// canceled returns a *CanceledError located at node if the execution context is done.
+func (rt *Runtime) canceled(node Node) e.Error {
+	if rt.done == nil {
+		return nil
+	}
+	select {
+	case <-rt.done:
+	default:
+		return nil
+	}
+	if rt.canceledErr == nil {
+		b := node.error(e.CanceledReason, fmt.Sprintf("execution stopped: %v", rt.ctx.Err())).(*e.Builder)
+		rt.canceledErr = &CanceledError{Builder: b, Template: b.T, Line: b.P.L, Cause: rt.ctx.Err()}
+	}
+	return rt.canceledErr
+}
+
+// recvContext receives from ch like reflect.Value.Recv, giving up when done is closed.
+func recvContext(ch reflect.Value, done <-chan struct{}) (reflect.Value, bool) {
+	if done == nil {
+		return ch.Recv()
+	}
+	chosen, v, ok := reflect.Select([]reflect.SelectCase{
+		{Dir: reflect.SelectRecv, Chan: ch},
+		{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(done)},
+	})
+	if chosen == 1 {
+		return reflect.Value{}, false
+	}
+	return v, ok
+}
@@ -0,0 +1,84 @@
+package jet
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newContextSet(closures bool) *Set {
+	var opts []Option
+	if closures {
+		opts = append(opts, WithClosureCompilation())
+	}
+	return NewSet(NewInMemLoader(), opts...)
+}
+
+func TestExecuteContextCancelsChannelRange(t *testing.T) {
+	for _, closures := range []bool{false, true} {
+		tmpl, err := newContextSet(closures).parseString(`{{ range v := ch }}{{ v }}{{ else }}empty{{ end }}`)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		var out strings.Builder
+		err = tmpl.ExecuteContext(ctx, &out, VarMap{}.Set("ch", make(chan int)), nil)
+		cancel()
+		var canceled *CanceledError
+		if !errors.As(err, &canceled) || !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("closures %v: got %v, want a CanceledError for the deadline", closures, err)
+		}
+		if canceled.Line != 1 {
+			t.Errorf("closures %v: got line %d, want 1", closures, canceled.Line)
+		}
+		if out.String() != "" {
+			t.Errorf("closures %v: got %q, the else branch of a canceled range must not run", closures, out.String())
+		}
+	}
+}
+
+func TestExecuteContextStopsRange(t *testing.T) {
+	for _, closures := range []bool{false, true} {
+		tmpl, err := newContextSet(closures).parseString(`{{ range items }}{{ . }}{{ stop() }}{{ end }}`)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		stop := func(a Arguments) reflect.Value {
+			if a.Runtime().ExecutionContext() != ctx {
+				t.Error("ExecutionContext is not the context passed to ExecuteContext")
+			}
+			cancel()
+			return reflect.Value{}
+		}
+		var out strings.Builder
+		err = tmpl.ExecuteContext(ctx, &out, VarMap{}.Set("items", []int{1, 2, 3}).SetFunc("stop", stop), nil)
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("closures %v: got %v, want context.Canceled", closures, err)
+		}
+		if out.String() != "1" {
+			t.Errorf("closures %v: got %q, want the first iteration only", closures, out.String())
+		}
+	}
+}
+
+func TestExecutionContextWithoutContext(t *testing.T) {
+	tmpl, err := newContextSet(false).parseString(`{{ ctx() }}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := func(a Arguments) reflect.Value {
+		return reflect.ValueOf(a.Runtime().ExecutionContext() == context.Background())
+	}
+	var out strings.Builder
+	if err := tmpl.Execute(&out, VarMap{}.SetFunc("ctx", ctx), nil); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "true" {
+		t.Errorf("got %q, want context.Background()", out.String())
+	}
+}
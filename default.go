@@ -97,6 +97,11 @@ func init() {
 				return hiddenFalse
 			}
 
+			if err := a.runtime.limits.enter(); err != nil {
+				a.Panicf(err.Error())
+			}
+			defer a.runtime.limits.leave()
+
 			a.runtime.newScope()
 			defer a.runtime.releaseScope()
 
@@ -127,12 +132,17 @@ func init() {
 				)
 			}
 
+			if err := a.runtime.limits.enter(); err != nil {
+				a.Panicf(err.Error())
+			}
+			defer a.runtime.limits.leave()
+
 			a.runtime.newScope()
 			defer a.runtime.releaseScope()
 
-			w := a.runtime.Writer
-			defer func() { a.runtime.Writer = w }()
-			a.runtime.Writer = io.Discard
+			w, binder := a.runtime.Writer, a.runtime.binder
+			defer func() { a.runtime.Writer, a.runtime.binder = w, binder }()
+			a.runtime.Writer, a.runtime.binder = io.Discard, nil
 
 			a.runtime.blocks = t.processedBlocks
 			root := t.Root
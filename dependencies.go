@@ -0,0 +1,278 @@
+package jet
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+)
+
+// DependencyKind tells how a template refers to another one.
+type DependencyKind int
+
+const (
+	DependencyExtends DependencyKind = iota // {{ extends "path" }}
+	DependencyImport                        // {{ import "path" }}
+	DependencyInclude                       // {{ include "path" }}, with a string literal
+)
+
+func (k DependencyKind) String() string {
+	switch k {
+	case DependencyExtends:
+		return "extends"
+	case DependencyImport:
+		return "import"
+	case DependencyInclude:
+		return "include"
+	}
+	return "DependencyKind(" + strconv.Itoa(int(k)) + ")"
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (k DependencyKind) MarshalText() ([]byte, error) {
+	return []byte(k.String()), nil
+}
+
+// Dependency is a reference from a template to another one.
+type Dependency struct {
+	Path string         `json:"path"` // path of the referenced template, with its extension when it could be found
+	Kind DependencyKind `json:"kind"`
+	Line int            `json:"line"` // line of the reference in the referencing template
+}
+
+// Dependencies returns the templates t extends, imports and includes, in order of appearance. Includes are only
+// known when the template name is a string literal; `{{ include name }}` with a computed name is not reported.
+func (t *Template) Dependencies() []Dependency {
+	return append([]Dependency(nil), t.dependencies...)
+}
+
+// findIncludes returns the includes of t with a string literal as template name.
+func (t *Template) findIncludes() []Dependency {
+	var deps []Dependency
+	var walk func(node Node)
+	walk = func(node Node) {
+		switch node := node.(type) {
+		case *ListNode:
+			if node != nil {
+				for _, n := range node.Nodes {
+					walk(n)
+				}
+			}
+		case *IfNode:
+			walk(node.List)
+			walk(node.ElseList)
+		case *RangeNode:
+			walk(node.List)
+			walk(node.ElseList)
+		case *BlockNode:
+			walk(node.List)
+			walk(node.Content)
+		case *YieldNode:
+			walk(node.Content)
+		case *TryNode:
+			walk(node.List)
+			if node.Catch != nil {
+				walk(node.Catch.List)
+			}
+		case *TransNode:
+			walk(node.List)
+		case *SwitchNode:
+			for _, c := range node.Cases {
+				walk(c.List)
+			}
+			walk(node.Default)
+		case *IncludeNode:
+			if name, ok := node.Name.(*StringNode); ok {
+				deps = append(deps, Dependency{Path: t.set.resolvePath(name.Text, t.Name), Kind: DependencyInclude, Line: node.Line})
+			}
+		}
+	}
+	walk(t.Root)
+	return deps
+}
+
+// resolvePath returns the name of the template found at templatePath relative to siblingPath, or the absolute
+// templatePath if there is none.
+func (s *Set) resolvePath(templatePath, siblingPath string) string {
+	templatePath = filepath.ToSlash(templatePath)
+	if !path.IsAbs(templatePath) {
+		templatePath = path.Join(path.Dir(path.Join("/", filepath.ToSlash(siblingPath))), templatePath)
+	}
+	for _, extension := range s.extensions {
+		canonicalPath := templatePath + extension
+		if t := s.cache.Get(canonicalPath); t != nil {
+			return t.Name
+		}
+		if s.loader.Exists(canonicalPath) {
+			return canonicalPath
+		}
+	}
+	return templatePath
+}
+
+// dependsOn reports whether t extends, imports or includes any of the named templates.
+func (t *Template) dependsOn(names map[string]bool) bool {
+	for _, dep := range t.dependencies {
+		if names[dep.Path] {
+			return true
+		}
+	}
+	return false
+}
+
+// Dependents returns the names of the cached templates extending, importing or including the template at
+// templatePath, sorted. Only direct dependents are returned; call Dependents on them to walk up the graph.
+func (s *Set) Dependents(templatePath string) []string {
+	name := s.resolvePath(templatePath, "/")
+	names := map[string]bool{name: true}
+	var result []string
+	seen := map[string]bool{}
+	for _, key := range s.dependents.keys(name) {
+		t := s.cache.Get(key)
+		if t == nil || seen[t.Name] || !t.dependsOn(names) {
+			continue
+		}
+		seen[t.Name] = true
+		result = append(result, t.Name)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// DependencyEdge is a reference from the template From to another one.
+type DependencyEdge struct {
+	From string `json:"from"`
+	Dependency
+}
+
+// DependencyGraph is the graph of templates and the references between them.
+type DependencyGraph struct {
+	Templates []string         `json:"templates"` // template names, sorted
+	Edges     []DependencyEdge `json:"edges"`
+}
+
+// DependencyGraph returns the graph of the templates at templatePaths and of all the templates they depend on,
+// directly or indirectly. It fails if one of these templates can't be loaded.
+func (s *Set) DependencyGraph(templatePaths ...string) (*DependencyGraph, error) {
+	g := &DependencyGraph{}
+	seen := map[string]bool{}
+	var visit func(t *Template) error
+	visit = func(t *Template) error {
+		if seen[t.Name] {
+			return nil
+		}
+		seen[t.Name] = true
+		g.Templates = append(g.Templates, t.Name)
+		for _, dep := range t.dependencies {
+			g.Edges = append(g.Edges, DependencyEdge{From: t.Name, Dependency: dep})
+			if seen[dep.Path] {
+				continue
+			}
+			tt, err := s.GetTemplate(dep.Path)
+			if err != nil {
+				return fmt.Errorf("%s:%d: %s: %w", t.Name, dep.Line, dep.Kind, err)
+			}
+			if err := visit(tt); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for _, templatePath := range templatePaths {
+		t, err := s.GetTemplate(templatePath)
+		if err != nil {
+			return nil, err
+		}
+		if err := visit(t); err != nil {
+			return nil, err
+		}
+	}
+	sort.Strings(g.Templates)
+	return g, nil
+}
+
+// Cycles returns the groups of templates referring to each other in a cycle, each sorted by name.
+func (g *DependencyGraph) Cycles() [][]string {
+	next := map[string][]string{}
+	selfLoop := map[string]bool{}
+	for _, edge := range g.Edges {
+		next[edge.From] = append(next[edge.From], edge.Path)
+		if edge.From == edge.Path {
+			selfLoop[edge.From] = true
+		}
+	}
+
+	// Tarjan's strongly connected components
+	var (
+		cycles  [][]string
+		stack   []string
+		onStack = map[string]bool{}
+		index   = map[string]int{}
+		lowlink = map[string]int{}
+	)
+	var connect func(name string)
+	connect = func(name string) {
+		index[name] = len(index)
+		lowlink[name] = index[name]
+		stack = append(stack, name)
+		onStack[name] = true
+		for _, to := range next[name] {
+			if _, visited := index[to]; !visited {
+				connect(to)
+				lowlink[name] = min(lowlink[name], lowlink[to])
+			} else if onStack[to] {
+				lowlink[name] = min(lowlink[name], index[to])
+			}
+		}
+		if lowlink[name] != index[name] {
+			return
+		}
+		var component []string
+		for {
+			top := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			onStack[top] = false
+			component = append(component, top)
+			if top == name {
+				break
+			}
+		}
+		if len(component) > 1 || selfLoop[name] {
+			sort.Strings(component)
+			cycles = append(cycles, component)
+		}
+	}
+	for _, name := range g.Templates {
+		if _, visited := index[name]; !visited {
+			connect(name)
+		}
+	}
+	return cycles
+}
+
+// WriteDOT writes the graph in the Graphviz DOT language, with edges labeled by kind.
+func (g *DependencyGraph) WriteDOT(w io.Writer) error {
+	if _, err := io.WriteString(w, "digraph templates {\n"); err != nil {
+		return err
+	}
+	for _, name := range g.Templates {
+		if _, err := fmt.Fprintf(w, "\t%q;\n", name); err != nil {
+			return err
+		}
+	}
+	for _, edge := range g.Edges {
+		if _, err := fmt.Fprintf(w, "\t%q -> %q [label=%q];\n", edge.From, edge.Path, edge.Kind.String()); err != nil {
+			return err
+		}
+	}
+	_, err := io.WriteString(w, "}\n")
+	return err
+}
+
+// WriteJSON writes the graph as a JSON object with the templates and edges.
+func (g *DependencyGraph) WriteJSON(w io.Writer) error {
+	return json.NewEncoder(w).Encode(g)
+}
@@ -0,0 +1,145 @@
+package jet
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// newDependencySet returns a Set over templates where page.jet extends layout.jet, imports macros.jet and includes
+// header.jet, and a.jet and b.jet include each other.
+func newDependencySet() *Set {
+	loader := NewInMemLoader()
+	loader.Set("/layout.jet", `{{ yield body() }}`)
+	loader.Set("/macros.jet", `{{ block m() }}m{{ end }}`)
+	loader.Set("/header.jet", `header`)
+	loader.Set("/page.jet", "{{ extends \"layout.jet\" }}\n{{ import \"macros\" }}\n{{ block body() }}\n{{ include \"header.jet\" }}\n{{ end }}")
+	loader.Set("/a.jet", `{{ if false }}{{ include "b.jet" }}{{ end }}`)
+	loader.Set("/b.jet", "\n{{ include \"/a\" }}")
+	return NewSet(loader)
+}
+
+func TestDependencies(t *testing.T) {
+	s := newDependencySet()
+	tests := []struct {
+		name string
+		want []Dependency
+	}{
+		{"/page.jet", []Dependency{
+			{Path: "/layout.jet", Kind: DependencyExtends, Line: 1},
+			{Path: "/macros.jet", Kind: DependencyImport, Line: 2},
+			{Path: "/header.jet", Kind: DependencyInclude, Line: 4},
+		}},
+		{"/a.jet", []Dependency{{Path: "/b.jet", Kind: DependencyInclude, Line: 1}}},
+		{"/b.jet", []Dependency{{Path: "/a.jet", Kind: DependencyInclude, Line: 2}}},
+		{"/header.jet", nil},
+	}
+	for _, tt := range tests {
+		tmpl, err := s.GetTemplate(tt.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := tmpl.Dependencies(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDependents(t *testing.T) {
+	s := newDependencySet()
+	for _, name := range []string{"/page.jet", "/a.jet", "/b.jet"} {
+		if _, err := s.GetTemplate(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tests := []struct {
+		name string
+		want []string
+	}{
+		{"/layout.jet", []string{"/page.jet"}},
+		{"macros", []string{"/page.jet"}},
+		{"header.jet", []string{"/page.jet"}},
+		{"/a", []string{"/b.jet"}},
+		{"/b.jet", []string{"/a.jet"}},
+		{"/page.jet", nil},
+	}
+	for _, tt := range tests {
+		if got := s.Dependents(tt.name); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDependencyGraph(t *testing.T) {
+	tests := []struct {
+		roots  []string
+		cycles [][]string
+		dot    string
+		json   string
+	}{
+		{
+			roots:  []string{"/page.jet"},
+			cycles: nil,
+			dot: `digraph templates {
+	"/header.jet";
+	"/layout.jet";
+	"/macros.jet";
+	"/page.jet";
+	"/page.jet" -> "/layout.jet" [label="extends"];
+	"/page.jet" -> "/macros.jet" [label="import"];
+	"/page.jet" -> "/header.jet" [label="include"];
+}
+`,
+			json: `{"templates":["/header.jet","/layout.jet","/macros.jet","/page.jet"],"edges":[` +
+				`{"from":"/page.jet","path":"/layout.jet","kind":"extends","line":1},` +
+				`{"from":"/page.jet","path":"/macros.jet","kind":"import","line":2},` +
+				`{"from":"/page.jet","path":"/header.jet","kind":"include","line":4}]}` + "\n",
+		},
+		{
+			roots:  []string{"/a.jet"},
+			cycles: [][]string{{"/a.jet", "/b.jet"}},
+			dot: `digraph templates {
+	"/a.jet";
+	"/b.jet";
+	"/a.jet" -> "/b.jet" [label="include"];
+	"/b.jet" -> "/a.jet" [label="include"];
+}
+`,
+			json: `{"templates":["/a.jet","/b.jet"],"edges":[` +
+				`{"from":"/a.jet","path":"/b.jet","kind":"include","line":1},` +
+				`{"from":"/b.jet","path":"/a.jet","kind":"include","line":2}]}` + "\n",
+		},
+	}
+	for _, tt := range tests {
+		g, err := newDependencySet().DependencyGraph(tt.roots...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := g.Cycles(); !reflect.DeepEqual(got, tt.cycles) {
+			t.Errorf("%v: cycles: got %q, want %q", tt.roots, got, tt.cycles)
+		}
+		var dot strings.Builder
+		if err := g.WriteDOT(&dot); err != nil {
+			t.Fatal(err)
+		}
+		if dot.String() != tt.dot {
+			t.Errorf("%v: DOT: got\n%s\nwant\n%s", tt.roots, dot.String(), tt.dot)
+		}
+		var json strings.Builder
+		if err := g.WriteJSON(&json); err != nil {
+			t.Fatal(err)
+		}
+		if json.String() != tt.json {
+			t.Errorf("%v: JSON: got\n%s\nwant\n%s", tt.roots, json.String(), tt.json)
+		}
+	}
+}
+
+func TestDependencyGraphMissingTemplate(t *testing.T) {
+	loader := NewInMemLoader()
+	loader.Set("/page.jet", "\n{{ include \"missing.jet\" }}")
+	_, err := NewSet(loader).DependencyGraph("/page.jet")
+	if err == nil || !strings.HasPrefix(err.Error(), "/page.jet:2: include: ") {
+		t.Errorf("got %v, want an error locating the include", err)
+	}
+}
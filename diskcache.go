@@ -0,0 +1,130 @@
+package jet
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// DiskCache is a Cache keeping templates in memory and persisting them, compiled (see Template.MarshalBinary), in a
+// directory. Files are keyed by a hash of the template's path, source and delimiters, so that after a restart a
+// template whose source didn't change is loaded without parsing, and a changed one is parsed again.
+//
+// A DiskCache must be passed to WithCache for a single Set, which it reads the template sources from. Writing
+// files is best effort: errors are ignored and the template is parsed again next time.
+type DiskCache struct {
+	dir string
+	mem Cache
+	set *Set
+}
+
+// compile-time check that DiskCache implements EvictingCache
+var _ EvictingCache = (*DiskCache)(nil)
+
+// NewDiskCache returns a DiskCache storing files in dir, which must exist, and keeping templates in mem, or in an
+// unbounded in-memory cache if mem is nil.
+func NewDiskCache(dir string, mem Cache) *DiskCache {
+	if mem == nil {
+		mem = &cache{}
+	}
+	return &DiskCache{dir: dir, mem: mem}
+}
+
+// Get returns the template from memory, or else decodes it from the file matching the current source of the
+// template, if any. A file that can't be decoded is removed.
+func (c *DiskCache) Get(templatePath string) *Template {
+	if t := c.mem.Get(templatePath); t != nil {
+		return t
+	}
+	if c.set == nil || !c.set.loader.Exists(templatePath) {
+		return nil
+	}
+	f, err := c.set.loader.Open(templatePath)
+	if err != nil {
+		return nil
+	}
+	source, err := io.ReadAll(f)
+	f.Close()
+	if err != nil {
+		return nil
+	}
+	file := c.file(templatePath, string(source))
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil
+	}
+	t, err := c.set.UnmarshalTemplate(data)
+	if err != nil || t.Name != templatePath {
+		// remove the corrupt file, so that Put writes it again once the template is parsed
+		os.Remove(file)
+		return nil
+	}
+	c.mem.Put(templatePath, t)
+	c.set.dependents.add(templatePath, t)
+	return t
+}
+
+// Put stores the template in memory and writes its file, unless it has no path (templates parsed from strings).
+func (c *DiskCache) Put(templatePath string, t *Template) {
+	c.mem.Put(templatePath, t)
+	if t == nil || t.Name == "" {
+		return
+	}
+	data, err := t.MarshalBinary()
+	if err != nil {
+		return
+	}
+	file := c.file(t.Name, t.text)
+	if _, err := os.Stat(file); err == nil {
+		return
+	}
+	tmp, err := os.CreateTemp(c.dir, ".jetc-*")
+	if err != nil {
+		return
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), file)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+}
+
+// Delete removes the template from memory, if the memory cache supports it. Files are left in place: they are keyed
+// by source, so a stale file is never read.
+func (c *DiskCache) Delete(templatePath string) {
+	deleteFromCache(c.mem, templatePath)
+}
+
+// Purge removes all templates from memory, if the memory cache supports it.
+func (c *DiskCache) Purge() {
+	if p, ok := c.mem.(CachePurger); ok {
+		p.Purge()
+	}
+}
+
+// Len returns the number of templates in memory, or 0 if the memory cache can't tell.
+func (c *DiskCache) Len() int {
+	if s, ok := c.mem.(CacheSizer); ok {
+		return s.Len()
+	}
+	return 0
+}
+
+// file returns the path of the file holding the compiled template with the given name and source.
+func (c *DiskCache) file(name, source string) string {
+	h := sha256.New()
+	for _, s := range []string{strconv.Itoa(compiledVersion), name, c.set.leftDelim, c.set.rightDelim, source} {
+		io.WriteString(h, strconv.Itoa(len(s)))
+		io.WriteString(h, ":")
+		io.WriteString(h, s)
+	}
+	return filepath.Join(c.dir, hex.EncodeToString(h.Sum(nil))+".jetc")
+}
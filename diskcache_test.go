@@ -0,0 +1,106 @@
+package jet
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// diskCacheFiles returns the files of a DiskCache directory.
+func diskCacheFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	files, err := filepath.Glob(filepath.Join(dir, "*.jetc"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+func TestDiskCache(t *testing.T) {
+	dir := t.TempDir()
+	loader := NewInMemLoader()
+	loader.Set("/layout.jet", `[{{ yield body() }}]`)
+	loader.Set("/page.jet", `{{ extends "layout.jet" }}{{ block body() }}{{ "page" | upper }}{{ end }}`)
+	newCache := func() (*DiskCache, *Set) {
+		c := NewDiskCache(dir, nil)
+		return c, NewSet(loader, WithCache(c))
+	}
+
+	c, s := newCache()
+	if c.Get("/page.jet") != nil {
+		t.Fatal("an empty DiskCache has a template")
+	}
+	if got := renderTemplate(t, s, "/page.jet"); got != "[PAGE]" {
+		t.Fatalf("got %q, want %q", got, "[PAGE]")
+	}
+	if files := diskCacheFiles(t, dir); len(files) != 2 {
+		t.Fatalf("got files %q, want one per template", files)
+	}
+
+	// another Set, as after a restart, reads the templates from the files
+	c, s = newCache()
+	if c.Get("/page.jet") == nil || c.Len() != 2 {
+		t.Fatal("the template and the one it extends were not read from their files")
+	}
+	if got := renderTemplate(t, s, "/page.jet"); got != "[PAGE]" {
+		t.Errorf("got %q, want %q", got, "[PAGE]")
+	}
+
+	// a changed source doesn't match the stale file
+	loader.Set("/page.jet", `{{ extends "layout.jet" }}{{ block body() }}new{{ end }}`)
+	c, s = newCache()
+	if c.Get("/page.jet") != nil {
+		t.Error("the stale file was read for the changed template")
+	}
+	if got := renderTemplate(t, s, "/page.jet"); got != "[new]" {
+		t.Errorf("got %q, want %q", got, "[new]")
+	}
+	if files := diskCacheFiles(t, dir); len(files) != 3 {
+		t.Errorf("got files %q, want a new one for the changed template", files)
+	}
+	c, _ = newCache()
+	if c.Get("/page.jet") == nil {
+		t.Error("the changed template was not read from its new file")
+	}
+
+	// the templates parsed from strings are not written
+	if _, err := s.parseString("{{ 1 }}"); err != nil {
+		t.Fatal(err)
+	}
+	c.Put("", &Template{})
+	if files := diskCacheFiles(t, dir); len(files) != 3 {
+		t.Errorf("got files %q, want none for templates without a path", files)
+	}
+}
+
+func TestDiskCacheCorruptFile(t *testing.T) {
+	dir := t.TempDir()
+	loader := NewInMemLoader()
+	loader.Set("/page.jet", `{{ "page" | upper }}`)
+	if got := renderTemplate(t, NewSet(loader, WithCache(NewDiskCache(dir, nil))), "/page.jet"); got != "PAGE" {
+		t.Fatalf("got %q, want %q", got, "PAGE")
+	}
+	files := diskCacheFiles(t, dir)
+	if len(files) != 1 {
+		t.Fatalf("got files %q, want one", files)
+	}
+
+	for _, data := range []string{"", "not a template", "\x00\x01\x02"} {
+		if err := os.WriteFile(files[0], []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		c := NewDiskCache(dir, nil)
+		s := NewSet(loader, WithCache(c))
+		if c.Get("/page.jet") != nil {
+			t.Fatalf("%q: a corrupt file was decoded", data)
+		}
+		if got := renderTemplate(t, s, "/page.jet"); got != "PAGE" {
+			t.Errorf("%q: got %q, want %q", data, got, "PAGE")
+		}
+		c = NewDiskCache(dir, nil)
+		NewSet(loader, WithCache(c))
+		if c.Get("/page.jet") == nil {
+			t.Errorf("%q: the corrupt file was not written again", data)
+		}
+	}
+}
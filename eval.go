@@ -16,6 +16,7 @@ package jet
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io"
 	"reflect"
@@ -64,11 +65,14 @@ type escapeeWriter struct {
 
 func (w *escapeeWriter) Write(b []byte) (int, error) {
 	if w.set == nil || w.set.escapee == nil {
-		w.Writer.Write(b)
-	} else {
-		w.set.escapee(w.Writer, b)
+		return w.Writer.Write(b)
 	}
-	return 0, nil
+	w.set.escapee(w.Writer, b)
+	// a SafeWriter can't return the error of the writer; the output limit is the one that must not be lost
+	if lw, ok := w.Writer.(*limitWriter); ok && lw.l.err != nil {
+		return 0, lw.l.err
+	}
+	return len(b), nil
 }
 
 // Runtime this type holds the state of the execution of an template
@@ -78,8 +82,31 @@ type Runtime struct {
 	content func(*Runtime, Expression) e.Error
 
 	context reflect.Value
+
+	globals   VarMap         // globals passed to Render, looked up before the Set's
+	binder    *sqlBinder     // collects bind arguments when executing with ExecuteSQL
+	locale    string         // locale passed to the Set's Translator
+	jsonTags  bool           // look struct fields up by their json names too, see WithJSONTags
+	missing   bool           // set when a missing value was read under MissingKeyZero or MissingKeyKeep
+	loop      loopControl    // set by break and continue until the innermost range handles it
+	iteration *loopIteration // the innermost range being executed, for the loop variable
+
+	ctx         context.Context // context passed to ExecuteContext
+	done        <-chan struct{} // ctx.Done(), nil when executing without a context
+	canceledErr *CanceledError
+
+	limits *limiter // resource accounting, nil when the execution is unlimited
 }
 
+// loopControl is the pending effect of a break or continue action on the innermost range.
+type loopControl uint8
+
+const (
+	loopNone loopControl = iota
+	loopBreak
+	loopContinue
+)
+
 // Context returns the current context value
 func (rt *Runtime) Context() reflect.Value {
 	return rt.context
@@ -204,7 +231,15 @@ func (rt *Runtime) resolve(name string) (reflect.Value, e.Error) {
 		sc = sc.parent
 	}
 
+	// try the loop variable of the innermost range, which variables hide
+	if name == loopVariable && rt.iteration != nil {
+		return reflect.ValueOf(rt.iteration), nil
+	}
+
 	// try globals
+	if v, ok := rt.globals[name]; ok {
+		return indirectEface(v), nil
+	}
 	rt.set.gmx.RLock()
 	v, ok := rt.set.globals[name]
 	rt.set.gmx.RUnlock()
@@ -239,9 +274,19 @@ func (rt *Runtime) MustResolve(name string) reflect.Value {
 }
 
 func (rt *Runtime) recover(err *error) {
+	stopped := rt.stopped()
+
 	// reset state scope and context just to be safe (they might not be cleared properly if there was a panic while using the state)
 	rt.scope = &scope{}
 	rt.context = reflect.Value{}
+	rt.globals = nil
+	rt.binder = nil
+	rt.locale = ""
+	rt.missing = false
+	rt.loop = loopNone
+	rt.iteration = nil
+	rt.ctx, rt.done, rt.canceledErr = nil, nil, nil
+	rt.limits = nil
 	pool_State.Put(rt)
 	if recovered := recover(); recovered != nil {
 		var ok bool
@@ -253,6 +298,21 @@ func (rt *Runtime) recover(err *error) {
 			panic(recovered)
 		}
 	}
+	if stopped != nil {
+		// report why the execution was stopped, even if an enclosing node wrapped the error
+		*err = stopped
+	}
+}
+
+// stopped returns the error that stopped the execution early: a cancellation or an exceeded limit.
+func (rt *Runtime) stopped() error {
+	if rt.canceledErr != nil {
+		return rt.canceledErr
+	}
+	if rt.limits != nil && rt.limits.err != nil {
+		return rt.limits.err
+	}
+	return nil
 }
 
 func (rt *Runtime) executeSet(left Expression, right reflect.Value) e.Error {
@@ -276,7 +336,7 @@ func (rt *Runtime) executeSet(left Expression, right reflect.Value) e.Error {
 	}
 	lef := len(fields) - 1
 	for i := 0; i < lef; i++ {
-		value, err = resolveIndex(value, reflect.Value{}, fields[i].name, fields[i].lax)
+		value, err = resolveIndex(value, reflect.Value{}, fields[i].name, fields[i].lax, rt.jsonTags)
 		if err != nil {
 			return left.error(err.Reason(), err.Message())
 		}
@@ -288,7 +348,15 @@ func (rt *Runtime) executeSet(left Expression, right reflect.Value) e.Error {
 			value = value.Elem()
 			continue
 		case reflect.Struct:
-			value = value.FieldByName(fields[lef].name)
+			if rt.jsonTags {
+				if id, ok := structFields(value.Type(), true)[fields[lef].name]; ok {
+					value = value.FieldByIndex(id)
+				} else {
+					value = reflect.Value{}
+				}
+			} else {
+				value = value.FieldByName(fields[lef].name)
+			}
 			if !value.IsValid() {
 				return left.error(
 					"not_available.identifier",
@@ -376,6 +444,11 @@ func (rt *Runtime) executeLetList(set *SetNode) e.Error {
 }
 
 func (rt *Runtime) executeYieldBlock(block *BlockNode, blockParam, yieldParam *BlockParameterList, expression Expression, content *ListNode) e.Error {
+	if err := rt.limits.enter(); err != nil {
+		return err
+	}
+	defer rt.limits.leave()
+
 	needNewScope := len(blockParam.List) > 0 || len(yieldParam.List) > 0
 	if needNewScope {
 		rt.newScope()
@@ -472,10 +545,19 @@ func (rt *Runtime) executeYieldBlock(block *BlockNode, blockParam, yieldParam *B
 }
 
 func (rt *Runtime) executeList(list *ListNode) (returnValue reflect.Value, err e.Error) {
+	if list.exec != nil {
+		return list.exec(rt)
+	}
 	inNewScope := false // to use just one scope for multiple actions with variable declarations
 
 	for i := 0; i < len(list.Nodes); i++ {
 		node := list.Nodes[i]
+		if err := rt.canceled(node); err != nil {
+			return reflect.Value{}, err
+		}
+		if err := rt.limits.step(node); err != nil {
+			return reflect.Value{}, err
+		}
 
 		switch node.Type() {
 		case NodeText:
@@ -498,14 +580,32 @@ func (rt *Runtime) executeList(list *ListNode) (returnValue reflect.Value, err e
 				}
 			}
 			if node.Pipe != nil {
+				rt.missing = false
 				v, safeWriter, err := rt.evalPipelineExpression(node.Pipe)
+				if rt.missing {
+					rt.missing = false
+					if rt.set.missingKey == MissingKeyKeep {
+						if _, err := io.WriteString(rt.Writer, node.src); err != nil {
+							return reflect.Value{}, node.error("", err.Error())
+						}
+						continue
+					}
+					if err != nil {
+						// MissingKeyZero: a failure caused by the missing value renders nothing
+						continue
+					}
+				}
 				if err != nil {
 					return reflect.Value{}, err
 				}
-				if !safeWriter && v.IsValid() {
-					if v.Type().Implements(rendererType) {
+				if !safeWriter {
+					if v.IsValid() && v.Type().Implements(rendererType) {
 						v.Interface().(Renderer).Render(rt)
-					} else {
+					} else if rt.binder != nil {
+						if err := rt.binder.bind(rt.Writer, node, v); err != nil {
+							return reflect.Value{}, node.error("", err.Error())
+						}
+					} else if v.IsValid() {
 						if _, err := fastprinter.PrintValue(rt.escapeeWriter, v); err != nil {
 							return reflect.Value{}, node.error("", err.Error())
 						}
@@ -570,18 +670,35 @@ func (rt *Runtime) executeList(list *ListNode) (returnValue reflect.Value, err e
 			if err != nil {
 				return reflect.Value{}, node.error("", err.Error())
 			}
+			if _, ok := ranger.(*funcRanger); ok {
+				// stop the iterator even when the body panics; stopping it twice is harmless
+				defer cleanup()
+			}
+			if cr, ok := ranger.(*chanRanger); ok {
+				cr.done = rt.done
+			}
 			if !ranger.ProvidesIndex() {
 				if isSet && len(node.Set.Left) > 1 {
 					// two-vars assignment with ranger that doesn't provide an index
+					cleanup()
 					return reflect.Value{}, node.error("", "two-var range over ranger that does not provide an index")
 				} else if isSet {
 					keyVarSlot, valVarSlot = -1, 0
 				}
 			}
 
-			indexValue, rangeValue, end := ranger.Range()
+			var stop e.Error // set when the execution is canceled or exceeds its limits
+			iteration := newLoopIteration(ranger, rt.iteration)
+			indexValue, rangeValue, end := iteration.next()
 			if !end {
+				rt.iteration = iteration
 				for !end && !returnValue.IsValid() {
+					if stop = rt.canceled(node); stop != nil {
+						break
+					}
+					if stop = rt.limits.iterate(node); stop != nil {
+						break
+					}
 					if isSet {
 						if isLet {
 							if keyVarSlot >= 0 {
@@ -603,16 +720,31 @@ func (rt *Runtime) executeList(list *ListNode) (returnValue reflect.Value, err e
 						rt.context = rangeValue
 					}
 					returnValue, err = rt.executeList(node.List)
-					indexValue, rangeValue, end = ranger.Range()
+					if control := rt.loop; control != loopNone {
+						rt.loop = loopNone
+						if control == loopBreak {
+							break
+						}
+					}
+					indexValue, rangeValue, end = iteration.next()
 				}
-			} else if node.ElseList != nil {
+				rt.iteration = iteration.parent
+			} else if stop = rt.canceled(node); stop == nil && node.ElseList != nil {
+				// a channel range that ends because the context is done is not empty
 				returnValue, err = rt.executeList(node.ElseList)
 			}
+			if stop == nil {
+				// a channel range ends early when the context is done
+				stop = rt.canceled(node)
+			}
 			cleanup()
 			rt.context = context
 			if isLet {
 				rt.releaseScope()
 			}
+			if stop != nil {
+				return reflect.Value{}, stop
+			}
 		case NodeTry:
 			node := node.(*TryNode)
 			returnValue, err = rt.executeTry(node)
@@ -642,15 +774,71 @@ func (rt *Runtime) executeList(list *ListNode) (returnValue reflect.Value, err e
 		case NodeReturn:
 			node := node.(*ReturnNode)
 			returnValue, err = rt.evalPrimaryExpressionGroup(node.Value)
+		case NodeTrans:
+			err = rt.executeTrans(node.(*TransNode))
+		case NodeSwitch:
+			list, err := rt.switchList(node.(*SwitchNode))
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			if list != nil {
+				// errors of the case are dropped, as those of the branches of an if
+				returnValue, _ = rt.executeList(list)
+			}
+		case NodeBreak, NodeContinue:
+			node := node.(*LoopControlNode)
+			if node.Condition != nil {
+				condition, err := rt.evalPrimaryExpressionGroup(node.Condition)
+				if err != nil {
+					return reflect.Value{}, err
+				}
+				if !isTrue(condition) {
+					continue
+				}
+			}
+			rt.loop = loopBreak
+			if node.Type() == NodeContinue {
+				rt.loop = loopContinue
+			}
+		}
+		if rt.loop != loopNone {
+			// a break or continue skips the rest of the lists up to the innermost range
+			return returnValue, err
 		}
 	}
 
 	return returnValue, err
 }
 
+// switchList returns the list of the first case of node matching its subject, or whose value is true when it has
+// none, or its default list.
+func (rt *Runtime) switchList(node *SwitchNode) (*ListNode, e.Error) {
+	var subject reflect.Value
+	if node.Expression != nil {
+		var err e.Error
+		if subject, err = rt.evalPrimaryExpressionGroup(node.Expression); err != nil {
+			return nil, err
+		}
+	}
+	for _, c := range node.Cases {
+		for _, value := range c.Values {
+			v, err := rt.evalPrimaryExpressionGroup(value)
+			if err != nil {
+				return nil, err
+			}
+			if node.Expression == nil && isTrue(v) || node.Expression != nil && checkEquality(subject, v) {
+				return c.List, nil
+			}
+		}
+	}
+	return node.Default, nil
+}
+
 func (rt *Runtime) executeTry(try *TryNode) (returnValue reflect.Value, err e.Error) {
 	writer := rt.Writer
 	buf := new(bytes.Buffer)
+	bound := rt.binder.len()
+	iteration := rt.iteration
 
 	defer func() {
 		r := recover()
@@ -660,6 +848,10 @@ func (rt *Runtime) executeTry(try *TryNode) (returnValue reflect.Value, err e.Er
 			io.Copy(writer, buf)
 		} else {
 			// rt.Writer is already set to its original value since the later defer ran first
+			// the buffered output is dropped, so are the arguments bound while producing it
+			rt.binder.truncate(bound)
+			// the ranges the panic went through are over
+			rt.iteration = iteration
 			if try.Catch != nil {
 				if try.Catch.Err != nil {
 					rt.newScope()
@@ -703,6 +895,11 @@ func (rt *Runtime) executeInclude(node *IncludeNode) (returnValue reflect.Value,
 		return reflect.Value{}, node.error("", getTemplateErr.Error())
 	}
 
+	if err := rt.limits.enter(); err != nil {
+		return reflect.Value{}, err
+	}
+	defer rt.limits.leave()
+
 	rt.newScope()
 	defer rt.releaseScope()
 
@@ -745,6 +942,13 @@ func (rt *Runtime) evalPrimaryExpressionGroup(node Expression) (reflect.Value, e
 		return rt.evalNumericComparativeExpression(node.(*NumericComparativeExprNode))
 	case NodeLogicalExpr:
 		return rt.evalLogicalExpression(node.(*LogicalExprNode))
+	case NodeCoalesceExpr:
+		node := node.(*CoalesceExprNode)
+		left, err := rt.evalIfSet(node.Left)
+		if err != nil || left.IsValid() {
+			return left, err
+		}
+		return rt.evalPrimaryExpressionGroup(node.Right)
 	case NodeNotExpr:
 		notExpression, err := rt.evalPrimaryExpressionGroup(node.(*NotExprNode).Expr)
 		if err != nil {
@@ -785,12 +989,7 @@ func (rt *Runtime) evalPrimaryExpressionGroup(node Expression) (reflect.Value, e
 		if err != nil {
 			return reflect.Value{}, err
 		}
-
-		resolved, err := resolveIndex(base, index, "", node.Nullable)
-		if err != nil {
-			return reflect.Value{}, node.error(err.Reason(), err.Message())
-		}
-		return resolved, nil
+		return rt.indexOf(node, base, index)
 	case NodeSliceExpr:
 		node := node.(*SliceExprNode)
 		baseExpression, err := rt.evalPrimaryExpressionGroup(node.Base)
@@ -804,10 +1003,8 @@ func (rt *Runtime) evalPrimaryExpressionGroup(node Expression) (reflect.Value, e
 			if err != nil {
 				return reflect.Value{}, err
 			}
-			if canNumber(indexExpression.Kind()) {
-				index = int(castInt64(indexExpression))
-			} else {
-				return reflect.Value{}, node.Index.error(e.InvalidValueReason, fmt.Sprintf("non numeric value in index expression kind %s", indexExpression.Kind().String()))
+			if index, err = sliceBound(node.Index, indexExpression); err != nil {
+				return reflect.Value{}, err
 			}
 		}
 
@@ -816,10 +1013,8 @@ func (rt *Runtime) evalPrimaryExpressionGroup(node Expression) (reflect.Value, e
 			if err != nil {
 				return reflect.Value{}, err
 			}
-			if canNumber(indexExpression.Kind()) {
-				length = int(castInt64(indexExpression))
-			} else {
-				return reflect.Value{}, node.EndIndex.error(e.InvalidValueReason, fmt.Sprintf("non numeric value in index expression kind %s", indexExpression.Kind().String()))
+			if length, err = sliceBound(node.EndIndex, indexExpression); err != nil {
+				return reflect.Value{}, err
 			}
 		} else {
 			length = baseExpression.Len()
@@ -830,6 +1025,26 @@ func (rt *Runtime) evalPrimaryExpressionGroup(node Expression) (reflect.Value, e
 	return rt.evalBaseExpressionGroup(node)
 }
 
+// sliceBound returns the value of a bound of a slice expression.
+func sliceBound(node Expression, v reflect.Value) (int, e.Error) {
+	if canNumber(v.Kind()) {
+		return int(castInt64(v)), nil
+	}
+	return 0, node.error(e.InvalidValueReason, fmt.Sprintf("non numeric value in index expression kind %s", v.Kind().String()))
+}
+
+// indexOf evaluates an index expression on the values of its base and index.
+func (rt *Runtime) indexOf(node *IndexExprNode, base, index reflect.Value) (reflect.Value, e.Error) {
+	resolved, err := resolveIndex(base, index, "", node.Nullable, rt.jsonTags)
+	if err != nil {
+		return rt.missingKey(node.error(err.Reason(), err.Message()))
+	}
+	if !resolved.IsValid() && !node.Nullable && isMissingMapKey(base, index) {
+		return rt.missingMapKey(node, index)
+	}
+	return resolved, nil
+}
+
 // notNil returns false when v.IsValid() == false
 // or when v's kind can be nil and v.IsNil() == true
 func notNil(v reflect.Value) bool {
@@ -845,6 +1060,8 @@ func notNil(v reflect.Value) bool {
 }
 
 func (rt *Runtime) isSet(node Node) (ok bool, err e.Error) {
+	// values isset finds missing are not missing values of the action using it
+	defer func(missing bool) { rt.missing = missing }(rt.missing)
 	defer func() {
 		if r := recover(); r != nil {
 			// something panicked while evaluating node
@@ -878,7 +1095,7 @@ func (rt *Runtime) isSet(node Node) (ok bool, err e.Error) {
 			return false, err
 		}
 
-		resolved, err := resolveIndex(base, index, "", node.Nullable)
+		resolved, err := resolveIndex(base, index, "", node.Nullable, rt.jsonTags)
 		return err == nil && notNil(resolved), nil
 	case NodeIdentifier:
 		value, err := rt.resolve(node.String())
@@ -888,7 +1105,7 @@ func (rt *Runtime) isSet(node Node) (ok bool, err e.Error) {
 		resolved := rt.context
 		for i := 0; i < len(node.Idents); i++ {
 			var err error
-			resolved, err = resolveIndex(resolved, reflect.Value{}, node.Idents[i].name, node.Idents[i].lax)
+			resolved, err = resolveIndex(resolved, reflect.Value{}, node.Idents[i].name, node.Idents[i].lax, rt.jsonTags)
 			if err != nil || !notNil(resolved) {
 				return false, nil
 			}
@@ -897,6 +1114,10 @@ func (rt *Runtime) isSet(node Node) (ok bool, err e.Error) {
 		node := node.(*ChainNode)
 		resolved, err := rt.evalChainNodeExpression(node)
 		return err == nil && notNil(resolved), nil
+	case NodeCoalesceExpr:
+		// set when the side the expression evaluates to is, so that a nil left side counts as unset
+		value, err := rt.evalIfSet(node.(*CoalesceExprNode))
+		return value.IsValid(), err
 	default:
 		// todo: maybe work some edge cases
 		if !(nodeType > beginExpressions && nodeType < endExpressions) {
@@ -906,6 +1127,62 @@ func (rt *Runtime) isSet(node Node) (ok bool, err e.Error) {
 	return true, nil
 }
 
+// evalIfSet evaluates the left side of '??' the way isset sees it: a missing variable, field, key or index, a nil
+// value, or a panic give an invalid value rather than an error.
+func (rt *Runtime) evalIfSet(node Expression) (value reflect.Value, err e.Error) {
+	defer func(missing bool) { rt.missing = missing }(rt.missing)
+	defer func() {
+		if r := recover(); r != nil {
+			value, err = reflect.Value{}, nil
+		}
+	}()
+
+	switch node := node.(type) {
+	case *IdentifierNode:
+		if value, err = rt.resolve(node.Ident); err != nil {
+			return reflect.Value{}, nil
+		}
+	case *FieldNode:
+		value = rt.context
+		for _, ident := range node.Idents {
+			var err error
+			if value, err = resolveIndex(value, reflect.Value{}, ident.name, ident.lax, rt.jsonTags); err != nil || !notNil(value) {
+				return reflect.Value{}, nil
+			}
+		}
+	case *ChainNode:
+		if value, err = rt.evalChainNodeExpression(node); err != nil {
+			return reflect.Value{}, nil
+		}
+	case *IndexExprNode:
+		base, err := rt.evalIfSet(node.Base)
+		if err != nil || !base.IsValid() {
+			return reflect.Value{}, err
+		}
+		index, err := rt.evalPrimaryExpressionGroup(node.Index)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		if value, err = resolveIndex(base, index, "", true, rt.jsonTags); err != nil {
+			return reflect.Value{}, nil
+		}
+	case *CoalesceExprNode:
+		// a ?? b ?? c
+		if value, err = rt.evalIfSet(node.Left); err != nil || value.IsValid() {
+			return value, err
+		}
+		return rt.evalIfSet(node.Right)
+	default:
+		if value, err = rt.evalPrimaryExpressionGroup(node); err != nil {
+			return reflect.Value{}, err
+		}
+	}
+	if !notNil(value) {
+		return reflect.Value{}, nil
+	}
+	return value, nil
+}
+
 func (rt *Runtime) evalNumericComparativeExpression(node *NumericComparativeExprNode) (reflect.Value, e.Error) {
 	left, err := rt.evalPrimaryExpressionGroup(node.Left)
 	if err != nil {
@@ -915,6 +1192,10 @@ func (rt *Runtime) evalNumericComparativeExpression(node *NumericComparativeExpr
 	if err != nil {
 		return reflect.Value{}, err
 	}
+	return numericComparison(node, left, right)
+}
+
+func numericComparison(node *NumericComparativeExprNode, left, right reflect.Value) (reflect.Value, e.Error) {
 	isTrue := false
 	kind := left.Kind()
 
@@ -1009,12 +1290,16 @@ func (rt *Runtime) evalLogicalExpression(node *LogicalExprNode) (reflect.Value,
 	if err != nil {
 		return reflect.Value{}, err
 	}
+	return logical(node, truthy, right), nil
+}
+
+func logical(node *LogicalExprNode, truthy bool, right reflect.Value) reflect.Value {
 	if node.Operator.typ == itemAnd {
 		truthy = truthy && isTrue(right)
 	} else {
 		truthy = truthy || isTrue(right)
 	}
-	return reflect.ValueOf(truthy), nil
+	return reflect.ValueOf(truthy)
 }
 
 func (rt *Runtime) evalComparativeExpression(node *ComparativeExprNode) (reflect.Value, e.Error) {
@@ -1026,11 +1311,15 @@ func (rt *Runtime) evalComparativeExpression(node *ComparativeExprNode) (reflect
 	if err != nil {
 		return reflect.Value{}, err
 	}
+	return comparison(node, left, right), nil
+}
+
+func comparison(node *ComparativeExprNode, left, right reflect.Value) reflect.Value {
 	equal := checkEquality(left, right)
 	if node.Operator.typ == itemNotEquals {
-		return reflect.ValueOf(!equal), nil
+		return reflect.ValueOf(!equal)
 	}
-	return reflect.ValueOf(equal), nil
+	return reflect.ValueOf(equal)
 }
 
 func toInt(v reflect.Value) int64 {
@@ -1126,6 +1415,10 @@ func (rt *Runtime) evalMultiplicativeExpression(node *MultiplicativeExprNode) (r
 	if err != nil {
 		return reflect.Value{}, err
 	}
+	return multiplication(node, left, right)
+}
+
+func multiplication(node *MultiplicativeExprNode, left, right reflect.Value) (reflect.Value, e.Error) {
 	kind := left.Kind()
 	// if the left value is not a float and the right is, we need to promote the left value to a float before the calculation
 	// this is necessary for expressions like 4*1.23
@@ -1184,37 +1477,12 @@ func (rt *Runtime) evalMultiplicativeExpression(node *MultiplicativeExprNode) (r
 }
 
 func (rt *Runtime) evalAdditiveExpression(node *AdditiveExprNode) (reflect.Value, e.Error) {
-	isAdditive := node.Operator.typ == itemAdd
 	if node.Left == nil {
 		right, err := rt.evalPrimaryExpressionGroup(node.Right)
 		if err != nil {
 			return reflect.Value{}, err
 		}
-		if !right.IsValid() {
-			return reflect.Value{}, node.error(e.InvalidValueReason, "right side of additive expression is invalid value")
-		}
-		kind := right.Kind()
-		// todo: optimize
-		if isInt(kind) {
-			if isAdditive {
-				return reflect.ValueOf(+right.Int()), nil
-			} else {
-				return reflect.ValueOf(-right.Int()), nil
-			}
-		} else if isUint(kind) {
-			if isAdditive {
-				return right, nil
-			} else {
-				return reflect.ValueOf(-int64(right.Uint())), nil
-			}
-		} else if isFloat(kind) {
-			if isAdditive {
-				return reflect.ValueOf(+right.Float()), nil
-			} else {
-				return reflect.ValueOf(-right.Float()), nil
-			}
-		}
-		return reflect.Value{}, node.Left.error(e.InvalidValueReason, fmt.Sprintf("additive expression: right side %s (%s) is not a numeric value (no left side)", node.Right, getTypeString(right)))
+		return unaryAddition(node, right)
 	}
 
 	left, err := rt.evalPrimaryExpressionGroup(node.Left)
@@ -1225,6 +1493,41 @@ func (rt *Runtime) evalAdditiveExpression(node *AdditiveExprNode) (reflect.Value
 	if err != nil {
 		return reflect.Value{}, err
 	}
+	return addition(node, left, right)
+}
+
+// unaryAddition applies the sign of a unary additive expression (no left side).
+func unaryAddition(node *AdditiveExprNode, right reflect.Value) (reflect.Value, e.Error) {
+	isAdditive := node.Operator.typ == itemAdd
+	if !right.IsValid() {
+		return reflect.Value{}, node.error(e.InvalidValueReason, "right side of additive expression is invalid value")
+	}
+	kind := right.Kind()
+	// todo: optimize
+	if isInt(kind) {
+		if isAdditive {
+			return reflect.ValueOf(+right.Int()), nil
+		} else {
+			return reflect.ValueOf(-right.Int()), nil
+		}
+	} else if isUint(kind) {
+		if isAdditive {
+			return right, nil
+		} else {
+			return reflect.ValueOf(-int64(right.Uint())), nil
+		}
+	} else if isFloat(kind) {
+		if isAdditive {
+			return reflect.ValueOf(+right.Float()), nil
+		} else {
+			return reflect.ValueOf(-right.Float()), nil
+		}
+	}
+	return reflect.Value{}, node.Left.error(e.InvalidValueReason, fmt.Sprintf("additive expression: right side %s (%s) is not a numeric value (no left side)", node.Right, getTypeString(right)))
+}
+
+func addition(node *AdditiveExprNode, left, right reflect.Value) (reflect.Value, e.Error) {
+	isAdditive := node.Operator.typ == itemAdd
 	if !left.IsValid() {
 		return reflect.Value{}, node.error(e.InvalidValueReason, "left side of additive expression is invalid value")
 	}
@@ -1308,19 +1611,19 @@ func (rt *Runtime) evalBaseExpressionGroup(node Node) (reflect.Value, e.Error) {
 	case NodeIdentifier:
 		val, err := rt.resolve(node.(*IdentifierNode).Ident)
 		if err != nil {
-			return reflect.Value{}, node.error(err.Reason(), err.Message())
+			return rt.missingKey(node.error(err.Reason(), err.Message()))
 		}
 		return val, nil
 	case NodeField:
 		node := node.(*FieldNode)
 		resolved := rt.context
 		for i := 0; i < len(node.Idents); i++ {
-			field, err := resolveIndex(resolved, reflect.Value{}, node.Idents[i].name, node.Idents[i].lax)
+			field, err := resolveIndex(resolved, reflect.Value{}, node.Idents[i].name, node.Idents[i].lax, rt.jsonTags)
 			if err != nil {
-				return reflect.Value{}, node.error(err.Reason(), err.Message())
+				return rt.missingKey(node.error(err.Reason(), err.Message()))
 			}
 			if !field.IsValid() {
-				return reflect.Value{}, node.error(e.NotFoundFieldOrMethodReason, fmt.Sprintf("there is no field or method '%s' in %s (.%s)", node.Idents[i].name, getTypeString(resolved), strings.Join(node.Idents.names(), ".")))
+				return rt.missingKey(node.error(e.NotFoundFieldOrMethodReason, fmt.Sprintf("there is no field or method '%s' in %s (.%s)", node.Idents[i].name, getTypeString(resolved), strings.Join(node.Idents.names(), "."))))
 			}
 			resolved = field
 		}
@@ -1403,22 +1706,36 @@ func (rt *Runtime) evalChainNodeExpression(node *ChainNode) (reflect.Value, e.Er
 	if err != nil {
 		return reflect.Value{}, err
 	}
+	return rt.chainFields(node, resolved, nil)
+}
 
+// chainFields resolves the fields of a chain on the value of its base. names holds the reflect.Value of each
+// field's name, or is nil to make them on the fly.
+func (rt *Runtime) chainFields(node *ChainNode, resolved reflect.Value, names []reflect.Value) (reflect.Value, e.Error) {
 	for i := 0; i < len(node.Field); i++ {
 		lax := node.Field[i].lax
-		field, err := resolveIndex(resolved, reflect.ValueOf(node.Field[i].name), node.Field[i].name, lax)
+		var name reflect.Value
+		if names != nil {
+			name = names[i]
+		} else {
+			name = reflect.ValueOf(node.Field[i].name)
+		}
+		field, err := resolveIndex(resolved, name, node.Field[i].name, lax, rt.jsonTags)
 		if err != nil {
-			return reflect.Value{}, node.error(err.Reason(), err.Message())
+			return rt.missingKey(node.error(err.Reason(), err.Message()))
 		}
 		if !field.IsValid() {
 			if resolved.Kind() == reflect.Map && i == len(node.Field)-1 {
 				// return reflect.Zero(resolved.Type().Elem()), nil
+				if !lax && isMissingMapKey(resolved, name) {
+					return rt.missingMapKey(node, name)
+				}
 				return reflect.Value{}, nil
 			}
 			if !lax {
-				return reflect.Value{}, e.New().
+				return rt.missingKey(e.New().
 					WithReason(e.NotFoundFieldOrMethodReason).
-					WithMessage(fmt.Sprintf("there is no field or method '%s' in %s (%s)", node.Field[i].name, getTypeString(resolved), node))
+					WithMessage(fmt.Sprintf("there is no field or method '%s' in %s (%s)", node.Field[i].name, getTypeString(resolved), node)))
 			}
 			field = reflect.ValueOf(nil)
 		}
@@ -1459,6 +1776,10 @@ func (rt *Runtime) evalSafeWriter(term reflect.Value, node *CommandNode, v ...re
 }
 
 func (rt *Runtime) evalCommandPipeExpression(node *CommandNode, value reflect.Value) (reflect.Value, bool, e.Error) {
+	if node.filter.IsValid() {
+		ret, err := rt.evalFilter(node, value)
+		return ret, false, err
+	}
 	term, err := rt.evalPrimaryExpressionGroup(node.BaseExpr)
 	if err != nil {
 		return reflect.Value{}, false, err
@@ -1491,6 +1812,9 @@ func (rt *Runtime) evalPipelineExpression(node *PipeNode) (value reflect.Value,
 			return reflect.Value{}, false, node.Cmds[i].error(e.UnexpectedCommandReason, fmt.Sprintf("unexpected command %s, writer command should be the last command", node.Cmds[i]))
 		}
 		value, safeWriter, err = rt.evalCommandPipeExpression(node.Cmds[i], value)
+		if err != nil {
+			return reflect.Value{}, false, err
+		}
 	}
 	return
 }
@@ -1502,17 +1826,19 @@ func (rt *Runtime) evaluateArgs(fnType reflect.Type, args CallArgs, pipedArg *re
 	}
 	numArgsRequired := fnType.NumIn()
 	isVariadic := fnType.IsVariadic()
-	invalidNumOfArgsError := e.New().
-		WithReason(e.InvalidNumberOfArgumentsReason).
-		WithMessage(fmt.Sprintf("%s needs at least %d arguments, but have %d", fnType, numArgsRequired, numArgs))
+	invalidNumOfArgsError := func() e.Error {
+		return e.New().
+			WithReason(e.InvalidNumberOfArgumentsReason).
+			WithMessage(fmt.Sprintf("%s needs at least %d arguments, but have %d", fnType, fnType.NumIn(), numArgs))
+	}
 	if isVariadic {
 		numArgsRequired--
 		if numArgs < numArgsRequired {
-			return nil, invalidNumOfArgsError
+			return nil, invalidNumOfArgsError()
 		}
 	} else {
 		if numArgs != numArgsRequired {
-			return nil, invalidNumOfArgsError
+			return nil, invalidNumOfArgsError()
 		}
 	}
 
@@ -1534,8 +1860,11 @@ func (rt *Runtime) evaluateArgs(fnType reflect.Type, args CallArgs, pipedArg *re
 
 	i := 0 // index in parsed argument expression list
 
-	invalidArgError := e.InvalidValueErr.
-		WithMessage(fmt.Sprintf("argument for position %d in %s is not a valid value", slot, fnType))
+	position := slot // reported by invalidArgError
+	invalidArgError := func() e.Error {
+		return e.InvalidValueErr.
+			WithMessage(fmt.Sprintf("argument for position %d in %s is not a valid value", position, fnType))
+	}
 
 	var err e.Error
 	for slot < numArgsRequired {
@@ -1550,7 +1879,7 @@ func (rt *Runtime) evaluateArgs(fnType reflect.Type, args CallArgs, pipedArg *re
 			}
 		}
 		if !term.IsValid() {
-			return nil, invalidArgError
+			return nil, invalidArgError()
 		}
 		if !term.Type().AssignableTo(in) {
 			term = term.Convert(in)
@@ -1573,7 +1902,7 @@ func (rt *Runtime) evaluateArgs(fnType reflect.Type, args CallArgs, pipedArg *re
 				}
 			}
 			if !term.IsValid() {
-				return nil, invalidArgError
+				return nil, invalidArgError()
 			}
 			if !term.Type().AssignableTo(in) {
 				term = term.Convert(in)
@@ -1728,6 +2057,7 @@ func castInt64(v reflect.Value) int64 {
 var (
 	cachedStructsMutex      = sync.RWMutex{}
 	cachedStructsFieldIndex = map[reflect.Type]map[string][]int{}
+	cachedStructsFields     = [2]map[reflect.Type]map[string][]int{{}, {}} // by Go name, by json name
 )
 
 // from text/template's exec.go:
@@ -1774,7 +2104,9 @@ func indirectEface(v reflect.Value) reflect.Value {
 // complex, it improves the memory allocation story for the most common
 // execution paths when executing a template, such as when accessing a field
 // element.
-func resolveIndex(v, index reflect.Value, indexAsStr string, lax bool) (reflect.Value, e.Error) {
+// resolveIndex returns the method, field, element or map value of v named by indexAsStr or index. With jsonTags,
+// the fields of structs are looked up by the name in their json tag first.
+func resolveIndex(v, index reflect.Value, indexAsStr string, lax, jsonTags bool) (reflect.Value, e.Error) {
 	if !v.IsValid() {
 		if lax {
 			return reflect.Value{}, nil
@@ -1783,6 +2115,12 @@ func resolveIndex(v, index reflect.Value, indexAsStr string, lax bool) (reflect.
 			WithReason(e.NotFoundFieldOrMethodReason).
 			WithMessage(fmt.Sprintf("there is no field or method '%s' in nil", indexAsStr))
 	}
+	if v.Type() == loopIterationType {
+		if indexAsStr == "" && index.Kind() == reflect.String {
+			indexAsStr = index.String()
+		}
+		return v.Interface().(*loopIteration).field(indexAsStr, lax)
+	}
 
 	v, isNil := indirect(v)
 	if v.Kind() == reflect.Interface && isNil {
@@ -1850,6 +2188,18 @@ func resolveIndex(v, index reflect.Value, indexAsStr string, lax bool) (reflect.
 		}
 		typ := v.Type()
 		key := indexAsStr
+		if jsonTags {
+			if id, ok := structFields(typ, true)[key]; ok {
+				return indirectEface(v.FieldByIndex(id)), nil
+			}
+			if lax {
+				return reflect.Value{}, nil
+			}
+			return reflect.Value{}, e.InvalidIndexErr.
+				WithMessage(fmt.Sprintf("can't use '%s' as field name in struct type %s", indexAsStr, v.Type())).
+				WithDetail("object", v.String()).
+				WithDetail("index", fmt.Sprintf("%v", index))
+		}
 
 		// Fast path: use the struct cache to avoid allocations.
 		cachedStructsMutex.RLock()
@@ -1943,6 +2293,59 @@ func indexArg(index reflect.Value, cap int) (int, e.Error) {
 	return int(x), nil
 }
 
+// structFields returns the indexes of the exported fields of the struct type typ, including the fields promoted
+// from embedded structs, by Go name or, with jsonTags, by the name encoding/json gives them.
+func structFields(typ reflect.Type, jsonTags bool) map[string][]int {
+	cached := cachedStructsFields[0]
+	if jsonTags {
+		cached = cachedStructsFields[1]
+	}
+	cachedStructsMutex.RLock()
+	fields, ok := cached[typ]
+	cachedStructsMutex.RUnlock()
+	if ok {
+		return fields
+	}
+	cachedStructsMutex.Lock()
+	defer cachedStructsMutex.Unlock()
+	if fields, ok = cached[typ]; !ok {
+		fields = map[string][]int{}
+		buildFieldNames(typ, jsonTags, fields, nil)
+		cached[typ] = fields
+	}
+	return fields
+}
+
+// buildFieldNames adds the exported fields of typ to fields; shallower fields hide the fields they promote.
+func buildFieldNames(typ reflect.Type, jsonTags bool, fields map[string][]int, parent []int) {
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		index := append(append(make([]int, 0, len(parent)+1), parent...), i)
+
+		name := field.Name
+		if jsonTags {
+			tag, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if tag == "-" {
+				continue
+			}
+			if tag != "" {
+				name = tag
+			} else if field.Anonymous && field.Type.Kind() == reflect.Struct {
+				buildFieldNames(field.Type, jsonTags, fields, index)
+				continue
+			}
+		} else if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			buildFieldNames(field.Type, jsonTags, fields, index)
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if other, ok := fields[name]; !ok || len(other) > len(index) {
+			fields[name] = index
+		}
+	}
+}
+
 func buildCache(typ reflect.Type, cache map[string][]int, parent []int) {
 	numFields := typ.NumField()
 	max := len(parent) + 1
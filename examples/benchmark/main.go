@@ -0,0 +1,80 @@
+// Command benchmark compares rendering templates with the tree-walking interpreter and with templates compiled to
+// closures (see jet.WithClosureCompilation).
+//
+//	go run ./examples/benchmark
+package main
+
+import (
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/oarkflow/jet"
+)
+
+type order struct {
+	ID    int
+	Items []item
+}
+
+type item struct {
+	Name     string
+	Quantity int
+	Price    float64
+}
+
+var templates = map[string]string{
+	"/text.jet":   `Hello, this notification has no actions at all.`,
+	"/simple.jet": `Hi {{ first_name }} {{ last_name }}, your code is {{ code }}.`,
+	"/arith.jet":  `{{ range i := numbers }}{{ i * 2 - 1 }},{{ end }} {{ 60 * 60 * 24 }} {{ "a" + "b" + "c" }} {{ 1 < 2 && !false }}`,
+	"/notification.jet": `{{ block line(it) }}{{ it.Name | upper }} x{{ it.Quantity }} = {{ it.Price * it.Quantity }}
+{{ end }}Dear {{ user.name }},
+{{ if user.vip }}Thank you for being a VIP customer!{{ else }}Thank you for your order.{{ end }}
+Order #{{ order.ID }}:
+{{ range order.Items }}{{ yield line(it=.) }}{{ else }}(no items){{ end }}
+{{ len(order.Items) > 2 ? "Free shipping" : "Standard shipping" }} to {{ user.address.city }}.`,
+}
+
+func vars() jet.VarMap {
+	return make(jet.VarMap).
+		Set("first_name", "Ann").
+		Set("last_name", "Smith").
+		Set("code", 123456).
+		Set("numbers", make([]int, 50)).
+		Set("user", map[string]any{"name": "Ann", "vip": true, "address": map[string]any{"city": "Kathmandu"}}).
+		Set("order", order{ID: 42, Items: []item{{"apple", 3, 0.5}, {"pear", 1, 0.75}, {"plum", 12, 0.2}}})
+}
+
+func newSet(opts ...jet.Option) *jet.Set {
+	loader := jet.NewInMemLoader()
+	for name, contents := range templates {
+		loader.Set(name, contents)
+	}
+	return jet.NewSet(loader, opts...)
+}
+
+func bench(set *jet.Set, name string) testing.BenchmarkResult {
+	t, err := set.GetTemplate(name)
+	if err != nil {
+		panic(err)
+	}
+	vars := vars()
+	return testing.Benchmark(func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if err := t.Execute(io.Discard, vars, nil); err != nil {
+				panic(err)
+			}
+		}
+	})
+}
+
+func main() {
+	interpreted := newSet()
+	compiled := newSet(jet.WithClosureCompilation())
+	for _, name := range []string{"/text.jet", "/simple.jet", "/arith.jet", "/notification.jet"} {
+		i, c := bench(interpreted, name), bench(compiled, name)
+		fmt.Printf("%-18s interpreter %8d ns/op %6d allocs/op   closures %8d ns/op %6d allocs/op   %.2fx\n",
+			name, i.NsPerOp(), i.AllocsPerOp(), c.NsPerOp(), c.AllocsPerOp(), float64(i.NsPerOp())/float64(c.NsPerOp()))
+	}
+}
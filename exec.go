@@ -53,13 +53,27 @@ func (scope VarMap) SetWriter(name string, v SafeWriter) VarMap {
 
 // Execute executes the template into w.
 func (t *Template) Execute(w io.Writer, variables VarMap, data interface{}) (err error) {
+	return t.execute(w, variables, data, nil)
+}
+
+// execute runs the template with a pooled Runtime; setup, if not nil, is called
+// once the Runtime is initialised and may attach execution-scoped state to it.
+func (t *Template) execute(w io.Writer, variables VarMap, data interface{}, setup func(*Runtime)) (err error) {
 	st := pool_State.Get().(*Runtime)
 	defer st.recover(&err)
 
 	st.blocks = t.processedBlocks
 	st.variables = variables
 	st.set = t.set
+	st.jsonTags = t.set.jsonTags
 	st.Writer = w
+	st.limits = newLimiter(t.set.limits)
+	if setup != nil {
+		setup(st)
+	}
+	if st.limits != nil && st.limits.MaxOutputBytes > 0 {
+		st.Writer = &limitWriter{w: st.Writer, l: st.limits}
+	}
 
 	// resolve extended template
 	for t.extends != nil {
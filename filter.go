@@ -0,0 +1,135 @@
+package jet
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/oarkflow/jet/utils/e"
+)
+
+// defaultFilters holds the builtin filters, used when no filter of the same name is registered in the Set.
+var defaultFilters = map[string]reflect.Value{
+	// default returns its argument instead of the piped value when that is unset, nil or a zero value
+	"default": reflect.ValueOf(Func(func(a Arguments) reflect.Value {
+		a.RequireNumOfArguments("default", 2, 2)
+		if value := a.Get(0); isTrue(value) {
+			return value
+		}
+		return a.Get(1)
+	})),
+	"truncate": reflect.ValueOf(truncate),
+}
+
+// truncate cuts s after n characters, marking the cut with an ellipsis.
+func truncate(s string, n int) string {
+	if n < 0 {
+		n = 0
+	}
+	for i := range s {
+		if n == 0 {
+			return s[:i] + "..."
+		}
+		n--
+	}
+	return s
+}
+
+var (
+	errorType = reflect.TypeOf((*error)(nil)).Elem()
+	pipeSlot  = &UnderscoreNode{NodeBase: NodeBase{NodeType: NodeUnderscore}}
+)
+
+// RegisterFilter adds a filter into the Set, overriding any filter previously registered under name, including
+// the builtin default and truncate. Filters are applied after a pipe, with their arguments after a colon or
+// between parentheses:
+//
+//	{{ title | truncate: 20 }}
+//	{{ user.Name | default("n/a") }}
+//
+// fn receives the piped value followed by the arguments, converted to the types of its parameters, and returns
+// the result, optionally followed by an error that fails the render. fn can also be a Func, whose first argument
+// is the piped value. Filters are resolved when templates are parsed, so they must be registered before the
+// templates using them are parsed, and the colon syntax fails to parse with a name that is neither a filter nor a
+// global. A global or builtin function of the same name takes precedence over a filter; variables don't, as they
+// are only known when the template is executed. RegisterFilter panics if fn is neither.
+// It returns the Set it was called on to allow for method chaining.
+func (s *Set) RegisterFilter(name string, fn interface{}) *Set {
+	v := reflect.ValueOf(fn)
+	if err := checkFilter(v); err != nil {
+		panic(fmt.Errorf("jet: RegisterFilter(%q): %w", name, err))
+	}
+	if funcType.AssignableTo(v.Type()) {
+		v = v.Convert(funcType)
+	}
+	s.gmx.Lock()
+	defer s.gmx.Unlock()
+	s.filters[name] = v
+	return s
+}
+
+// lookupFilter returns the filter registered under name, or else the builtin filter of that name.
+func (s *Set) lookupFilter(name string) (reflect.Value, bool) {
+	if s != nil {
+		s.gmx.RLock()
+		filter, ok := s.filters[name]
+		s.gmx.RUnlock()
+		if ok {
+			return filter, true
+		}
+	}
+	filter, ok := defaultFilters[name]
+	return filter, ok
+}
+
+// checkFilter tells why fn can't be used as a filter, if it can't.
+func checkFilter(fn reflect.Value) error {
+	if !fn.IsValid() || fn.Kind() != reflect.Func || fn.IsNil() {
+		return errors.New("filter must be a non-nil function")
+	}
+	typ := fn.Type()
+	if funcType.AssignableTo(typ) {
+		return nil
+	}
+	if typ.NumIn() == 0 {
+		return fmt.Errorf("filter %s takes no piped value", typ)
+	}
+	switch {
+	case typ.NumOut() == 1 && typ.Out(0) != errorType:
+	case typ.NumOut() == 2 && typ.Out(1) == errorType:
+	default:
+		return fmt.Errorf("filter %s must return a value, optionally followed by an error", typ)
+	}
+	return nil
+}
+
+// commandFilter returns the filter applied by a command named name after a pipe, decided once when the template is
+// parsed: globals and builtin functions of the same name take precedence over filters.
+func (s *Set) commandFilter(name string) (reflect.Value, bool) {
+	if isGlobal(s, name) {
+		return reflect.Value{}, false
+	}
+	return s.lookupFilter(name)
+}
+
+// evalFilter applies the filter of node to value.
+func (rt *Runtime) evalFilter(node *CommandNode, value reflect.Value) (reflect.Value, e.Error) {
+	filter := node.filter
+	if filter.Type() == funcType {
+		args := node.CallArgs
+		if !args.HasPipeSlot {
+			// Arguments only yields the piped value through a slot
+			args = CallArgs{Exprs: append([]Expression{pipeSlot}, args.Exprs...), HasPipeSlot: true}
+		}
+		return filter.Interface().(Func)(Arguments{runtime: rt, args: args, pipedVal: &value}), nil
+	}
+	args, err := rt.evaluateArgs(filter.Type(), node.CallArgs, &value)
+	if err != nil {
+		return reflect.Value{}, node.BaseExpr.error("invalid.call", fmt.Sprintf("filter %s: %v", node.BaseExpr, err))
+	}
+	returns := filter.Call(args)
+	if len(returns) == 2 && !returns[1].IsNil() {
+		return reflect.Value{}, node.BaseExpr.error("", fmt.Sprintf("filter %s: %v", node.BaseExpr, returns[1].Interface()))
+	}
+	return returns[0], nil
+}
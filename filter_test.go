@@ -0,0 +1,90 @@
+package jet
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func newFilterSet() *Set {
+	s := NewSet(NewInMemLoader())
+	s.RegisterFilter("wrap", func(v, left, right string) string { return left + v + right })
+	s.RegisterFilter("fail", func(v string) (string, error) { return "", errors.New("filter failed") })
+	return s
+}
+
+func TestFilters(t *testing.T) {
+	tests := []struct {
+		template, want string
+	}{
+		{`{{ s | truncate: 3 }}`, "hél..."},
+		{`{{ s | truncate(10) }}`, "héllo"},
+		{`{{ empty | default("n/a") }}`, "n/a"},
+		{`{{ s | default: "n/a" }}`, "héllo"},
+		{`{{ s | wrap: "[", "]" }}`, "[héllo]"},
+		{`{{ s | wrap: "(", ")" | truncate: 2 }}`, "(h..."},
+		{`{{ s | upper }}`, "HÉLLO"},
+	}
+	vars := VarMap{}.Set("s", "héllo").Set("empty", "")
+	for _, tt := range tests {
+		tmpl, err := newFilterSet().parseString(tt.template)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.template, err)
+		}
+		var out strings.Builder
+		if err := tmpl.Execute(&out, vars, nil); err != nil {
+			t.Fatalf("%s: %v", tt.template, err)
+		}
+		if out.String() != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.template, out.String(), tt.want)
+		}
+		again, err := newFilterSet().parseString(tmpl.String())
+		if err != nil || again.String() != tmpl.String() {
+			t.Errorf("%s: String() = %q doesn't round-trip: %v", tt.template, tmpl.String(), err)
+		}
+	}
+}
+
+func TestFilterPrecedence(t *testing.T) {
+	s := newFilterSet()
+	s.AddGlobal("fn", func(s string, _ int) string { return s })
+	s.AddGlobal("upper", func(s string) string { return "global " + s })
+	tmpl, err := s.parseString(`{{ "a" | fn: 1 }}|{{ "b" | wrap: "(", ")" }}|{{ "c" | upper }}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// filters are resolved at parse time: a global hides a filter, a variable doesn't
+	vars := VarMap{}.Set("wrap", func(s, _, _ string) string { return "variable " + s })
+	var out strings.Builder
+	if err := tmpl.Execute(&out, vars, nil); err != nil {
+		t.Fatal(err)
+	}
+	if want := "a|(b)|global c"; out.String() != want {
+		t.Errorf("got %q, want %q", out.String(), want)
+	}
+}
+
+func TestFilterErrors(t *testing.T) {
+	s := newFilterSet()
+	tmpl, err := s.parseString(`{{ s | fail }}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tmpl.Execute(&strings.Builder{}, VarMap{}.Set("s", "x"), nil); err == nil || !strings.Contains(err.Error(), "filter failed") {
+		t.Errorf("got %v, want the error of the filter", err)
+	}
+	for _, text := range []string{`{{ s | unknown: 1 }}`, `{{ s | unknown: 1 | upper }}`} {
+		if _, err := s.parseString(text); err == nil || !strings.Contains(err.Error(), `unknown filter "unknown"`) {
+			t.Errorf("%s: got %v, want an unknown filter error at parse time", text, err)
+		}
+	}
+	if _, err := s.parseString(`{{ s | unknown(1) }}`); err != nil {
+		t.Errorf("a call after a pipe may name a variable: %v", err)
+	}
+	defer func() {
+		if recover() == nil {
+			t.Error("RegisterFilter accepted a function without parameters")
+		}
+	}()
+	s.RegisterFilter("bad", func() string { return "" })
+}
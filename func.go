@@ -91,7 +91,7 @@ func (a *Arguments) NumOfArguments() int {
 	return num
 }
 
-// Runtime get the Runtime context
+// Runtime get the Runtime context; its ExecutionContext is the context passed to ExecuteContext
 func (a *Arguments) Runtime() *Runtime {
 	return a.runtime
 }
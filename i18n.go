@@ -0,0 +1,317 @@
+package jet
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/oarkflow/jet/utils/e"
+)
+
+// Translator looks up the messages rendered by trans actions and msg blocks.
+type Translator interface {
+	// Translate returns the text for tr in the given locale and whether a translation was found.
+	// The returned text is written to the output as-is (escaped by the Set's SafeWriter).
+	Translate(locale string, tr *Translation) (text string, found bool)
+}
+
+// Translation describes a message requested by a trans action or msg block.
+type Translation struct {
+	Key   string                 // message key
+	Args  []interface{}          // positional arguments, in order
+	Named map[string]interface{} // name=value arguments, including count
+
+	// Count is the value of the `count` argument, which selects the plural form.
+	// HasCount reports whether a count argument was given.
+	Count    int64
+	HasCount bool
+}
+
+// Format fills the placeholders in text: `{0}`, `{1}`, ... are replaced by positional arguments and `{name}` by the
+// named argument `name`. Placeholders without a matching argument are kept as they are.
+func (tr *Translation) Format(text string) string {
+	if len(tr.Args) == 0 && len(tr.Named) == 0 {
+		return text
+	}
+	var b strings.Builder
+	for {
+		start := strings.IndexByte(text, '{')
+		if start < 0 {
+			break
+		}
+		end := strings.IndexByte(text[start:], '}')
+		if end < 0 {
+			break
+		}
+		end += start
+		name := text[start+1 : end]
+		var arg interface{}
+		found := false
+		if i, err := strconv.Atoi(name); err == nil {
+			if i >= 0 && i < len(tr.Args) {
+				arg, found = tr.Args[i], true
+			}
+		} else {
+			arg, found = tr.Named[name]
+		}
+		if found {
+			b.WriteString(text[:start])
+			fmt.Fprint(&b, arg)
+		} else {
+			b.WriteString(text[:end+1])
+		}
+		text = text[end+1:]
+	}
+	b.WriteString(text)
+	return b.String()
+}
+
+// WithTranslator returns an option function that sets the Translator used by trans actions and msg blocks.
+// Without a Translator, trans renders its key (with placeholders filled) and msg renders its body.
+func WithTranslator(tr Translator) Option {
+	return func(s *Set) {
+		s.translator = tr
+	}
+}
+
+// WithLocale returns an option function that sets the locale used when executing without an explicit locale.
+func WithLocale(locale string) Option {
+	return func(s *Set) {
+		s.locale = locale
+	}
+}
+
+// ExecuteLocale executes the template like Execute, translating trans actions and msg blocks into locale.
+func (t *Template) ExecuteLocale(w io.Writer, locale string, variables VarMap, data interface{}) error {
+	return t.execute(w, variables, data, func(rt *Runtime) {
+		rt.locale = locale
+	})
+}
+
+// Locale returns the locale of the current execution.
+func (rt *Runtime) Locale() string {
+	if rt.locale == "" && rt.set != nil {
+		return rt.set.locale
+	}
+	return rt.locale
+}
+
+func (rt *Runtime) executeTrans(node *TransNode) e.Error {
+	tr := &Translation{}
+	if node.Key != nil {
+		key, err := rt.evalPrimaryExpressionGroup(node.Key)
+		if err != nil {
+			return err
+		}
+		if !key.IsValid() {
+			return node.Key.error(e.InvalidValueReason, "message key is not a valid value")
+		}
+		if key.Kind() == reflect.String {
+			tr.Key = key.String()
+		} else {
+			tr.Key = fmt.Sprint(key.Interface())
+		}
+	} else {
+		tr.Key = node.List.String()
+	}
+
+	for _, arg := range node.Args {
+		v, err := rt.evalPrimaryExpressionGroup(arg)
+		if err != nil {
+			return err
+		}
+		tr.Args = append(tr.Args, valueInterface(v))
+	}
+	if len(node.Named) > 0 {
+		tr.Named = make(map[string]interface{}, len(node.Named))
+	}
+	for _, arg := range node.Named {
+		v, err := rt.evalPrimaryExpressionGroup(arg.Value)
+		if err != nil {
+			return err
+		}
+		tr.Named[arg.Name] = valueInterface(v)
+		if arg.Name == "count" {
+			if !v.IsValid() || !canNumber(v.Kind()) {
+				return arg.Value.error(e.InvalidValueReason, fmt.Sprintf("count argument must be a number, got %s", getTypeString(v)))
+			}
+			tr.Count, tr.HasCount = castInt64(v), true
+		}
+	}
+
+	var text string
+	found := false
+	if rt.set.translator != nil {
+		text, found = rt.set.translator.Translate(rt.Locale(), tr)
+	}
+	if !found {
+		if node.List != nil {
+			_, err := rt.executeList(node.List)
+			return err
+		}
+		text = tr.Format(tr.Key)
+	}
+	if _, err := io.WriteString(rt.escapeeWriter, text); err != nil {
+		return node.error("", err.Error())
+	}
+	return nil
+}
+
+// valueInterface returns the value held by v, or nil if v is invalid.
+func valueInterface(v reflect.Value) interface{} {
+	if !v.IsValid() || !v.CanInterface() {
+		return nil
+	}
+	return v.Interface()
+}
+
+// PluralRule returns the plural category ("zero", "one", "two", "few", "many" or "other") for a count.
+type PluralRule func(n int64) string
+
+// defaultPluralRule implements the rule of English and most Germanic and Romance languages.
+func defaultPluralRule(n int64) string {
+	if n == 1 {
+		return "one"
+	}
+	return "other"
+}
+
+// InMemTranslator is a Translator holding message catalogs in memory.
+// A message has one or more forms keyed by plural category; messages without plural forms only have "other".
+// Lookups for a regional locale such as "de-AT" fall back to the base language "de".
+// It is safe for concurrent use.
+type InMemTranslator struct {
+	lock     sync.RWMutex
+	catalogs map[string]map[string]map[string]string // locale -> key -> plural category -> text
+	rules    map[string]PluralRule
+}
+
+// compile time check that we implement Translator
+var _ Translator = (*InMemTranslator)(nil)
+
+// NewInMemTranslator returns a new InMemTranslator.
+func NewInMemTranslator() *InMemTranslator {
+	return &InMemTranslator{
+		catalogs: map[string]map[string]map[string]string{},
+		rules:    map[string]PluralRule{},
+	}
+}
+
+// Set adds a message without plural forms.
+func (t *InMemTranslator) Set(locale, key, text string) {
+	t.SetPlural(locale, key, map[string]string{"other": text})
+}
+
+// SetPlural adds a message with plural forms keyed by plural category. The "zero" form, if present, is used for a
+// count of 0 regardless of the locale's plural rule; "other" is used when the rule's category has no form.
+func (t *InMemTranslator) SetPlural(locale, key string, forms map[string]string) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	catalog, ok := t.catalogs[locale]
+	if !ok {
+		catalog = map[string]map[string]string{}
+		t.catalogs[locale] = catalog
+	}
+	catalog[key] = forms
+}
+
+// SetPluralRule sets the plural rule for locale. Locales without a rule use the English rule.
+func (t *InMemTranslator) SetPluralRule(locale string, rule PluralRule) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.rules[locale] = rule
+}
+
+// Translate implements Translator.
+func (t *InMemTranslator) Translate(locale string, tr *Translation) (string, bool) {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+	for locale != "" {
+		if forms, ok := t.catalogs[locale][tr.Key]; ok {
+			if text, ok := t.pluralForm(locale, forms, tr); ok {
+				return tr.Format(text), true
+			}
+		}
+		i := strings.LastIndexAny(locale, "-_")
+		if i < 0 {
+			break
+		}
+		locale = locale[:i]
+	}
+	return "", false
+}
+
+func (t *InMemTranslator) pluralForm(locale string, forms map[string]string, tr *Translation) (string, bool) {
+	if tr.HasCount {
+		if text, ok := forms["zero"]; ok && tr.Count == 0 {
+			return text, true
+		}
+		rule, ok := t.rules[locale]
+		if !ok {
+			rule = defaultPluralRule
+		}
+		if text, ok := forms[rule(tr.Count)]; ok {
+			return text, true
+		}
+	}
+	text, ok := forms["other"]
+	return text, ok
+}
+
+// LoadJSON adds the messages of a JSON catalog to locale. The catalog is an object mapping keys to either a
+// string or an object of plural forms:
+//
+//	{
+//	  "greeting": "Hello {name}!",
+//	  "inbox": {"zero": "No messages", "one": "One message", "other": "{count} messages"}
+//	}
+func (t *InMemTranslator) LoadJSON(locale string, r io.Reader) error {
+	var catalog map[string]json.RawMessage
+	if err := json.NewDecoder(r).Decode(&catalog); err != nil {
+		return fmt.Errorf("jet: loading %s catalog: %w", locale, err)
+	}
+	for key, raw := range catalog {
+		raw = bytes.TrimSpace(raw)
+		if len(raw) > 0 && raw[0] == '{' {
+			var forms map[string]string
+			if err := json.Unmarshal(raw, &forms); err != nil {
+				return fmt.Errorf("jet: loading %s catalog: message %q: %w", locale, key, err)
+			}
+			t.SetPlural(locale, key, forms)
+			continue
+		}
+		var text string
+		if err := json.Unmarshal(raw, &text); err != nil {
+			return fmt.Errorf("jet: loading %s catalog: message %q: %w", locale, key, err)
+		}
+		t.Set(locale, key, text)
+	}
+	return nil
+}
+
+// LoadJSONFS loads every `<locale>.json` file in dir of fsys using LoadJSON.
+func (t *InMemTranslator) LoadJSONFS(fsys fs.FS, dir string) error {
+	files, err := fs.Glob(fsys, path.Join(dir, "*.json"))
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		f, err := fsys.Open(file)
+		if err != nil {
+			return err
+		}
+		err = t.LoadJSON(strings.TrimSuffix(path.Base(file), ".json"), f)
+		f.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
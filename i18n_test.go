@@ -0,0 +1,85 @@
+package jet
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func newTranslator(t *testing.T) *InMemTranslator {
+	tr := NewInMemTranslator()
+	fsys := fstest.MapFS{
+		"i18n/de.json": {Data: []byte(`{
+			"hello": "Hallo {0}!",
+			"inbox": {"zero": "Keine Nachrichten", "one": "Eine Nachricht", "other": "{count} Nachrichten"},
+			"bye": "Tschüss {name}"
+		}`)},
+		"i18n/fr.json": {Data: []byte(`{"hello": "Bonjour {0} !"}`)},
+	}
+	if err := tr.LoadJSONFS(fsys, "i18n"); err != nil {
+		t.Fatal(err)
+	}
+	return tr
+}
+
+func TestTrans(t *testing.T) {
+	tests := []struct {
+		locale, template, want string
+	}{
+		{"de", `{{ trans "hello" name }}`, "Hallo Ann!"},
+		{"de-AT", `{{ trans "hello" name }}`, "Hallo Ann!"},
+		{"fr", `{{ trans "hello" name }}`, "Bonjour Ann !"},
+		{"en", `{{ trans "hello {0}" name }}`, "hello Ann"},
+		{"de", `{{ trans "inbox" count=0 }}|{{ trans "inbox" count=1 }}|{{ trans "inbox" count=5 }}`, "Keine Nachrichten|Eine Nachricht|5 Nachrichten"},
+		{"de", `{{ msg "bye" name=name }}Bye {{ name }}{{ end }}`, "Tschüss Ann"},
+		{"en", `{{ msg "bye" name=name }}Bye {{ name }}{{ end }}`, "Bye Ann"},
+		{"en", `{{ msg }}Untranslated <b>{{ name }}</b>{{ end }}`, "Untranslated <b>Ann</b>"},
+		{"de", `{{ trans "<{0}>" "x" }}`, "&lt;x&gt;"},
+	}
+	s := NewSet(NewInMemLoader(), WithTranslator(newTranslator(t)))
+	for _, tt := range tests {
+		tmpl, err := s.parseString(tt.template)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.template, err)
+		}
+		var out strings.Builder
+		if err := tmpl.ExecuteLocale(&out, tt.locale, VarMap{}.Set("name", "Ann"), nil); err != nil {
+			t.Fatalf("%s: %v", tt.template, err)
+		}
+		if out.String() != tt.want {
+			t.Errorf("%s in %s: got %q, want %q", tt.template, tt.locale, out.String(), tt.want)
+		}
+	}
+}
+
+func TestTransDefaultLocaleAndPluralRule(t *testing.T) {
+	tr := newTranslator(t)
+	// every count above 1 takes the "one" form in this made-up rule
+	tr.SetPluralRule("de", func(n int64) string {
+		if n > 1 {
+			return "one"
+		}
+		return "other"
+	})
+	tmpl, err := NewSet(NewInMemLoader(), WithTranslator(tr), WithLocale("de")).parseString(`{{ trans "inbox" count=n }}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out strings.Builder
+	if err := tmpl.Execute(&out, VarMap{}.Set("n", 3), nil); err != nil {
+		t.Fatal(err)
+	}
+	if want := "Eine Nachricht"; out.String() != want {
+		t.Errorf("got %q, want %q", out.String(), want)
+	}
+	if err := tmpl.Execute(&out, VarMap{}.Set("n", "three"), nil); err == nil {
+		t.Error("count accepted a string")
+	}
+}
+
+func TestTranslationFormat(t *testing.T) {
+	tr := &Translation{Args: []interface{}{"a", 2}, Named: map[string]interface{}{"name": "n"}}
+	if got, want := tr.Format("{0}{1}{name}{2}{missing}{"), "a2n{2}{missing}{"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
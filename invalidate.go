@@ -0,0 +1,137 @@
+package jet
+
+import (
+	"path"
+	"path/filepath"
+	"sync"
+)
+
+// dependents records, for every cached template name, the cache keys of the cached templates depending on it and
+// the other keys the template itself is cached under.
+type dependents struct {
+	lock   sync.Mutex
+	m      map[string]map[string]struct{}
+	cached map[string]struct{} // names of the cached templates
+}
+
+func (d *dependents) add(templatePath string, t *Template) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if d.m == nil {
+		d.m = map[string]map[string]struct{}{}
+		d.cached = map[string]struct{}{}
+	}
+	d.cached[t.Name] = struct{}{}
+	for _, dep := range t.dependencies {
+		d.link(dep.Path, templatePath)
+	}
+	if t.Name != templatePath {
+		d.link(t.Name, templatePath)
+	}
+}
+
+func (d *dependents) link(name, templatePath string) {
+	keys, ok := d.m[name]
+	if !ok {
+		keys = map[string]struct{}{}
+		d.m[name] = keys
+	}
+	keys[templatePath] = struct{}{}
+}
+
+// keys returns the cache keys linked to name.
+func (d *dependents) keys(name string) []string {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	keys := make([]string, 0, len(d.m[name]))
+	for key := range d.m[name] {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// take removes and returns the cache keys linked to name.
+func (d *dependents) take(name string) []string {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	keys := make([]string, 0, len(d.m[name]))
+	for key := range d.m[name] {
+		keys = append(keys, key)
+	}
+	delete(d.m, name)
+	delete(d.cached, name)
+	return keys
+}
+
+// names returns the names of the cached templates.
+func (d *dependents) names() []string {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	names := make([]string, 0, len(d.cached))
+	for name := range d.cached {
+		names = append(names, name)
+	}
+	return names
+}
+
+// Invalidate removes the template at templatePath from the cache, with every configured extension, together with
+// all cached templates extending, importing or including it, directly or indirectly. Templates parsed from strings
+// depending on them are dropped as well. The next GetTemplate parses them again from the Loader.
+//
+// Invalidate requires a cache implementing CacheDeleter, as the caches of this package do; it does nothing if the
+// Set's cache can't delete templates.
+func (s *Set) Invalidate(templatePath string) {
+	s.invalidateTemplate(templatePath)
+}
+
+// invalidateTemplate implements Invalidate, returning the cache keys of the templates it removed.
+func (s *Set) invalidateTemplate(templatePath string) (evicted []string) {
+	deleter, ok := s.cache.(CacheDeleter)
+	if !ok {
+		return nil
+	}
+	names := map[string]bool{}
+	s.invalidate(deleter, path.Join("/", filepath.ToSlash(templatePath)), names, &evicted)
+	if s.parseCache != nil && len(names) > 0 {
+		s.parseCache.entries.deleteFunc(func(_ uint64, t *Template) bool {
+			return t.dependsOn(names)
+		})
+	}
+	return evicted
+}
+
+func (s *Set) invalidate(deleter CacheDeleter, templatePath string, names map[string]bool, evicted *[]string) {
+	var removed []string
+	for _, extension := range s.extensions {
+		key := templatePath + extension
+		if names[key] {
+			continue
+		}
+		names[key] = true
+		removed = append(removed, key)
+		if t := s.cache.Get(key); t != nil {
+			if !names[t.Name] {
+				names[t.Name] = true
+				removed = append(removed, t.Name)
+			}
+			deleter.Delete(key)
+			*evicted = append(*evicted, key)
+		}
+	}
+	for _, name := range removed {
+		for _, key := range s.dependents.take(name) {
+			s.invalidate(deleter, key, names, evicted)
+		}
+	}
+}
+
+// PurgeCache removes all templates from the cache, if the cache supports it, and from the cache of templates
+// parsed from strings.
+func (s *Set) PurgeCache() {
+	if p, ok := s.cache.(CachePurger); ok {
+		p.Purge()
+	}
+	if s.parseCache != nil {
+		s.parseCache.entries.purge()
+	}
+}
@@ -0,0 +1,76 @@
+package jet
+
+import (
+	"strings"
+	"sync"
+	"testing"
+)
+
+// putOnlyCache is a Cache implementing none of the optional interfaces.
+type putOnlyCache struct {
+	m    sync.Map
+	puts int
+}
+
+func (c *putOnlyCache) Get(templatePath string) *Template {
+	if t, ok := c.m.Load(templatePath); ok {
+		return t.(*Template)
+	}
+	return nil
+}
+
+func (c *putOnlyCache) Put(templatePath string, t *Template) {
+	c.puts++
+	c.m.Store(templatePath, t)
+}
+
+func renderTemplate(t *testing.T, s *Set, path string) string {
+	t.Helper()
+	tmpl, err := s.GetTemplate(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out strings.Builder
+	if err := tmpl.Execute(&out, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	return out.String()
+}
+
+func TestInvalidate(t *testing.T) {
+	loader := NewInMemLoader()
+	loader.Set("/layout.jet", `[{{ yield body() }}]`)
+	loader.Set("/page.jet", `{{ extends "layout.jet" }}{{ block body() }}page{{ end }}`)
+	loader.Set("/other.jet", `other`)
+	s := NewSet(loader)
+	for path, want := range map[string]string{"/page.jet": "[page]", "/other.jet": "other"} {
+		if got := renderTemplate(t, s, path); got != want {
+			t.Fatalf("%s: got %q, want %q", path, got, want)
+		}
+	}
+
+	loader.Set("/layout.jet", `<{{ yield body() }}>`)
+	s.Invalidate("/layout.jet")
+	if got := renderTemplate(t, s, "/page.jet"); got != "<page>" {
+		t.Errorf("got %q, want the template extending the invalidated one parsed again", got)
+	}
+	if s.cache.Get("/other.jet") == nil {
+		t.Error("Invalidate removed an unrelated template")
+	}
+}
+
+func TestInvalidateWithoutCacheDeleter(t *testing.T) {
+	loader := NewInMemLoader()
+	loader.Set("/page.jet", `page`)
+	c := &putOnlyCache{}
+	s := NewSet(loader, WithCache(c))
+	renderTemplate(t, s, "/page.jet")
+
+	s.Invalidate("/page.jet")
+	if c.puts != 1 {
+		t.Errorf("got %d calls to Put, want only the one caching the template", c.puts)
+	}
+	if c.Get("/page.jet") == nil {
+		t.Error("the template was removed from a cache without Delete")
+	}
+}
@@ -15,8 +15,7 @@ func Sprintf(format string, a any, delims ...*Delims) string {
 			delim.Right = delims[0].Right
 		}
 	}
-	set := NewMemorySet(WithDelims(delim.Left, delim.Right))
-	tmpl, err := set.ParseContent(format)
+	tmpl, err := sprintfSet(*delim).ParseContent(format)
 	if err != nil {
 		return format
 	}
@@ -0,0 +1,27 @@
+package jet
+
+import "testing"
+
+func TestSprintf(t *testing.T) {
+	tests := []struct {
+		name, format string
+		data         any
+		delims       []*Delims
+		want         string
+	}{
+		{"map", "Hi {name}", map[string]any{"name": "Ann"}, nil, "Hi Ann"},
+		{"struct", "Hi {name}", struct {
+			Name string `json:"name"`
+		}{"Ann"}, nil, "Hi Ann"},
+		{"delims", "Hi <name> {x}", map[string]any{"name": "Ann"}, []*Delims{{Left: "<", Right: ">"}}, "Hi Ann {x}"},
+		{"missing key", "Hi {name} from {city}", map[string]any{"name": "Ann"}, nil, "Hi {name} from {city}"},
+		{"invalid", "Hi {if}", map[string]any{}, nil, "Hi {if}"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Sprintf(tt.format, tt.data, tt.delims...); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -106,6 +106,12 @@ const (
 	itemNil
 	itemMSG
 	itemTrans
+	itemSwitch
+	itemCase
+	itemDefault
+	itemBreak
+	itemContinue
+	itemCoalesce // ??
 )
 
 var key = map[string]itemType{
@@ -136,6 +142,23 @@ var key = map[string]itemType{
 
 	"msg":   itemMSG,
 	"trans": itemTrans,
+
+	"switch":  itemSwitch,
+	"case":    itemCase,
+	"default": itemDefault,
+
+	"break":    itemBreak,
+	"continue": itemContinue,
+}
+
+// actionKeywords are only keywords as the first word of an action, so that variables, functions and filters can
+// still have these names; see lexer.isKeyword.
+var actionKeywords = map[string]bool{
+	"switch":   true,
+	"case":     true,
+	"default":  true,
+	"break":    true,
+	"continue": true,
 }
 
 const eof = -1
@@ -438,6 +461,8 @@ func lexInsideAction(l *lexer) stateFn {
 		switch l.next() {
 		case '[':
 			l.emit(itemLeftLaxBrackets)
+		case '?':
+			l.emit(itemCoalesce)
 		case '.':
 			// special look-ahead for ".field" so we don't break l.backup().
 			if l.pos < Pos(len(l.input)) {
@@ -578,7 +603,7 @@ Loop:
 				return l.errorf("bad character %#U", r)
 			}
 			switch {
-			case key[word] > itemKeyword:
+			case l.isKeyword(word):
 				l.emit(key[word])
 			case word[0] == '.':
 				l.emit(itemField)
@@ -593,6 +618,28 @@ Loop:
 	return lexInsideAction
 }
 
+// isKeyword reports whether word, which was just scanned, is a keyword. Words of actionKeywords are keywords at the
+// start of an action only, and even there not when assigned ({{ case := 1 }}) nor, for default, which takes
+// nothing, when anything follows ({{ default(x, "none") }}).
+func (l *lexer) isKeyword(word string) bool {
+	if key[word] <= itemKeyword {
+		return false
+	}
+	if !actionKeywords[word] {
+		return true
+	}
+	for i := len(l.items) - 1; i >= 0 && l.items[i].typ != itemLeftDelim; i-- {
+		if l.items[i].typ != itemSpace {
+			return false
+		}
+	}
+	rest := strings.TrimLeft(l.input[l.pos:], " \t\r\n")
+	if strings.HasPrefix(rest, ":=") || strings.HasPrefix(rest, "=") && !strings.HasPrefix(rest, "==") {
+		return false
+	}
+	return word != "default" || strings.HasPrefix(rest, l.rightDelim) || strings.HasPrefix(rest, rightTrimMarker[1:]+l.rightDelim)
+}
+
 // lexField scans a field: .Alphanumeric.
 // The . has been scanned.
 func lexField(l *lexer) stateFn {
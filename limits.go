@@ -0,0 +1,127 @@
+package jet
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/oarkflow/jet/utils/e"
+)
+
+// Limits bounds the resources a single execution may use, for rendering templates from untrusted sources.
+// A zero field means no limit. When a limit is exceeded the execution stops with an error whose reason is one of
+// e.LimitOutputReason, e.LimitIterationsReason, e.LimitDepthReason or e.LimitNodesReason, located at the node
+// being executed.
+type Limits struct {
+	MaxOutputBytes int64 // bytes written to the output
+	MaxIterations  int64 // range iterations, over all loops
+	MaxDepth       int   // nesting of include, yield, block and exec
+	MaxNodes       int64 // nodes executed, over all lists
+}
+
+// WithLimits returns an option function that sets the limits applied to every execution of the Set's templates.
+func WithLimits(limits Limits) Option {
+	return func(s *Set) {
+		s.limits = limits
+	}
+}
+
+// ExecuteLimits executes the template like Execute with limits in place of the Set's limits.
+func (t *Template) ExecuteLimits(w io.Writer, limits Limits, variables VarMap, data interface{}) error {
+	return t.execute(w, variables, data, func(rt *Runtime) {
+		rt.limits = newLimiter(limits)
+	})
+}
+
+// limiter tracks the resources used by an execution.
+type limiter struct {
+	Limits
+	output, iterations, nodes int64
+	depth                     int
+	node                      Node // node being executed, to locate output limit errors
+	err                       e.Error
+}
+
+// newLimiter returns nil when limits has no limit set, so that unlimited executions skip all accounting.
+func newLimiter(limits Limits) *limiter {
+	if limits == (Limits{}) {
+		return nil
+	}
+	return &limiter{Limits: limits}
+}
+
+func (l *limiter) exceeded(node Node, reason e.Reason, message string) e.Error {
+	if l.err == nil {
+		if node == nil {
+			l.err = e.New().WithReason(reason).WithMessage(message)
+		} else {
+			l.err = node.error(reason, message)
+		}
+	}
+	return l.err
+}
+
+// step accounts for the execution of node.
+func (l *limiter) step(node Node) e.Error {
+	if l == nil {
+		return nil
+	}
+	if l.err != nil {
+		return l.err
+	}
+	l.node = node
+	l.nodes++
+	if l.MaxNodes > 0 && l.nodes > l.MaxNodes {
+		return l.exceeded(node, e.LimitNodesReason, fmt.Sprintf("execution exceeded the limit of %d nodes", l.MaxNodes))
+	}
+	return nil
+}
+
+// iterate accounts for an iteration of the range node.
+func (l *limiter) iterate(node Node) e.Error {
+	if l == nil {
+		return nil
+	}
+	if l.err != nil {
+		return l.err
+	}
+	l.iterations++
+	if l.MaxIterations > 0 && l.iterations > l.MaxIterations {
+		return l.exceeded(node, e.LimitIterationsReason, fmt.Sprintf("execution exceeded the limit of %d loop iterations", l.MaxIterations))
+	}
+	return nil
+}
+
+// enter accounts for entering an include, yield, block or exec; every successful call must be paired with leave.
+func (l *limiter) enter() e.Error {
+	if l == nil {
+		return nil
+	}
+	if l.MaxDepth > 0 && l.depth >= l.MaxDepth {
+		return l.exceeded(l.node, e.LimitDepthReason, fmt.Sprintf("execution exceeded the nesting limit of %d", l.MaxDepth))
+	}
+	l.depth++
+	return nil
+}
+
+func (l *limiter) leave() {
+	if l != nil {
+		l.depth--
+	}
+}
+
+// limitWriter fails writes once the output limit is reached.
+type limitWriter struct {
+	w io.Writer
+	l *limiter
+}
+
+func (w *limitWriter) Write(p []byte) (int, error) {
+	if w.l.err != nil {
+		return 0, w.l.err
+	}
+	if w.l.output+int64(len(p)) > w.l.MaxOutputBytes {
+		return 0, w.l.exceeded(w.l.node, e.LimitOutputReason, fmt.Sprintf("output exceeded the limit of %d bytes", w.l.MaxOutputBytes))
+	}
+	w.l.output += int64(len(p))
+	return w.w.Write(p)
+}
@@ -0,0 +1,90 @@
+package jet
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/oarkflow/jet/utils/e"
+)
+
+func TestExecuteLimitsOutput(t *testing.T) {
+	for _, closures := range []bool{false, true} {
+		for _, text := range []string{`abcdefghijkl`, `{{ "abcdefghijkl" }}`, `{{ 123456789 }}`, `ab{{ range ints(0, 9) }}{{ . }}{{ end }}`} {
+			var opts []Option
+			if closures {
+				opts = append(opts, WithClosureCompilation())
+			}
+			tmpl, err := NewSet(NewInMemLoader(), opts...).parseString(text)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var out strings.Builder
+			err = tmpl.ExecuteLimits(&out, Limits{MaxOutputBytes: 5}, nil, nil)
+			if err, ok := err.(e.Error); !ok || err.Reason() != e.LimitOutputReason {
+				t.Errorf("closures %v, %s: got %v, want the output limit error", closures, text, err)
+			}
+			if out.Len() > 5 {
+				t.Errorf("closures %v, %s: wrote %q past the limit", closures, text, out.String())
+			}
+		}
+	}
+}
+
+func TestEscapeeWriterReturnsLimitError(t *testing.T) {
+	for _, set := range []*Set{NewSet(NewInMemLoader()), NewSet(NewInMemLoader(), WithSafeWriter(nil))} {
+		l := newLimiter(Limits{MaxOutputBytes: 2})
+		w := &escapeeWriter{Writer: &limitWriter{w: new(strings.Builder), l: l}, set: set}
+		if n, err := w.Write([]byte("ab")); n != 2 || err != nil {
+			t.Fatalf("got %d, %v, want 2, nil", n, err)
+		}
+		if _, err := w.Write([]byte("<b>")); err == nil || err != l.err {
+			t.Errorf("got %v, want the error of the limit writer", err)
+		}
+	}
+}
+
+func TestExecuteLimits(t *testing.T) {
+	loader := NewInMemLoader()
+	loader.Set("/range.jet", "a\n{{ range ints(0, 1000000000) }}{{ end }}")
+	loader.Set("/yield.jet", "{{ block r() }}\n\n{{ yield r() }}{{ end }}")
+	loader.Set("/include.jet", "x\n\n\n{{ include \"include.jet\" }}")
+	loader.Set("/nodes.jet", "a\n\n\n\n{{ range ints(0, 10) }}{{ . }}{{ end }}")
+	tests := []struct {
+		name   string
+		limits Limits
+		reason e.Reason
+		line   int
+		want   string
+	}{
+		{"/range.jet", Limits{MaxIterations: 1000}, e.LimitIterationsReason, 2, "a\n"},
+		{"/yield.jet", Limits{MaxDepth: 5}, e.LimitDepthReason, 3, strings.Repeat("\n\n", 5)},
+		{"/include.jet", Limits{MaxDepth: 5}, e.LimitDepthReason, 4, strings.Repeat("x\n\n\n", 6)},
+		{"/nodes.jet", Limits{MaxNodes: 8}, e.LimitNodesReason, 5, "a\n\n\n\n012345"},
+	}
+	for _, closures := range []bool{false, true} {
+		var opts []Option
+		if closures {
+			opts = append(opts, WithClosureCompilation())
+		}
+		s := NewSet(loader, opts...)
+		for _, tt := range tests {
+			tmpl, err := s.GetTemplate(tt.name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var out strings.Builder
+			err = tmpl.ExecuteLimits(&out, tt.limits, nil, nil)
+			limitErr, ok := err.(e.Error)
+			if !ok || limitErr.Reason() != tt.reason {
+				t.Errorf("closures %v, %s: got %v, want a %s error", closures, tt.name, err, tt.reason)
+				continue
+			}
+			if pos := limitErr.Position(); pos == nil || pos.L != tt.line || !strings.HasPrefix(err.Error(), tt.reason+" "+tt.name+":") {
+				t.Errorf("closures %v, %s: got %v, want the error at line %d", closures, tt.name, err, tt.line)
+			}
+			if out.String() != tt.want {
+				t.Errorf("closures %v, %s: got %q, want %q", closures, tt.name, out.String(), tt.want)
+			}
+		}
+	}
+}
@@ -22,6 +22,7 @@ import (
 	"path"
 	"path/filepath"
 	"sync"
+	"time"
 )
 
 // Loader is a minimal interface required for loading templates.
@@ -54,6 +55,9 @@ type Loader interface {
 // OSFileSystemLoader implements Loader interface using OS file system (os.File).
 type OSFileSystemLoader struct {
 	dir string
+
+	lock    sync.Mutex
+	tracker *fileTracker // modification times of opened files, set by Reload
 }
 
 // compile time check that we implement Loader
@@ -79,7 +83,17 @@ func (l *OSFileSystemLoader) Exists(templatePath string) bool {
 
 // Open returns the result of `os.Open()` on the file located using the same logic as Exists().
 func (l *OSFileSystemLoader) Open(templatePath string) (io.ReadCloser, error) {
-	return os.Open(filepath.Join(l.dir, filepath.FromSlash(templatePath)))
+	f, err := os.Open(filepath.Join(l.dir, filepath.FromSlash(templatePath)))
+	if err != nil {
+		return nil, err
+	}
+	l.lock.Lock()
+	tracker := l.tracker
+	l.lock.Unlock()
+	if tracker != nil {
+		tracker.track(templatePath, f)
+	}
+	return f, nil
 }
 
 // InMemLoader is a simple in-memory loader storing template contents in a simple map.
@@ -87,17 +101,32 @@ func (l *OSFileSystemLoader) Open(templatePath string) (io.ReadCloser, error) {
 // turning it into an absolute path by prepending a "/" if neccessary, and cleaning it (see path.Clean()).
 // It is safe for concurrent use.
 type InMemLoader struct {
-	lock  sync.RWMutex
-	files map[string][]byte
+	lock    sync.RWMutex
+	files   map[string]inMemFile
+	version uint64 // incremented by every Set
+}
+
+type inMemFile struct {
+	contents []byte
+	version  uint64
+	modTime  time.Time
 }
 
 // compile time check that we implement Loader
 var _ Loader = (*InMemLoader)(nil)
 
-var defaultSet = NewSet(NewInMemLoader())
+// defaultSet is the Set of the package-level functions. It looks struct fields up by their json names, as the
+// JSON conversion of the data of Parse used to make them.
+var defaultSet = newDefaultSet()
 
+// DefaultSet replaces the Set of the package-level functions with one configured with opts, which come after
+// WithJSONTags: pass WithoutJSONTags to look struct fields up by their Go name.
 func DefaultSet(opts ...Option) {
-	defaultSet = NewSet(NewInMemLoader(), opts...)
+	defaultSet = newDefaultSet(opts...)
+}
+
+func newDefaultSet(opts ...Option) *Set {
+	return NewSet(NewInMemLoader(), append([]Option{WithJSONTags()}, opts...)...)
 }
 
 func NewMemorySet(opts ...Option) *Set {
@@ -107,7 +136,7 @@ func NewMemorySet(opts ...Option) *Set {
 // NewInMemLoader return a new InMemLoader.
 func NewInMemLoader() *InMemLoader {
 	return &InMemLoader{
-		files: map[string][]byte{},
+		files: map[string]inMemFile{},
 	}
 }
 
@@ -126,7 +155,7 @@ func (l *InMemLoader) Open(templatePath string) (io.ReadCloser, error) {
 		return nil, fmt.Errorf("%s does not exist", templatePath)
 	}
 
-	return io.NopCloser(bytes.NewReader(f)), nil
+	return io.NopCloser(bytes.NewReader(f.contents)), nil
 }
 
 // Exists returns whether or not a template is indexed under this path.
@@ -138,12 +167,13 @@ func (l *InMemLoader) Exists(templatePath string) bool {
 	return ok
 }
 
-// Set adds a template to the loader.
+// Set adds a template to the loader, giving it a new version (see Stat).
 func (l *InMemLoader) Set(templatePath, contents string) {
 	templatePath = l.normalize(templatePath)
 	l.lock.Lock()
 	defer l.lock.Unlock()
-	l.files[templatePath] = []byte(contents)
+	l.version++
+	l.files[templatePath] = inMemFile{contents: []byte(contents), version: l.version, modTime: time.Now()}
 }
 
 // Delete removes whatever contents are stored under the given path.
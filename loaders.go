@@ -0,0 +1,152 @@
+package jet
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// normalizePath converts templatePath to a clean, absolute, slash-delimited path, as InMemLoader does.
+func normalizePath(templatePath string) string {
+	return path.Join("/", filepath.ToSlash(templatePath))
+}
+
+// FSLoader implements Loader interface using an fs.FS, such as an embed.FS.
+// Template paths are normalized like InMemLoader does, then the leading slash is removed to get an fs.FS path.
+type FSLoader struct {
+	fsys fs.FS
+}
+
+// compile time check that we implement Loader
+var _ Loader = (*FSLoader)(nil)
+
+// NewFSLoader returns an initialized FSLoader. Use fs.Sub to serve templates from a subdirectory of fsys.
+func NewFSLoader(fsys fs.FS) *FSLoader {
+	return &FSLoader{fsys: fsys}
+}
+
+func (l *FSLoader) name(templatePath string) string {
+	name := strings.TrimPrefix(normalizePath(templatePath), "/")
+	if name == "" {
+		return "."
+	}
+	return name
+}
+
+// Exists returns true if a regular file is found under the template path.
+func (l *FSLoader) Exists(templatePath string) bool {
+	stat, err := fs.Stat(l.fsys, l.name(templatePath))
+	return err == nil && !stat.IsDir()
+}
+
+// Open returns the file located using the same logic as Exists().
+func (l *FSLoader) Open(templatePath string) (io.ReadCloser, error) {
+	return l.fsys.Open(l.name(templatePath))
+}
+
+// MultiLoader implements Loader interface by trying several loaders in order: a template is loaded from the first
+// loader it exists in. Put the loaders with overriding templates (e.g. a tenant's overlay) before the ones they
+// override (e.g. the base theme).
+type MultiLoader struct {
+	loaders []Loader
+}
+
+// compile time check that we implement Loader
+var _ Loader = (*MultiLoader)(nil)
+
+// NewMultiLoader returns a MultiLoader trying loaders in the order given.
+func NewMultiLoader(loaders ...Loader) *MultiLoader {
+	return &MultiLoader{loaders: loaders}
+}
+
+// Exists returns whether any of the loaders has a template under the requested path.
+func (l *MultiLoader) Exists(templatePath string) bool {
+	return l.find(templatePath) != nil
+}
+
+// Open returns the template's contents from the first loader having it.
+func (l *MultiLoader) Open(templatePath string) (io.ReadCloser, error) {
+	loader := l.find(templatePath)
+	if loader == nil {
+		return nil, fmt.Errorf("%s does not exist", normalizePath(templatePath))
+	}
+	return loader.Open(templatePath)
+}
+
+func (l *MultiLoader) find(templatePath string) Loader {
+	for _, loader := range l.loaders {
+		if loader.Exists(templatePath) {
+			return loader
+		}
+	}
+	return nil
+}
+
+// PrefixLoader implements Loader interface by mounting loaders under path prefixes: with a loader mounted at
+// "/@emails", the template "/@emails/welcome.jet" is "/welcome.jet" in that loader. A path is served by the loader
+// with the longest matching prefix; a loader mounted at "/" serves the paths no other prefix matches.
+// It is safe for concurrent use.
+type PrefixLoader struct {
+	lock     sync.RWMutex
+	mounts   map[string]Loader
+	prefixes []string // longest first
+}
+
+// compile time check that we implement Loader
+var _ Loader = (*PrefixLoader)(nil)
+
+// NewPrefixLoader returns a PrefixLoader without mounted loaders.
+func NewPrefixLoader() *PrefixLoader {
+	return &PrefixLoader{mounts: map[string]Loader{}}
+}
+
+// Mount mounts loader under prefix, replacing the loader previously mounted there. The prefix is normalized like
+// template paths.
+func (l *PrefixLoader) Mount(prefix string, loader Loader) {
+	prefix = normalizePath(prefix)
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if _, ok := l.mounts[prefix]; !ok {
+		l.prefixes = append(l.prefixes, prefix)
+		sort.Slice(l.prefixes, func(i, j int) bool { return len(l.prefixes[i]) > len(l.prefixes[j]) })
+	}
+	l.mounts[prefix] = loader
+}
+
+// Exists returns whether the loader mounted for the path has a template under the rest of the path.
+func (l *PrefixLoader) Exists(templatePath string) bool {
+	loader, rest := l.find(templatePath)
+	return loader != nil && loader.Exists(rest)
+}
+
+// Open returns the template's contents from the loader mounted for the path.
+func (l *PrefixLoader) Open(templatePath string) (io.ReadCloser, error) {
+	loader, rest := l.find(templatePath)
+	if loader == nil {
+		return nil, fmt.Errorf("%s does not exist", normalizePath(templatePath))
+	}
+	return loader.Open(rest)
+}
+
+// find returns the loader mounted for templatePath and the path to look up in it.
+func (l *PrefixLoader) find(templatePath string) (Loader, string) {
+	templatePath = normalizePath(templatePath)
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+	for _, prefix := range l.prefixes {
+		switch {
+		case prefix == "/":
+			return l.mounts[prefix], templatePath
+		case templatePath == prefix:
+			return l.mounts[prefix], "/"
+		case strings.HasPrefix(templatePath, prefix+"/"):
+			return l.mounts[prefix], templatePath[len(prefix):]
+		}
+	}
+	return nil, ""
+}
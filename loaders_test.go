@@ -0,0 +1,140 @@
+package jet
+
+import (
+	"io"
+	"testing"
+	"testing/fstest"
+)
+
+// loaded returns the contents loader has under templatePath, or "" if it doesn't have it.
+func loaded(t *testing.T, loader Loader, templatePath string) string {
+	t.Helper()
+	if !loader.Exists(templatePath) {
+		return ""
+	}
+	r, err := loader.Open(templatePath)
+	if err != nil {
+		t.Fatalf("%s: %v", templatePath, err)
+	}
+	defer r.Close()
+	contents, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("%s: %v", templatePath, err)
+	}
+	return string(contents)
+}
+
+func TestFSLoader(t *testing.T) {
+	loader := NewFSLoader(fstest.MapFS{
+		"a.jet":     {Data: []byte("a")},
+		"dir/b.jet": {Data: []byte("b")},
+	})
+	tests := []struct {
+		path, want string
+	}{
+		{"/a.jet", "a"},
+		{"a.jet", "a"},
+		{"/x/../a.jet", "a"},
+		{"../a.jet", "a"},
+		{"/dir/b.jet", "b"},
+		{"dir//b.jet", "b"},
+		{"/dir/./sub/../b.jet", "b"},
+		{"/dir", ""},
+		{"/", ""},
+		{"", ""},
+		{"/missing.jet", ""},
+	}
+	for _, tt := range tests {
+		if got := loaded(t, loader, tt.path); got != tt.want {
+			t.Errorf("%q: got %q, want %q", tt.path, got, tt.want)
+		}
+	}
+	if _, err := loader.Open("/missing.jet"); err == nil {
+		t.Error("opening a missing template must fail")
+	}
+}
+
+func TestMultiLoader(t *testing.T) {
+	base := NewInMemLoader()
+	base.Set("/layout.jet", "base layout")
+	base.Set("/page.jet", "base page")
+	overlay := NewInMemLoader()
+	overlay.Set("/page.jet", "overlay page")
+	overlay.Set("/extra.jet", "overlay extra")
+	loader := NewMultiLoader(overlay, base)
+
+	tests := []struct {
+		path, want string
+	}{
+		{"/page.jet", "overlay page"},
+		{"page.jet", "overlay page"},
+		{"/x/../page.jet", "overlay page"},
+		{"/layout.jet", "base layout"},
+		{"/extra.jet", "overlay extra"},
+		{"/missing.jet", ""},
+	}
+	for _, tt := range tests {
+		if got := loaded(t, loader, tt.path); got != tt.want {
+			t.Errorf("%q: got %q, want %q", tt.path, got, tt.want)
+		}
+	}
+
+	if got := loaded(t, NewMultiLoader(base, overlay), "/page.jet"); got != "base page" {
+		t.Errorf("the first loader must win: got %q", got)
+	}
+	if _, err := loader.Open("/missing.jet"); err == nil {
+		t.Error("opening a missing template must fail")
+	}
+}
+
+func TestPrefixLoader(t *testing.T) {
+	root := NewInMemLoader()
+	root.Set("/index.jet", "root index")
+	root.Set("/@emails/welcome.jet", "root welcome")
+	root.Set("/@emailsfoo/welcome.jet", "root emailsfoo")
+	emails := NewInMemLoader()
+	emails.Set("/welcome.jet", "emails welcome")
+	emails.Set("/admin/welcome.jet", "emails admin welcome")
+	emails.Set("/", "emails root")
+	admin := NewInMemLoader()
+	admin.Set("/welcome.jet", "admin welcome")
+
+	loader := NewPrefixLoader()
+	loader.Mount("/@emails", emails)
+	loader.Mount("/", root)
+	loader.Mount("@emails/admin/", admin)
+
+	tests := []struct {
+		path, want string
+	}{
+		{"/index.jet", "root index"},
+		{"index.jet", "root index"},
+		{"/@emails/welcome.jet", "emails welcome"},
+		{"@emails/welcome.jet", "emails welcome"},
+		{"/x/../@emails/welcome.jet", "emails welcome"},
+		{"/@emails", "emails root"},
+		{"/@emails/admin/welcome.jet", "admin welcome"},
+		{"/@emails/admin/../welcome.jet", "emails welcome"},
+		{"/@emailsfoo/welcome.jet", "root emailsfoo"},
+		{"/@emails/missing.jet", ""},
+	}
+	for _, tt := range tests {
+		if got := loaded(t, loader, tt.path); got != tt.want {
+			t.Errorf("%q: got %q, want %q", tt.path, got, tt.want)
+		}
+	}
+
+	unrooted := NewPrefixLoader()
+	unrooted.Mount("/@emails", emails)
+	if got := loaded(t, unrooted, "/@emailsfoo/welcome.jet"); got != "" {
+		t.Errorf("/@emailsfoo/welcome.jet is served by the /@emails mount: %q", got)
+	}
+	if _, err := unrooted.Open("/index.jet"); err == nil {
+		t.Error("/index.jet is served without a / mount")
+	}
+
+	loader.Mount("/@emails/", root)
+	if got := loaded(t, loader, "/@emails/index.jet"); got != "root index" {
+		t.Errorf("mounting again must replace the loader: got %q", got)
+	}
+}
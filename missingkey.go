@@ -0,0 +1,72 @@
+package jet
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/oarkflow/jet/utils/e"
+)
+
+// MissingKey is the policy applied when a template refers to a variable, field, map key or index that does not exist.
+type MissingKey int
+
+const (
+	// MissingKeyDefault fails on undefined identifiers and missing fields, and evaluates missing map keys reached
+	// through a field chain or an index expression to nothing.
+	MissingKeyDefault MissingKey = iota
+	// MissingKeyError fails on any missing value, including missing map keys.
+	MissingKeyError
+	// MissingKeyZero evaluates missing values to nothing: actions reading them render empty, conditions are false.
+	MissingKeyZero
+	// MissingKeyKeep writes the source of an action reading a missing value back to the output, delimiters
+	// included, so the result can be rendered again with more data. Outside of actions (in conditions, range
+	// expressions or assignments) it behaves like MissingKeyZero.
+	MissingKeyKeep
+)
+
+func (m MissingKey) String() string {
+	switch m {
+	case MissingKeyError:
+		return "error"
+	case MissingKeyZero:
+		return "zero"
+	case MissingKeyKeep:
+		return "keep"
+	default:
+		return "default"
+	}
+}
+
+// WithMissingKey returns an option function that sets the policy for missing variables, fields, map keys and indexes.
+func WithMissingKey(policy MissingKey) Option {
+	return func(s *Set) {
+		s.missingKey = policy
+	}
+}
+
+// missingKey applies the missing-key policy to err, raised because a variable, field or index does not exist.
+func (rt *Runtime) missingKey(err e.Error) (reflect.Value, e.Error) {
+	switch rt.set.missingKey {
+	case MissingKeyZero, MissingKeyKeep:
+		rt.missing = true
+		return reflect.Value{}, nil
+	}
+	return reflect.Value{}, err
+}
+
+// missingMapKey applies the missing-key policy to a key not found in a map, which is not an error by default.
+func (rt *Runtime) missingMapKey(node Node, key reflect.Value) (reflect.Value, e.Error) {
+	if rt.set.missingKey == MissingKeyDefault {
+		return reflect.Value{}, nil
+	}
+	return rt.missingKey(node.error(e.NotFoundFieldOrMethodReason, fmt.Sprintf("map has no key %v", key)))
+}
+
+// isMissingMapKey reports whether v is a map that holds no value for key.
+func isMissingMapKey(v, key reflect.Value) bool {
+	v, isNil := indirect(v)
+	if isNil || v.Kind() != reflect.Map || !key.IsValid() || !key.Type().ConvertibleTo(v.Type().Key()) {
+		return false
+	}
+	return !v.MapIndex(key.Convert(v.Type().Key())).IsValid()
+}
@@ -0,0 +1,51 @@
+package jet
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMissingKey(t *testing.T) {
+	const fails = "<error>"
+	type user struct{ Name string }
+	tests := []struct {
+		name, template         string
+		deflt, err, zero, keep string
+	}{
+		{"variable", `a{{ missing }}b`, fails, fails, "ab", "a{{ missing }}b"},
+		{"field", `a{{ user.Missing }}b`, fails, fails, "ab", "a{{ user.Missing }}b"},
+		{"nested field", `a{{ user.Name.Missing }}b`, fails, fails, "ab", "a{{ user.Name.Missing }}b"},
+		{"map key", `a{{ m.missing }}b`, "ab", fails, "ab", "a{{ m.missing }}b"},
+		{"map index", `a{{ m["missing"] }}b`, "ab", fails, "ab", `a{{ m["missing"] }}b`},
+		{"present", `a{{ user.Name }}{{ m.key }}b`, "aAnnvalueb", "aAnnvalueb", "aAnnvalueb", "aAnnvalueb"},
+		{"condition", `{{ if m.missing }}y{{ else }}n{{ end }}`, "n", fails, "n", "n"},
+		{"pipeline", `a{{ m.missing | upper }}b`, fails, fails, "ab", "a{{ m.missing | upper }}b"},
+	}
+	modes := []MissingKey{MissingKeyDefault, MissingKeyError, MissingKeyZero, MissingKeyKeep}
+	for _, closures := range []bool{false, true} {
+		for _, mode := range modes {
+			opts := []Option{WithMissingKey(mode)}
+			if closures {
+				opts = append(opts, WithClosureCompilation())
+			}
+			s := NewSet(NewInMemLoader(), opts...)
+			for _, tt := range tests {
+				want := []string{tt.deflt, tt.err, tt.zero, tt.keep}[mode]
+				tmpl, err := s.parseString(tt.template)
+				if err != nil {
+					t.Fatalf("%s: %v", tt.name, err)
+				}
+				vars := VarMap{}.Set("user", user{Name: "Ann"}).Set("m", map[string]string{"key": "value"})
+				var out strings.Builder
+				got := fails
+				err = tmpl.Execute(&out, vars, nil)
+				if err == nil {
+					got = out.String()
+				}
+				if got != want {
+					t.Errorf("closures %v, %s, %s: got %q, want %q (%v)", closures, mode, tt.name, got, want, err)
+				}
+			}
+		}
+	}
+}
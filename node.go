@@ -17,6 +17,8 @@ package jet
 import (
 	"bytes"
 	"fmt"
+	"reflect"
+	"strings"
 
 	"github.com/oarkflow/jet/utils/e"
 )
@@ -28,6 +30,7 @@ type Node interface {
 	String() string
 	Position() Pos
 	line() int
+	templatePath() string
 	error(e.Reason, e.Message) e.Error
 }
 
@@ -57,6 +60,10 @@ func (n *NodeBase) line() int {
 	return n.Line
 }
 
+func (n *NodeBase) templatePath() string {
+	return n.TemplatePath
+}
+
 func (n *NodeBase) error(reason e.Reason, message e.Message) e.Error {
 	if reason == "" {
 		reason = e.RuntimeErrorReason
@@ -98,6 +105,12 @@ const (
 	NodeTry
 	nodeCatch
 	NodeReturn
+	NodeTrans
+	NodeSwitch   // A switch action.
+	NodeCase     // A case of a switch action.
+	nodeDefault  // The default case of a switch action. Not added to tree.
+	NodeBreak    // A break action.
+	NodeContinue // A continue action.
 	beginExpressions
 	NodeString // A string constant.
 	NodeNil    // An untyped nil constant.
@@ -113,6 +126,7 @@ const (
 	NodeTernaryExpr
 	NodeIndexExpr
 	NodeSliceExpr
+	NodeCoalesceExpr
 	endExpressions
 )
 
@@ -122,6 +136,8 @@ const (
 type ListNode struct {
 	NodeBase
 	Nodes []Node // The element nodes in lexical order.
+
+	exec listFunc // compiled nodes, set when the Set compiles templates to closures
 }
 
 func (l *ListNode) append(n Node) {
@@ -174,6 +190,7 @@ type ActionNode struct {
 	NodeBase
 	Set  *SetNode
 	Pipe *PipeNode
+	src  string // source of the action, delimiters included
 }
 
 func (a *ActionNode) String() string {
@@ -190,6 +207,8 @@ func (a *ActionNode) String() string {
 type CommandNode struct {
 	NodeBase
 	CallExprNode
+	Colon  bool          // the arguments follow a colon rather than being between parentheses
+	filter reflect.Value // the filter the command applies, resolved at parse time; invalid for other commands
 }
 
 func (c *CommandNode) append(arg Node) {
@@ -208,6 +227,9 @@ func (c *CommandNode) String() string {
 		}
 		arguments += expr.String()
 	}
+	if c.Colon {
+		return fmt.Sprintf("%s: %s", c.BaseExpr, arguments)
+	}
 	return fmt.Sprintf("%s(%s)", c.BaseExpr, arguments)
 }
 
@@ -645,6 +667,13 @@ type NumericComparativeExprNode struct {
 	binaryExprNode
 }
 
+// CoalesceExprNode represents a null-coalescing expression, whose right side is only evaluated when the left side
+// is missing or nil
+// ex: expression '??' expression
+type CoalesceExprNode struct {
+	binaryExprNode
+}
+
 // NotExprNode represents a negate expression
 // ex: '!' expression
 type NotExprNode struct {
@@ -755,3 +784,114 @@ type catchNode struct {
 func (n *catchNode) String() string {
 	return fmt.Sprintf("{{catch %s}}%s{{end}}", n.Err, n.List)
 }
+
+// SwitchNode represents a {{switch}} action and its cases. Without an expression, each value of a case is a
+// condition.
+type SwitchNode struct {
+	NodeBase
+	Expression Expression // nil for a switch with no subject
+	Cases      []*CaseNode
+	Default    *ListNode // nil when there is no {{default}}
+}
+
+func (n *SwitchNode) String() string {
+	s := "{{switch}}"
+	if n.Expression != nil {
+		s = fmt.Sprintf("{{switch %s}}", n.Expression)
+	}
+	for _, c := range n.Cases {
+		s += c.String()
+	}
+	if n.Default != nil {
+		s += fmt.Sprintf("{{default}}%s", n.Default)
+	}
+	return s + "{{end}}"
+}
+
+// CaseNode represents a {{case}} of a switch and the list it executes.
+type CaseNode struct {
+	NodeBase
+	Values []Expression
+	List   *ListNode
+}
+
+func (n *CaseNode) String() string {
+	values := make([]string, len(n.Values))
+	for i, value := range n.Values {
+		values[i] = value.String()
+	}
+	s := fmt.Sprintf("{{case %s}}", strings.Join(values, ", "))
+	if n.List != nil {
+		s += n.List.String()
+	}
+	return s
+}
+
+// defaultNode represents a {{default}} action. Does not appear in the final tree.
+type defaultNode struct {
+	NodeBase
+}
+
+func (n *defaultNode) String() string {
+	return "{{default}}"
+}
+
+// LoopControlNode represents a {{break}} or {{continue}} action, which ends the iteration of the innermost
+// range loop or the loop itself. A condition makes the action conditional.
+type LoopControlNode struct {
+	NodeBase
+	Condition Expression // nil for an unconditional action
+}
+
+func (n *LoopControlNode) String() string {
+	keyword := "break"
+	if n.NodeType == NodeContinue {
+		keyword = "continue"
+	}
+	if n.Condition != nil {
+		return fmt.Sprintf("{{%s if %s}}", keyword, n.Condition)
+	}
+	return fmt.Sprintf("{{%s}}", keyword)
+}
+
+// TransArgument is a named argument (name=value) of a trans action or msg block.
+type TransArgument struct {
+	Name  string
+	Value Expression
+}
+
+// TransNode represents a {{trans}} action or a {{msg}}...{{end}} block.
+type TransNode struct {
+	NodeBase
+	Key   Expression // the message key; nil for a msg block without key, which uses the block's source as key
+	Args  []Expression
+	Named []TransArgument
+	List  *ListNode // body of a msg block, rendered when there is no translation; nil for trans
+}
+
+func (n *TransNode) arguments() string {
+	s := ""
+	if n.Key != nil {
+		s += " " + n.Key.String()
+	}
+	for i, arg := range n.Args {
+		if i > 0 {
+			s += ","
+		}
+		s += " " + arg.String()
+	}
+	for i, arg := range n.Named {
+		if i > 0 || len(n.Args) > 0 {
+			s += ","
+		}
+		s += fmt.Sprintf(" %s=%s", arg.Name, arg.Value)
+	}
+	return s
+}
+
+func (n *TransNode) String() string {
+	if n.List != nil {
+		return fmt.Sprintf("{{msg%s}}%s{{end}}", n.arguments(), n.List)
+	}
+	return fmt.Sprintf("{{trans%s}}", n.arguments())
+}
@@ -18,6 +18,7 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"reflect"
 	"runtime"
 	"strconv"
 	"strings"
@@ -41,61 +42,94 @@ type Template struct {
 	processedBlocks map[string]*BlockNode
 	passedBlocks    map[string]*BlockNode
 	Root            *ListNode // top-level root of the tree.
-	placeholders    []string
+	placeholders    []Placeholder
+	dependencies    []Dependency
+	version         string // source version reported by a VersionedLoader
 	text            string // text parsed to create the template (or its parent)
 
 	// Parsing only; cleared after parse.
 	lex       *lexer
 	token     [3]item // three-token lookahead for parser.
 	peekCount int
+	loops     int // range loops around the action being parsed, for break and continue
 }
 
-func (t *Template) Placeholders() []string {
-	return t.placeholders
-}
-
+// ParseMap executes the template with data and returns the result. A map or a struct passed as data becomes the
+// variables, and a struct the context as well; any other value is converted into a map through JSON. Like the
+// JSON conversion it replaces, structs are looked up by the names encoding/json gives their fields, as if the Set
+// had WithJSONTags, unless the Set has WithoutJSONTags. asMap, which makes a map the context instead, is only kept for compatibility:
+// RenderString sets the context, the variables and globals explicitly.
 func (t *Template) ParseMap(data any, asMap ...bool) (result string, err error) {
-	var d bytes.Buffer
-	var bt []byte
-	switch data := data.(type) {
-	case map[string]any:
-		if len(asMap) > 0 && asMap[0] {
-			err = t.Execute(&d, nil, data)
-			if err != nil {
-				return
-			}
-			result = d.String()
-		} else {
-			varMap := make(VarMap)
-			for k, v := range data {
-				varMap.Set(k, v)
-			}
-			err = t.Execute(&d, varMap, nil)
-			if err != nil {
-				return
-			}
-			result = d.String()
-		}
+	variables, context, err := splitData(data, t.set.dataJSONTags(), asMap...)
+	if err != nil {
 		return
-	case VarMap:
-		err = t.Execute(&d, data, nil)
-		if err != nil {
-			return
-		}
-		result = d.String()
+	}
+	var d bytes.Buffer
+	if err = t.execute(&d, variables, context, t.set.dataSetup()); err != nil {
 		return
-	default:
-		bt, err = json.Marshal(data)
-		if err != nil {
-			return
-		}
-		var d map[string]any
-		err = json.Unmarshal(bt, &d)
-		if err != nil {
-			return
-		}
-		return t.ParseMap(d)
 	}
+	return d.String(), nil
+}
+
+// dataSetup returns the setup of the executions of ParseMap and ParseMapSQL.
+func (s *Set) dataSetup() func(rt *Runtime) {
+	if s.dataJSONTags() {
+		return withJSONTags
+	}
+	return withGoFieldNames
+}
+
+func withJSONTags(rt *Runtime)     { rt.jsonTags = true }
+func withGoFieldNames(rt *Runtime) { rt.jsonTags = false }
+
+// splitData turns the data passed to ParseMap into the variables and the context to execute with.
+// A map becomes the variables, or the context if asMap is true; a struct becomes both, see inputVariables. Any other
+// value is converted into a map through JSON.
+func splitData(data any, jsonTags bool, asMap ...bool) (variables VarMap, context any, err error) {
+	if _, isVarMap := data.(VarMap); !isVarMap && len(asMap) > 0 && asMap[0] && reflect.ValueOf(data).Kind() == reflect.Map {
+		return nil, data, nil
+	}
+	if variables, context, ok := inputVariables(data, jsonTags); ok {
+		return variables, context, nil
+	}
+	bt, err := json.Marshal(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	var d map[string]any
+	if err = json.Unmarshal(bt, &d); err != nil {
+		return nil, nil, err
+	}
+	return splitData(d, jsonTags)
+}
+
+// structData turns a struct, or a pointer to a struct, into variables holding its exported fields and methods, and
+// makes it the context; values keep their types. Fields are named by their Go name or, with jsonTags, only by the
+// name encoding/json gives them, so that fields tagged json:"-" are left out. A struct passed by value is copied so that methods with a pointer receiver can be called. A nil pointer has
+// no variables. ok is false when data isn't a struct or a pointer to one.
+func structData(data any, jsonTags bool) (variables VarMap, context any, ok bool) {
+	v := reflect.ValueOf(data)
+	switch {
+	case v.Kind() == reflect.Struct:
+		ptr := reflect.New(v.Type())
+		ptr.Elem().Set(v)
+		v = ptr
+	case v.Kind() != reflect.Ptr || v.Type().Elem().Kind() != reflect.Struct:
+		return nil, nil, false
+	case v.IsNil():
+		return VarMap{}, nil, true
+	}
+
+	fields := structFields(v.Type().Elem(), jsonTags)
+	variables = make(VarMap, len(fields)+v.NumMethod())
+	for i := 0; i < v.NumMethod(); i++ {
+		variables[v.Type().Method(i).Name] = v.Method(i)
+	}
+	elem := v.Elem()
+	for name, index := range fields {
+		variables[name] = indirectEface(elem.FieldByIndex(index))
+	}
+	return variables, v.Interface(), true
 }
 
 func (t *Template) String() (template string) {
@@ -280,17 +314,11 @@ func (t *Template) recover(errp *error) {
 }
 
 func (s *Set) parse(name, text string, cacheAfterParsing bool) (t *Template, err e.Error) {
-	var placeholders []string
-	matches := s.placeholderParser.FindAllStringSubmatch(text, -1)
-	for _, match := range matches {
-		placeholders = append(placeholders, strings.TrimSpace(match[1]))
-	}
 	t = &Template{
 		Name:         name,
 		ParseName:    name,
 		text:         text,
 		set:          s,
-		placeholders: placeholders,
 		passedBlocks: make(map[string]*BlockNode),
 	}
 
@@ -302,6 +330,13 @@ func (s *Set) parse(name, text string, cacheAfterParsing bool) (t *Template, err
 		return nil, err
 	}
 	t.stopParse()
+	t.placeholders = t.findPlaceholders()
+	t.dependencies = append(t.dependencies, t.findIncludes()...)
+	if s.closures {
+		if err = t.compileClosures(); err != nil {
+			return nil, err
+		}
+	}
 
 	if t.extends != nil {
 		t.addBlocks(t.extends.processedBlocks)
@@ -356,12 +391,14 @@ func (t *Template) parseTemplate(cacheAfterParsing bool) (next Node, err e.Error
 					if err != nil {
 						return nil, t.error("", err.Error())
 					}
+					t.dependencies = append(t.dependencies, Dependency{Path: t.extends.Name, Kind: DependencyExtends, Line: t.lex.lineNumber()})
 				} else {
 					tt, err := t.set.getSiblingTemplate(s, t.Name, cacheAfterParsing)
 					if err != nil {
 						return nil, t.error("", err.Error())
 					}
 					t.imports = append(t.imports, tt)
+					t.dependencies = append(t.dependencies, Dependency{Path: tt.Name, Kind: DependencyImport, Line: t.lex.lineNumber()})
 				}
 				if err = t.expect(itemRightDelim, "extends|import", "closing delimiter"); err != nil {
 					return nil, err
@@ -384,6 +421,8 @@ func (t *Template) parseTemplate(cacheAfterParsing bool) (next Node, err e.Error
 		switch n.Type() {
 		case nodeEnd, nodeElse, nodeContent:
 			return nil, t.error(e.UnexpectedReason, fmt.Sprintf("unexpected %s", n))
+		case NodeCase, nodeDefault:
+			return nil, t.error(e.UnexpectedReason, fmt.Sprintf("%s outside of switch", n))
 		default:
 			t.Root.append(n)
 		}
@@ -409,6 +448,8 @@ func IsEmptyTree(n Node) bool {
 		return true
 	case *ActionNode:
 	case *IfNode:
+	case *SwitchNode:
+	case *LoopControlNode:
 	case *ListNode:
 		for _, node := range n.Nodes {
 			if !IsEmptyTree(node) {
@@ -422,6 +463,7 @@ func IsEmptyTree(n Node) bool {
 		return len(bytes.TrimSpace(n.Text)) == 0
 	case *BlockNode:
 	case *YieldNode:
+	case *TransNode:
 	default:
 		panic("unknown node: " + n.String())
 	}
@@ -512,6 +554,9 @@ func (t *Template) parseBlock() (Node, e.Error) {
 		return nil, err
 	}
 
+	// a block can be yielded anywhere, so the loops around its definition do not enclose its body
+	loops := t.loops
+	t.loops = 0
 	list, end, err := t.itemList(nodeContent, nodeEnd)
 	if err != nil {
 		return nil, err
@@ -524,6 +569,7 @@ func (t *Template) parseBlock() (Node, e.Error) {
 			return nil, err
 		}
 	}
+	t.loops = loops
 
 	block := t.newBlock(name.pos, t.lex.lineNumber(), name.val, bplist, pipe, list, contentList)
 	t.passedBlocks[block.Name] = block
@@ -632,6 +678,89 @@ func (t *Template) parseReturn() (Node, e.Error) {
 	return t.newReturn(value.Position(), t.lex.lineNumber(), value), nil
 }
 
+// Trans:
+//
+//	{{trans "key" arg1, arg2, name=value}}
+//
+// trans keyword is past.
+func (t *Template) parseTrans() (Node, e.Error) {
+	const context = "trans"
+	line := t.lex.lineNumber()
+	key, err := t.expression(context, "message key")
+	if err != nil {
+		return nil, err
+	}
+	args, named, err := t.translationArguments(context)
+	if err != nil {
+		return nil, err
+	}
+	return t.newTrans(key.Position(), line, key, args, named, nil), nil
+}
+
+// Msg:
+//
+//	{{msg}} itemList {{end}}
+//	{{msg "key" arg1, arg2, name=value}} itemList {{end}}
+//
+// msg keyword is past.
+func (t *Template) parseMsg() (Node, e.Error) {
+	const context = "msg"
+	line := t.lex.lineNumber()
+	pos := t.peekNonSpace().pos
+	var key Expression
+	var err e.Error
+	if t.peekNonSpace().typ != itemRightDelim {
+		key, err = t.expression(context, "message key")
+		if err != nil {
+			return nil, err
+		}
+	}
+	args, named, err := t.translationArguments(context)
+	if err != nil {
+		return nil, err
+	}
+	list, _, err := t.itemList(nodeEnd)
+	if err != nil {
+		return nil, err
+	}
+	return t.newTrans(pos, line, key, args, named, list), nil
+}
+
+// translationArguments parses the positional and named (name=value) arguments of a trans action or msg block,
+// separated by commas or spaces, up to and including the closing delimiter.
+func (t *Template) translationArguments(context string) (args []Expression, named []TransArgument, err e.Error) {
+	for {
+		switch t.peekNonSpace().typ {
+		case itemRightDelim:
+			t.nextNonSpace()
+			return args, named, nil
+		case itemComma:
+			t.nextNonSpace()
+			continue
+		}
+		expr, endtoken, err := t.parseExpression(context)
+		if err != nil {
+			return nil, nil, err
+		}
+		if endtoken.typ == itemAssign {
+			if expr.Type() != NodeIdentifier || endtoken.val != "=" {
+				return nil, nil, t.unexpected(endtoken, context, "name=value argument")
+			}
+			value, err := t.expression(context, "argument value")
+			if err != nil {
+				return nil, nil, err
+			}
+			named = append(named, TransArgument{Name: expr.(*IdentifierNode).Ident, Value: value})
+			continue
+		}
+		t.backup()
+		if len(named) > 0 {
+			return nil, nil, t.error(e.UnexpectedReason, fmt.Sprintf("parsing %s: positional argument %s after named arguments", context, expr))
+		}
+		args = append(args, expr)
+	}
+}
+
 // itemList:
 //
 //	textOrAction*
@@ -649,6 +778,9 @@ func (t *Template) itemList(terminatedBy ...NodeType) (list *ListNode, next Node
 				return list, n, nil
 			}
 		}
+		if typ := n.Type(); typ == NodeCase || typ == nodeDefault {
+			return nil, nil, t.error(e.UnexpectedReason, fmt.Sprintf("%s outside of switch", n))
+		}
 		list.append(n)
 	}
 
@@ -693,6 +825,18 @@ func (t *Template) action() (n Node, err e.Error) {
 		return t.parseCatch()
 	case itemReturn:
 		return t.parseReturn()
+	case itemTrans:
+		return t.parseTrans()
+	case itemMSG:
+		return t.parseMsg()
+	case itemSwitch:
+		return t.switchControl()
+	case itemCase:
+		return t.caseControl()
+	case itemDefault:
+		return t.defaultControl()
+	case itemBreak, itemContinue:
+		return t.loopControl(token)
 	}
 
 	t.backup()
@@ -722,9 +866,23 @@ func (t *Template) action() (n Node, err e.Error) {
 			return nil, err
 		}
 	}
+	action.src = t.actionSource(action.Pos)
 	return action, nil
 }
 
+// actionSource returns the source of the action at pos, from its left delimiter to the right delimiter just consumed.
+func (t *Template) actionSource(pos Pos) string {
+	end := t.token[t.peekCount]
+	if end.typ != itemRightDelim {
+		return ""
+	}
+	start := strings.LastIndex(t.lex.input[:pos], t.lex.leftDelim)
+	if start < 0 {
+		return ""
+	}
+	return t.lex.input[start : int(end.pos)+len(end.val)]
+}
+
 func (t *Template) logicalExpression(context string) (Expression, item, e.Error) {
 	left, endtoken, err := t.comparativeExpression(context)
 	if err != nil {
@@ -740,8 +898,24 @@ func (t *Template) logicalExpression(context string) (Expression, item, e.Error)
 	return left, endtoken, nil
 }
 
+// coalesceExpression parses '??', which binds less tightly than the logical operators.
+func (t *Template) coalesceExpression(context string) (Expression, item, e.Error) {
+	left, endtoken, err := t.logicalExpression(context)
+	if err != nil {
+		return nil, item{}, err
+	}
+	for endtoken.typ == itemCoalesce {
+		right, rightendtoken, err := t.logicalExpression(context)
+		if err != nil {
+			return nil, item{}, err
+		}
+		left, endtoken = t.newCoalesceExpr(left.Position(), t.lex.lineNumber(), left, right, endtoken), rightendtoken
+	}
+	return left, endtoken, nil
+}
+
 func (t *Template) parseExpression(context string) (Expression, item, e.Error) {
-	expression, endtoken, err := t.logicalExpression(context)
+	expression, endtoken, err := t.coalesceExpression(context)
 	if err != nil {
 		return nil, item{}, err
 	}
@@ -995,6 +1169,13 @@ func (t *Template) pipeline(context string, baseExprMutate Expression) (pipe *Pi
 			if err != nil {
 				return nil, err
 			}
+			if ident, ok := command.BaseExpr.(*IdentifierNode); ok {
+				var isFilter bool
+				command.filter, isFilter = t.set.commandFilter(ident.Ident)
+				if !isFilter && command.Colon && !isGlobal(t.set, ident.Ident) {
+					return nil, t.error("unknown.filter", fmt.Sprintf("unknown filter %q", ident.Ident))
+				}
+			}
 			pipe.append(command)
 		default:
 			if err = t.unexpected(token, "pipeline", "field or identifier"); err != nil {
@@ -1033,6 +1214,7 @@ func (t *Template) command(baseExpr Expression) (*CommandNode, e.Error) {
 			return nil, err
 		}
 		cmd.CallArgs = callArgs
+		cmd.Colon = true
 	default:
 		t.backup()
 	}
@@ -1105,7 +1287,7 @@ func (t *Template) operand(context string) (Expression, e.Error) {
 	for {
 		peek := t.peek()
 		if peek.typ == itemField || peek.typ == itemLaxField {
-			chain := t.newChain(t.peek().pos, node)
+			chain := t.newChain(node.Position(), node)
 			for t.peekNonSpace().typ == itemField || t.peekNonSpace().typ == itemLaxField {
 				chain.Add(t.next().val)
 			}
@@ -1234,10 +1416,17 @@ func (t *Template) parseControl(allowElseIf bool, context string) (pos Pos, line
 	}
 	var next Node
 	var ifControl Node
+	if context == "range" {
+		// break and continue apply to the body of the loop, not to its else list
+		t.loops++
+	}
 	list, next, err = t.itemList(nodeElse, nodeEnd)
 	if err != nil {
 		return
 	}
+	if context == "range" {
+		t.loops--
+	}
 	if next.Type() == nodeElse {
 		if allowElseIf && t.peek().typ == itemIf {
 			// Special case for "else if". If the "else" is followed immediately by an "if",
@@ -1397,6 +1586,132 @@ func (t *Template) parseCatch() (*catchNode, e.Error) {
 	return t.newCatch(peek.pos, line, errVar, list), nil
 }
 
+// Switch:
+//
+//	{{switch expression}} {{case expression, ...}} itemList ... {{default}} itemList {{end}}
+//	{{switch}} {{case condition, ...}} itemList ... {{default}} itemList {{end}}
+//
+// Only spaces may come between the switch and its first case; default is optional. Cases don't fall through, so
+// a break or continue in a case applies to the range around the switch.
+// Switch keyword is past.
+func (t *Template) switchControl() (Node, e.Error) {
+	const context = "switch"
+	line := t.lex.lineNumber()
+	pos := t.peekNonSpace().pos
+	var expression Expression
+	var err e.Error
+	if t.peekNonSpace().typ != itemRightDelim {
+		if expression, err = t.expression(context, "switch expression"); err != nil {
+			return nil, err
+		}
+	}
+	if err = t.expectRightDelim(context); err != nil {
+		return nil, err
+	}
+	switchNode := t.newSwitch(pos, line, expression)
+
+	before, next, err := t.itemList(NodeCase, nodeDefault, nodeEnd)
+	if err != nil {
+		return nil, err
+	}
+	for _, n := range before.Nodes {
+		if text, ok := n.(*TextNode); !ok || len(bytes.TrimSpace(text.Text)) > 0 {
+			return nil, t.error(e.UnexpectedReason, fmt.Sprintf("%s before the first case of switch", n))
+		}
+	}
+	for next.Type() != nodeEnd {
+		current := next
+		var list *ListNode
+		if list, next, err = t.itemList(NodeCase, nodeDefault, nodeEnd); err != nil {
+			return nil, err
+		}
+		if caseNode, ok := current.(*CaseNode); ok {
+			caseNode.List = list
+			switchNode.Cases = append(switchNode.Cases, caseNode)
+			continue
+		}
+		if switchNode.Default != nil {
+			return nil, t.error(e.UnexpectedReason, "multiple defaults in switch")
+		}
+		switchNode.Default = list
+	}
+	return switchNode, nil
+}
+
+// Case:
+//
+//	{{case expression, ...}}
+//
+// Case keyword is past.
+func (t *Template) caseControl() (Node, e.Error) {
+	const context = "case"
+	line := t.lex.lineNumber()
+	pos := t.peekNonSpace().pos
+	var values []Expression
+	for {
+		value, err := t.expression(context, "case value")
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+		if t.peekNonSpace().typ != itemComma {
+			break
+		}
+		t.nextNonSpace()
+	}
+	if err := t.expectRightDelim(context); err != nil {
+		return nil, err
+	}
+	return t.newCase(pos, line, values), nil
+}
+
+// Default:
+//
+//	{{default}}
+//
+// Default keyword is past.
+func (t *Template) defaultControl() (Node, e.Error) {
+	line := t.lex.lineNumber()
+	item, err := t.expectRightDelimI("default")
+	if err != nil {
+		return nil, err
+	}
+	return t.newDefault(item.pos, line), nil
+}
+
+// Break and continue:
+//
+//	{{break}}
+//	{{break if expression}}
+//	{{continue}}
+//	{{continue if expression}}
+//
+// Only allowed inside the body of a range, which they apply to even from inside a switch. Break or continue keyword
+// is past.
+func (t *Template) loopControl(token item) (Node, e.Error) {
+	context := token.val
+	line := t.lex.lineNumber()
+	if t.loops == 0 {
+		return nil, t.error(e.UnexpectedReason, fmt.Sprintf("%s outside of range", context))
+	}
+	var condition Expression
+	var err e.Error
+	if t.peekNonSpace().typ == itemIf {
+		t.nextNonSpace()
+		if condition, err = t.expression(context, "condition"); err != nil {
+			return nil, err
+		}
+	}
+	if err = t.expectRightDelim(context); err != nil {
+		return nil, err
+	}
+	typ := NodeBreak
+	if token.typ == itemContinue {
+		typ = NodeContinue
+	}
+	return t.newLoopControl(token.pos, line, typ, condition), nil
+}
+
 // term:
 //
 //	literal (number, string, nil, boolean)
@@ -0,0 +1,231 @@
+package jet
+
+import (
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type parseUser struct {
+	FirstName string       `json:"first_name"`
+	Nick      string       // no tag: encoding/json keeps the Go name
+	Password  string       `json:"-"`
+	Address   parseAddress `json:"address"`
+}
+
+type parseAddress struct {
+	City string `json:"city"`
+}
+
+func TestParseStructJSONNames(t *testing.T) {
+	user := parseUser{FirstName: "Ann", Nick: "an", Password: "secret", Address: parseAddress{City: "Oslo"}}
+	tests := []struct {
+		template, want string
+		fails          bool
+	}{
+		{template: `{{ first_name }}`, want: "Ann"},
+		{template: `{{ Nick }}`, want: "an"},
+		{template: `{{ address.city }}`, want: "Oslo"},
+		{template: `{{ .address.city }}`, want: "Oslo"},
+		{template: `{{ FirstName }}`, fails: true},
+		{template: `{{ .address.City }}`, fails: true},
+		{template: `{{ Password }}`, fails: true},
+		{template: `{{ .Password }}`, fails: true},
+	}
+	for _, tt := range tests {
+		got, err := Parse(tt.template, user)
+		if tt.fails {
+			if err == nil {
+				t.Errorf("Parse(%q) = %q, want an error", tt.template, got)
+			}
+		} else if err != nil || got != tt.want {
+			t.Errorf("Parse(%q) = %q, %v, want %q", tt.template, got, err, tt.want)
+		}
+
+		// the legacy API names fields by json tag whatever the Set
+		tmpl, err := NewSet(NewInMemLoader()).parseString(tt.template)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err = tmpl.ParseMap(&user)
+		if tt.fails {
+			if err == nil {
+				t.Errorf("ParseMap(%q) = %q, want an error", tt.template, got)
+			}
+		} else if err != nil || got != tt.want {
+			t.Errorf("ParseMap(%q) = %q, %v, want %q", tt.template, got, err, tt.want)
+		}
+	}
+	if got := Sprintf("{Password}", user); got != "{Password}" {
+		t.Errorf("Sprintf read a json:\"-\" field: %q", got)
+	}
+}
+
+func TestDefaultSetWithoutJSONTags(t *testing.T) {
+	defer func(set *Set) { defaultSet = set }(defaultSet)
+	DefaultSet(WithoutJSONTags())
+	got, err := Parse(`{{ FirstName }}`, parseUser{FirstName: "Ann"})
+	if err != nil || got != "Ann" {
+		t.Errorf("got %q, %v, want %q", got, err, "Ann")
+	}
+}
+
+func TestParseSQLStructJSONNames(t *testing.T) {
+	query, args, err := ParseSQL(`SELECT * FROM users WHERE name = {{ first_name }}`, parseUser{FirstName: "Ann"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "SELECT * FROM users WHERE name = ?"; query != want {
+		t.Errorf("query = %q, want %q", query, want)
+	}
+	if want := []interface{}{"Ann"}; !reflect.DeepEqual(args, want) {
+		t.Errorf("args = %v, want %v", args, want)
+	}
+}
+
+func TestExecuteKeepsGoNames(t *testing.T) {
+	tmpl, err := NewSet(NewInMemLoader()).parseString(`{{ .first_name }}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tmpl.Execute(io.Discard, nil, parseUser{FirstName: "Ann"}); err == nil {
+		t.Error("Execute found a field by its json name without WithJSONTags")
+	}
+}
+
+func TestSwitch(t *testing.T) {
+	tests := []struct {
+		name, template, want string
+	}{
+		{"subject", `{{ switch x }}{{ case 1, 2 }}low{{ case 3 }}three{{ default }}other{{ end }}`, "three"},
+		{"conditions", `{{ switch }}{{ case x > 5 }}big{{ case x > 2 }}mid{{- default -}}small{{ end }}`, "mid"},
+		{"default", `{{ switch x }}{{ case 1 }}one{{ default }}other{{ end }}`, "other"},
+		{"break leaves the range", `{{ range ints(0, 5) }}{{ switch . }}{{ case 2 }}{{ break }}{{ default }}{{ . }}{{ end }}{{ end }}`, "01"},
+		{"continue", `{{ range ints(0, 5) }}{{ switch . }}{{ case 2 }}{{ continue }}{{ end }}{{ . }}{{ end }}`, "0134"},
+		{"variables", `{{ case := 2 }}{{ switch := 3 }}{{ default := 4 }}{{ sum := case + switch }}{{ sum }}{{ default + 1 }}`, "55"},
+		{"assignment", `{{ case := 2 }}{{ case = case * 2 }}{{ sprint(case) }}`, "4"},
+		{"default filter", `{{ missing ?? nil | default("none") }}{{ nil | default: "colon" }}`, "nonecolon"},
+		{"in expressions", `{{ default := 3 }}{{ switch x }}{{ case default }}d{{ end }}{{ m.case }}{{ m["switch"] }}`, "dcs"},
+	}
+	for _, closures := range []bool{false, true} {
+		for _, tt := range tests {
+			var opts []Option
+			if closures {
+				opts = append(opts, WithClosureCompilation())
+			}
+			tmpl, err := NewSet(NewInMemLoader(), opts...).parseString(tt.template)
+			if err != nil {
+				t.Fatalf("%s: %v", tt.name, err)
+			}
+			vars := VarMap{}.Set("x", 3).Set("m", map[string]string{"case": "c", "switch": "s"})
+			var out strings.Builder
+			if err := tmpl.Execute(&out, vars, nil); err != nil {
+				t.Fatalf("%s: %v", tt.name, err)
+			}
+			if out.String() != tt.want {
+				t.Errorf("closures %v, %s: got %q, want %q", closures, tt.name, out.String(), tt.want)
+			}
+		}
+	}
+}
+
+func TestSwitchErrors(t *testing.T) {
+	tests := []struct {
+		template, want string
+	}{
+		{`{{ switch x }}a{{ case 1 }}{{ end }}`, `unexpected a before the first case of switch`},
+		{`{{ switch x }}{{ x }}{{ case 1 }}{{ end }}`, `unexpected {{x}} before the first case of switch`},
+		{`{{ switch x }}{{ default }}{{ default }}{{ end }}`, `unexpected multiple defaults in switch`},
+		{`{{ case 1 }}`, `unexpected {{case 1}} outside of switch`},
+		{`{{ if x }}{{ default }}{{ end }}`, `unexpected {{default}} outside of switch`},
+	}
+	for _, tt := range tests {
+		_, err := NewSet(NewInMemLoader()).parseString(tt.template)
+		if err == nil || err.Error() != tt.want {
+			t.Errorf("%s: got %v, want %s", tt.template, err, tt.want)
+		}
+	}
+}
+
+func TestDefaultGlobal(t *testing.T) {
+	set := NewSet(NewInMemLoader())
+	set.AddGlobal("default", func(v, fallback string) string {
+		if v == "" {
+			return fallback
+		}
+		return v
+	})
+	tmpl, err := set.parseString(`{{ default("", "a") }}{{ switch }}{{ default }}b{{ end }}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out strings.Builder
+	if err := tmpl.Execute(&out, nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "ab" {
+		t.Errorf("got %q, want %q", out.String(), "ab")
+	}
+}
+
+func TestCoalesce(t *testing.T) {
+	type user struct{ Name string }
+	tests := []struct {
+		name, template, want string
+		calls                int // calls of the right side
+	}{
+		{"missing", `{{ missing ?? "a" }}`, "a", 0},
+		{"nil", `{{ nil ?? "a" }}`, "a", 0},
+		{"missing map key", `{{ m.nokey ?? "a" }}{{ m["nokey"] ?? "b" }}`, "ab", 0},
+		{"lax field", `{{ nobody?.Name ?? "anon" }}`, "anon", 0},
+		{"lax index", `{{ s?[5] ?? "none" }}`, "none", 0},
+		{"set", `{{ x ?? 0 }}{{ m.key ?? "a" }}`, "5value", 0},
+		{"zero values are set", `{{ zero ?? 1 }}[{{ empty ?? "a" }}]{{ no ?? true }}`, "0[]false", 0},
+		{"chained", `{{ missing ?? nil ?? m.nokey ?? "c" }}`, "c", 0},
+		{"right side on missing", `{{ missing ?? right() }}{{ nil ?? right() }}{{ m.nokey ?? right() }}`, "rrr", 3},
+		{"right side on set", `{{ x ?? right() }}{{ zero ?? right() }}{{ empty ?? right() }}`, "50", 0},
+		{"binds less than ==", `{{ missing ?? 1 == 1 }} {{ x ?? 0 == 1 }}`, "true 5", 0},
+		{"binds less than ||", `{{ missing ?? false || true }} {{ x ?? false || true }}`, "true 5", 0},
+		{"binds less than &&", `{{ no ?? true && false }}`, "false", 0},
+		{"parenthesized", `{{ (missing ?? 1) == 1 }} {{ (x ?? 0) == 1 }}`, "true false", 0},
+		{"in a ternary", `{{ missing ?? false ? "y" : "n" }}`, "n", 0},
+		{"isset", `{{ isset(missing ?? x) }} {{ isset(missing ?? other) }} {{ isset(x ?? missing) }}`, "true false true", 0},
+		{"isset of nil", `{{ isset(nil ?? m.nokey) }}`, "false", 0},
+		{"pipeline", `{{ missing ?? "a" | upper }}`, "A", 0},
+		{"default filter", `[{{ empty | default("n/a") }}][{{ empty ?? "n/a" }}][{{ missing ?? "" | default("n/a") }}]`, "[n/a][][n/a]", 0},
+	}
+	for _, closures := range []bool{false, true} {
+		var opts []Option
+		if closures {
+			opts = append(opts, WithClosureCompilation())
+		}
+		s := NewSet(NewInMemLoader(), opts...)
+		for _, tt := range tests {
+			tmpl, err := s.parseString(tt.template)
+			if err != nil {
+				t.Fatalf("%s: %v", tt.name, err)
+			}
+			calls := 0
+			vars := VarMap{}.
+				Set("x", 5).Set("zero", 0).Set("empty", "").Set("no", false).
+				Set("m", map[string]string{"key": "value"}).
+				Set("s", []string{"a"}).
+				Set("nobody", (*user)(nil)).
+				SetFunc("right", func(Arguments) reflect.Value {
+					calls++
+					return reflect.ValueOf("r")
+				})
+			var out strings.Builder
+			if err := tmpl.Execute(&out, vars, nil); err != nil {
+				t.Fatalf("closures %v, %s: %v", closures, tt.name, err)
+			}
+			if out.String() != tt.want {
+				t.Errorf("closures %v, %s: got %q, want %q", closures, tt.name, out.String(), tt.want)
+			}
+			if calls != tt.calls {
+				t.Errorf("closures %v, %s: the right side was evaluated %d times, want %d", closures, tt.name, calls, tt.calls)
+			}
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package jet
+
+import (
+	"hash/maphash"
+	"sync/atomic"
+)
+
+// DefaultParseCacheSize is the number of template strings a Set keeps parsed unless configured with
+// WithParseCacheSize.
+const DefaultParseCacheSize = 512
+
+// WithParseCacheSize returns an option function that sets how many template strings parsed through the
+// string-based API (ParseContent, ParseTemplate, ParseSQL and the package-level Parse, Sprintf, NewTemplate and
+// Placeholders) are kept parsed. The least recently used template is dropped first; a size of 0 or less disables
+// the cache.
+func WithParseCacheSize(size int) Option {
+	return func(s *Set) {
+		s.parseCacheSize = size
+	}
+}
+
+// CacheStats reports the usage of a cache.
+type CacheStats struct {
+	Hits     uint64
+	Misses   uint64
+	Len      int // entries currently held
+	Capacity int // maximum number of entries
+}
+
+// maxSprintfSets is the number of delimiter pairs Sprintf keeps a Set for.
+const maxSprintfSets = 16
+
+// sprintfSets holds the Set used by Sprintf for the most recently used delimiters, so that formats are parsed only
+// once.
+var sprintfSets = newLRU[Delims, *Set](maxSprintfSets)
+
+func sprintfSet(delim Delims) *Set {
+	if set, ok := sprintfSets.get(delim); ok {
+		return set
+	}
+	set := NewMemorySet(WithDelims(delim.Left, delim.Right))
+	sprintfSets.put(delim, set)
+	return set
+}
+
+var parseCacheSeed = maphash.MakeSeed()
+
+// parseCache holds templates parsed from strings, keyed by the hash of their source.
+type parseCache struct {
+	entries      *lru[uint64, *Template]
+	hits, misses atomic.Uint64
+}
+
+func newParseCache(size int) *parseCache {
+	if size <= 0 {
+		return nil
+	}
+	return &parseCache{entries: newLRU[uint64, *Template](size)}
+}
+
+// parseString parses text as an anonymous template, reusing the result of a previous call with the same text.
+func (s *Set) parseString(text string) (*Template, error) {
+	c := s.parseCache
+	if c == nil {
+		return s.parse("", text, true)
+	}
+	key := maphash.String(parseCacheSeed, text)
+	if t, ok := c.entries.get(key); ok && t.text == text {
+		c.hits.Add(1)
+		return t, nil
+	}
+	c.misses.Add(1)
+	t, err := s.parse("", text, true)
+	if err != nil {
+		return nil, err
+	}
+	c.entries.put(key, t)
+	return t, nil
+}
+
+// ParseCacheStats returns the statistics of the Set's cache of parsed template strings.
+func (s *Set) ParseCacheStats() CacheStats {
+	c := s.parseCache
+	if c == nil {
+		return CacheStats{}
+	}
+	return CacheStats{
+		Hits:     c.hits.Load(),
+		Misses:   c.misses.Load(),
+		Len:      c.entries.len(),
+		Capacity: c.entries.capacity,
+	}
+}
+
+// ParseCacheStats returns the statistics of the default Set's cache of parsed template strings.
+func ParseCacheStats() CacheStats {
+	return defaultSet.ParseCacheStats()
+}
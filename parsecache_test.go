@@ -0,0 +1,91 @@
+package jet
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestParseCache(t *testing.T) {
+	s := NewSet(NewInMemLoader(), WithParseCacheSize(2))
+	parse := func(text string) *Template {
+		t.Helper()
+		tmpl, err := s.parseString(text)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tmpl
+	}
+	a := parse("a{{ 1 }}")
+	if parse("a{{ 1 }}") != a {
+		t.Error("parsing the same text again returned another template")
+	}
+	parse("b{{ 1 }}")
+	parse("c{{ 1 }}") // evicts a
+	if got, want := s.ParseCacheStats(), (CacheStats{Hits: 1, Misses: 3, Len: 2, Capacity: 2}); got != want {
+		t.Errorf("stats: got %+v, want %+v", got, want)
+	}
+	if parse("a{{ 1 }}") == a {
+		t.Error("the least recently used template was not evicted")
+	}
+	if got := s.ParseCacheStats(); got.Misses != 4 || got.Len != 2 {
+		t.Errorf("stats after parsing an evicted text: got %+v", got)
+	}
+	if _, err := s.parseString("{{ if }}"); err == nil {
+		t.Fatal("parsing an invalid text succeeded")
+	}
+	if got := s.ParseCacheStats(); got.Len != 2 {
+		t.Errorf("a template failing to parse was cached: got %+v", got)
+	}
+}
+
+func TestParseCacheDisabled(t *testing.T) {
+	s := NewSet(NewInMemLoader(), WithParseCacheSize(0))
+	a, err := s.parseString("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := s.parseString("a"); b == a {
+		t.Error("a Set without parse cache returned the same template twice")
+	}
+	if got := s.ParseCacheStats(); got != (CacheStats{}) {
+		t.Errorf("stats: got %+v, want none", got)
+	}
+}
+
+func TestParseCachePackageLevel(t *testing.T) {
+	const format = "Hi {parse_cache_test}"
+	data := map[string]any{"parse_cache_test": "Ann"}
+	stats := sprintfSet(Delims{Left: "{", Right: "}"}).ParseCacheStats
+	before := stats()
+	for i := 0; i < 3; i++ {
+		if got := Sprintf(format, data); got != "Hi Ann" {
+			t.Fatalf("got %q, want %q", got, "Hi Ann")
+		}
+	}
+	if got := stats(); got.Hits-before.Hits < 2 || got.Len > got.Capacity {
+		t.Errorf("Sprintf: stats went from %+v to %+v", before, got)
+	}
+
+	const template = "Hi {{ parse_cache_test }}"
+	before = ParseCacheStats()
+	for i := 0; i < 3; i++ {
+		if got, err := Parse(template, data); err != nil || got != "Hi Ann" {
+			t.Fatalf("got %q, %v, want %q", got, err, "Hi Ann")
+		}
+	}
+	if got := ParseCacheStats(); got.Hits-before.Hits < 2 || got.Len > got.Capacity {
+		t.Errorf("Parse: stats went from %+v to %+v", before, got)
+	}
+}
+
+func TestSprintfSetsAreBounded(t *testing.T) {
+	for i := 0; i < 2*maxSprintfSets; i++ {
+		left := "<" + strconv.Itoa(i) + "|"
+		if got := Sprintf(left+"name>", map[string]any{"name": "Ann"}, &Delims{Left: left, Right: ">"}); got != "Ann" {
+			t.Fatalf("got %q, want %q", got, "Ann")
+		}
+	}
+	if n := sprintfSets.len(); n > maxSprintfSets {
+		t.Errorf("Sprintf holds %d sets, want at most %d", n, maxSprintfSets)
+	}
+}
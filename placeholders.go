@@ -0,0 +1,490 @@
+package jet
+
+import (
+	"strings"
+)
+
+// PlaceholderRoot tells where the value of a placeholder comes from.
+type PlaceholderRoot int
+
+const (
+	// PlaceholderVariable is a variable passed to Execute, i.e. a key of the data passed to ParseMap.
+	PlaceholderVariable PlaceholderRoot = iota
+	// PlaceholderContext is a field of the context (`.name`).
+	PlaceholderContext
+	// PlaceholderRange is a field of an element of a range loop, reached through a loop variable or through the
+	// context inside a range with no element variable.
+	PlaceholderRange
+)
+
+func (r PlaceholderRoot) String() string {
+	switch r {
+	case PlaceholderContext:
+		return "context"
+	case PlaceholderRange:
+		return "range"
+	default:
+		return "variable"
+	}
+}
+
+// Placeholder describes a value a template reads from its input.
+type Placeholder struct {
+	// Path is the dotted field path, e.g. "address.city". For PlaceholderRange it starts with the loop variable
+	// ("item.price"), or is relative to the element when the element is the context ("price").
+	Path string
+	Root PlaceholderRoot
+	// Range is the collection expression ranged over, as written in the template (PlaceholderRange only).
+	Range string
+	// Funcs lists the functions applied to the value, innermost first.
+	Funcs []string
+	// Line is the line of the first use of the placeholder.
+	Line int
+}
+
+func (p Placeholder) String() string {
+	if p.Root == PlaceholderContext {
+		return "." + p.Path
+	}
+	return p.Path
+}
+
+// Placeholders returns the values read by the template from its variables and context, in order of first use.
+// Local variables, globals and default variables are not placeholders.
+func (t *Template) Placeholders() []Placeholder {
+	return t.placeholders
+}
+
+// findPlaceholders walks the parsed tree of t to collect its placeholders.
+func (t *Template) findPlaceholders() []Placeholder {
+	w := &placeholderWalker{set: t.set, index: map[placeholderKey]int{}}
+	w.push()
+	w.walkList(t.Root)
+	return w.placeholders
+}
+
+// placeholderBinding is what a name declared in the template refers to.
+type placeholderBinding struct {
+	rangeOf string // collection ranged over, for loop variables and the context of a range; empty for locals
+	local   bool   // the value is not an input of the template
+	probe   *bool  // set to true when fields of the value are read; see readsFields
+}
+
+type placeholderWalker struct {
+	set          *Set
+	scopes       []map[string]placeholderBinding
+	dots         []placeholderBinding // context overrides, innermost last
+	line         int
+	placeholders []Placeholder
+	index        map[placeholderKey]int
+}
+
+// placeholderKey identifies a placeholder in placeholderWalker.placeholders.
+type placeholderKey struct {
+	root      PlaceholderRoot
+	path, rng string
+}
+
+func (w *placeholderWalker) push() {
+	w.scopes = append(w.scopes, map[string]placeholderBinding{})
+}
+
+func (w *placeholderWalker) pop() {
+	w.scopes = w.scopes[:len(w.scopes)-1]
+}
+
+func (w *placeholderWalker) declare(node Expression, binding placeholderBinding) {
+	if ident, ok := node.(*IdentifierNode); ok {
+		w.scopes[len(w.scopes)-1][ident.Ident] = binding
+	}
+}
+
+func (w *placeholderWalker) lookup(name string) (placeholderBinding, bool) {
+	for i := len(w.scopes) - 1; i >= 0; i-- {
+		if b, ok := w.scopes[i][name]; ok {
+			return b, true
+		}
+	}
+	return placeholderBinding{}, false
+}
+
+func (w *placeholderWalker) setLine(node Node) {
+	if line := node.line(); line > 0 {
+		w.line = line
+	}
+}
+
+// add records a placeholder and returns its position, or -1 if the path does not read an input.
+func (w *placeholderWalker) add(root string, fields []string) int {
+	p := Placeholder{}
+	if root == "." {
+		if len(fields) == 0 {
+			return -1
+		}
+		p.Root, p.Path = PlaceholderContext, strings.Join(fields, ".")
+		if len(w.dots) > 0 {
+			dot := w.dots[len(w.dots)-1]
+			if dot.local {
+				return -1
+			}
+			p.Root, p.Range = PlaceholderRange, dot.rangeOf
+		}
+	} else {
+		p.Path = strings.Join(append([]string{root}, fields...), ".")
+		if b, ok := w.lookup(root); ok {
+			if b.probe != nil {
+				*b.probe = *b.probe || len(fields) > 0
+				return -1
+			}
+			if b.local {
+				return -1
+			}
+			p.Root, p.Range = PlaceholderRange, b.rangeOf
+		} else if isGlobal(w.set, root) {
+			return -1
+		}
+	}
+
+	key := placeholderKey{root: p.Root, path: p.Path, rng: p.Range}
+	if i, ok := w.index[key]; ok {
+		return i
+	}
+	i := len(w.placeholders)
+	w.index[key] = i
+	p.Line = w.line
+	w.placeholders = append(w.placeholders, p)
+	return i
+}
+
+// isGlobal tells whether name is a default variable or a global of set, which may be nil.
+func isGlobal(set *Set, name string) bool {
+	if _, ok := defaultVariables[name]; ok {
+		return true
+	}
+	if set == nil {
+		return false
+	}
+	set.gmx.RLock()
+	defer set.gmx.RUnlock()
+	_, ok := set.globals[name]
+	return ok
+}
+
+// apply records that fn is applied to the placeholders at the given positions.
+func (w *placeholderWalker) apply(fn Expression, values []int) {
+	ident, ok := fn.(*IdentifierNode)
+	if !ok {
+		return
+	}
+	if b, declared := w.lookup(ident.Ident); declared && !b.local {
+		return
+	}
+	for _, i := range values {
+		p := &w.placeholders[i]
+		found := false
+		for _, f := range p.Funcs {
+			found = found || f == ident.Ident
+		}
+		if !found {
+			p.Funcs = append(p.Funcs, ident.Ident)
+		}
+	}
+}
+
+// path resolves expressions made of an identifier or the context followed by fields and constant string indexes.
+// complete is false when the path stops at a dynamic index; any other expression is not a path.
+func (w *placeholderWalker) path(node Node) (root string, fields []string, complete, ok bool) {
+	switch node := node.(type) {
+	case *FieldNode:
+		return ".", node.Idents.names(), true, true
+	case *IdentifierNode:
+		return node.Ident, nil, true, true
+	case *ChainNode:
+		root, fields, complete, ok = w.path(node.Node)
+		if ok && complete {
+			fields = append(fields, node.Field.names()...)
+		}
+		return
+	case *IndexExprNode:
+		root, fields, complete, ok = w.path(node.Base)
+		if !ok || !complete {
+			if ok {
+				w.walk(node.Index)
+			}
+			return
+		}
+		if key, isString := node.Index.(*StringNode); isString {
+			return root, append(fields, key.Text), true, true
+		}
+		w.walk(node.Index)
+		return root, fields, false, true
+	}
+	return "", nil, false, false
+}
+
+// walk visits an expression and returns the positions of the placeholders whose value it evaluates to.
+func (w *placeholderWalker) walk(node Node) []int {
+	if node == nil {
+		return nil
+	}
+	w.setLine(node)
+	if root, fields, _, ok := w.path(node); ok {
+		if i := w.add(root, fields); i >= 0 {
+			return []int{i}
+		}
+		return nil
+	}
+
+	switch node := node.(type) {
+	case *ChainNode:
+		return w.walk(node.Node)
+	case *IndexExprNode:
+		w.walk(node.Index)
+		return w.walk(node.Base)
+	case *SliceExprNode:
+		w.walk(node.Index)
+		w.walk(node.EndIndex)
+		return w.walk(node.Base)
+	case *PipeNode:
+		return w.walkPipe(node)
+	case *CommandNode:
+		return w.walkCall(&node.CallExprNode, node.CallExprNode.NodeType == NodeCallExpr || node.Exprs != nil)
+	case *CallExprNode:
+		return w.walkCall(node, true)
+	case *AdditiveExprNode:
+		return append(w.walk(node.Left), w.walk(node.Right)...)
+	case *MultiplicativeExprNode:
+		return append(w.walk(node.Left), w.walk(node.Right)...)
+	case *LogicalExprNode:
+		return append(w.walk(node.Left), w.walk(node.Right)...)
+	case *CoalesceExprNode:
+		return append(w.walk(node.Left), w.walk(node.Right)...)
+	case *ComparativeExprNode:
+		w.walk(node.Left)
+		w.walk(node.Right)
+	case *NumericComparativeExprNode:
+		w.walk(node.Left)
+		w.walk(node.Right)
+	case *NotExprNode:
+		w.walk(node.Expr)
+	case *TernaryExprNode:
+		w.walk(node.Boolean)
+		return append(w.walk(node.Left), w.walk(node.Right)...)
+	}
+	return nil
+}
+
+func (w *placeholderWalker) walkCall(call *CallExprNode, isCall bool) []int {
+	if !isCall {
+		return w.walk(call.BaseExpr)
+	}
+	var values []int
+	for _, arg := range call.Exprs {
+		values = append(values, w.walk(arg)...)
+	}
+	if _, ok := call.BaseExpr.(*IdentifierNode); !ok {
+		w.walk(call.BaseExpr)
+	}
+	w.apply(call.BaseExpr, values)
+	return values
+}
+
+func (w *placeholderWalker) walkPipe(pipe *PipeNode) []int {
+	var values []int
+	for i, cmd := range pipe.Cmds {
+		if i == 0 {
+			values = w.walk(cmd)
+			continue
+		}
+		for _, arg := range cmd.Exprs {
+			w.walk(arg)
+		}
+		w.apply(cmd.BaseExpr, values)
+	}
+	return values
+}
+
+func (w *placeholderWalker) walkSet(set *SetNode) {
+	for _, right := range set.Right {
+		w.walk(right)
+	}
+	for _, left := range set.Left {
+		if set.Let {
+			w.declare(left, placeholderBinding{local: true})
+			continue
+		}
+		if _, isIdent := left.(*IdentifierNode); !isIdent {
+			// assignments to fields and indexes read the value they are assigned into
+			w.walk(left)
+		}
+	}
+}
+
+func (w *placeholderWalker) walkList(list *ListNode) {
+	if list == nil {
+		return
+	}
+	for _, node := range list.Nodes {
+		w.walkNode(node)
+	}
+}
+
+// walkScope walks list in a new scope, declaring names before visiting it.
+func (w *placeholderWalker) walkScope(list *ListNode, declare func()) {
+	if list == nil {
+		return
+	}
+	w.push()
+	if declare != nil {
+		declare()
+	}
+	w.walkList(list)
+	w.pop()
+}
+
+func (w *placeholderWalker) walkParameters(params *BlockParameterList) {
+	if params == nil {
+		return
+	}
+	for _, param := range params.List {
+		w.walk(param.Expression)
+	}
+}
+
+func (w *placeholderWalker) walkNode(node Node) {
+	w.setLine(node)
+	switch node := node.(type) {
+	case *ActionNode:
+		if node.Set != nil {
+			w.walkSet(node.Set)
+		}
+		if node.Pipe != nil {
+			w.walkPipe(node.Pipe)
+		}
+	case *IfNode:
+		w.push()
+		if node.Set != nil {
+			w.walkSet(node.Set)
+		}
+		w.walk(node.Expression)
+		w.walkScope(node.List, nil)
+		w.pop()
+		w.walkScope(node.ElseList, nil)
+	case *SwitchNode:
+		w.walk(node.Expression)
+		for _, c := range node.Cases {
+			for _, value := range c.Values {
+				w.walk(value)
+			}
+			w.walkScope(c.List, nil)
+		}
+		w.walkScope(node.Default, nil)
+	case *LoopControlNode:
+		w.walk(node.Condition)
+	case *RangeNode:
+		w.walkRange(node)
+	case *BlockNode:
+		w.walk(node.Expression)
+		w.walkParameters(node.Parameters)
+		local := node.Expression != nil
+		w.walkScope(node.List, func() {
+			if local {
+				w.dots = append(w.dots, placeholderBinding{local: true})
+			}
+			if node.Parameters == nil {
+				return
+			}
+			for _, param := range node.Parameters.List {
+				w.scopes[len(w.scopes)-1][param.Identifier] = placeholderBinding{local: true}
+			}
+		})
+		if local {
+			w.dots = w.dots[:len(w.dots)-1]
+		}
+		w.walkScope(node.Content, nil)
+	case *YieldNode:
+		w.walkParameters(node.Parameters)
+		w.walk(node.Expression)
+		w.walkScope(node.Content, nil)
+	case *IncludeNode:
+		w.walk(node.Name)
+		w.walk(node.Context)
+	case *ReturnNode:
+		w.walk(node.Value)
+	case *TryNode:
+		w.walkScope(node.List, nil)
+		if node.Catch != nil {
+			w.walkScope(node.Catch.List, func() {
+				if node.Catch.Err != nil {
+					w.declare(node.Catch.Err, placeholderBinding{local: true})
+				}
+			})
+		}
+	case *TransNode:
+		w.walk(node.Key)
+		for _, arg := range node.Args {
+			w.walk(arg)
+		}
+		for _, arg := range node.Named {
+			w.walk(arg.Value)
+		}
+		w.walkScope(node.List, nil)
+	}
+}
+
+func (w *placeholderWalker) walkRange(node *RangeNode) {
+	collection := node.Expression
+	if node.Set != nil {
+		collection = node.Set.Right[0]
+	}
+	w.walk(collection)
+	element := placeholderBinding{rangeOf: collection.String()}
+
+	// With a single variable, the variable gets the index or key and the context the element, except over a
+	// channel, which has no index: there the variable gets the element and the context is left as is. Which one
+	// applies is only known at run time, so the variable is taken for the element when its fields are read.
+	single := node.Set != nil && len(node.Set.Left) == 1
+	elementIsDot := node.Set == nil || single && !w.readsFields(node.List, node.Set.Left[0])
+	w.walkScope(node.List, func() {
+		if _, declared := w.lookup(loopVariable); !declared {
+			w.scopes[len(w.scopes)-1][loopVariable] = placeholderBinding{local: true}
+		}
+		switch {
+		case node.Set == nil:
+		case single && !elementIsDot:
+			w.declare(node.Set.Left[0], element)
+		default:
+			w.declare(node.Set.Left[0], placeholderBinding{local: true})
+			if len(node.Set.Left) > 1 {
+				w.declare(node.Set.Left[1], element)
+			}
+		}
+		if elementIsDot {
+			w.dots = append(w.dots, element)
+		}
+	})
+	if elementIsDot {
+		w.dots = w.dots[:len(w.dots)-1]
+	}
+	w.walkScope(node.ElseList, nil)
+}
+
+// readsFields reports whether list reads fields of the range variable declared by variable, by walking it with a
+// throwaway walker.
+func (w *placeholderWalker) readsFields(list *ListNode, variable Expression) bool {
+	ident, ok := variable.(*IdentifierNode)
+	if !ok || list == nil {
+		return false
+	}
+	read := false
+	probe := &placeholderWalker{
+		set:    w.set,
+		scopes: append([]map[string]placeholderBinding(nil), w.scopes...),
+		dots:   append([]placeholderBinding(nil), w.dots...),
+		index:  map[placeholderKey]int{},
+	}
+	probe.walkScope(list, func() {
+		probe.scopes[len(probe.scopes)-1][ident.Ident] = placeholderBinding{probe: &read}
+	})
+	return read
+}
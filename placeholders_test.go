@@ -0,0 +1,69 @@
+package jet
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPlaceholders(t *testing.T) {
+	tests := []struct {
+		name, template string
+		want           []Placeholder
+	}{
+		{"variable", "{{ name }}", []Placeholder{{Path: "name", Line: 1}}},
+		{"fields", "{{ user.address.city }}", []Placeholder{{Path: "user.address.city", Line: 1}}},
+		{"context", "{{ .title }}", []Placeholder{{Path: "title", Root: PlaceholderContext, Line: 1}}},
+		{"funcs", "{{ name | upper | trim }}", []Placeholder{{Path: "name", Funcs: []string{"upper", "trim"}, Line: 1}}},
+		{"line", "a\n{{ name }}\n{{ name }}", []Placeholder{{Path: "name", Line: 2}}},
+		{"locals and globals", "{{ x := 1 }}{{ x }}{{ len(items) }}", []Placeholder{{Path: "items", Funcs: []string{"len"}, Line: 1}}},
+		{"range context", "{{ range items }}{{ .price }}{{ end }}", []Placeholder{
+			{Path: "items", Line: 1},
+			{Path: "price", Root: PlaceholderRange, Range: "items", Line: 1},
+		}},
+		{"range value", "{{ range i, item := items }}{{ i }}{{ item.price }}{{ end }}", []Placeholder{
+			{Path: "items", Line: 1},
+			{Path: "item.price", Root: PlaceholderRange, Range: "items", Line: 1},
+		}},
+		{"range index", "{{ range i := items }}{{ i }}{{ .price }}{{ end }}", []Placeholder{
+			{Path: "items", Line: 1},
+			{Path: "price", Root: PlaceholderRange, Range: "items", Line: 1},
+		}},
+		{"range channel", "{{ range item := queue }}{{ item.price }}{{ .title }}{{ end }}", []Placeholder{
+			{Path: "queue", Line: 1},
+			{Path: "item.price", Root: PlaceholderRange, Range: "queue", Line: 1},
+			{Path: "title", Root: PlaceholderContext, Line: 1},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, err := NewSet(NewInMemLoader()).parseString(tt.template)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := tmpl.Placeholders(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPackagePlaceholders(t *testing.T) {
+	got := Placeholders("{{ .title }} {{ range item := queue }}{{ item.price }}{{ end }}")
+	want := []string{".title", "queue", "item.price"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Placeholders: got %q, want %q", got, want)
+	}
+	if got := Placeholders("{{ if }}"); got != nil {
+		t.Errorf("Placeholders of an invalid template: got %q, want nil", got)
+	}
+	placeholders, err := ParsePlaceholders("{{ .title }}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []Placeholder{{Path: "title", Root: PlaceholderContext, Line: 1}}; !reflect.DeepEqual(placeholders, want) {
+		t.Errorf("ParsePlaceholders: got %+v, want %+v", placeholders, want)
+	}
+	if _, err := ParsePlaceholders("{{ if }}"); err == nil {
+		t.Error("ParsePlaceholders of an invalid template: expected an error")
+	}
+}
@@ -3,8 +3,12 @@ package jet
 import (
 	"errors"
 	"fmt"
+	"iter"
+	"math"
 	"reflect"
 	"sync"
+
+	"github.com/oarkflow/jet/utils/e"
 )
 
 // Ranger describes an interface for types that iterate over something.
@@ -15,6 +19,10 @@ type Ranger interface {
 	// whether there are more values to be generated.
 	//
 	// When the done flag is true, then the loop ends.
+	//
+	// When the body of the loop reads loop.last, Range is called for the
+	// next values before the body is done with the current ones, so the
+	// values it returns must stay valid across calls.
 	Range() (reflect.Value, reflect.Value, bool)
 
 	// ProvidesIndex should return true if keys are produced during Range()
@@ -23,6 +31,13 @@ type Ranger interface {
 	ProvidesIndex() bool
 }
 
+// sizedRanger is implemented by the rangers that know how many values they produce before the first Range call,
+// which gives the length of the loop variable and spares looking ahead to find the last value.
+type sizedRanger interface {
+	Ranger
+	size() int
+}
+
 type intsRanger struct {
 	i, val, to int64
 }
@@ -45,6 +60,8 @@ func (r *intsRanger) Range() (index, value reflect.Value, end bool) {
 
 func (r *intsRanger) ProvidesIndex() bool { return true }
 
+func (r *intsRanger) size() int { return int(r.to - r.val - 1) }
+
 func newIntsRanger(from, to int64) *intsRanger {
 	r := &intsRanger{
 		to:  to,
@@ -57,6 +74,9 @@ func newIntsRanger(from, to int64) *intsRanger {
 type pooledRanger interface {
 	Ranger
 	Setup(reflect.Value)
+	// Reset drops the references to the ranged value before the ranger goes back to its pool, since the
+	// range may have ended early (break, return, error) and left it in the middle of the value.
+	Reset()
 }
 
 type sliceRanger struct {
@@ -74,6 +94,11 @@ func (r *sliceRanger) Setup(v reflect.Value) {
 	r.v = v
 }
 
+func (r *sliceRanger) Reset() {
+	r.i = 0
+	r.v = reflect.Value{}
+}
+
 func (r *sliceRanger) Range() (index, value reflect.Value, end bool) {
 	if r.i == r.v.Len() {
 		end = true
@@ -87,9 +112,12 @@ func (r *sliceRanger) Range() (index, value reflect.Value, end bool) {
 
 func (r *sliceRanger) ProvidesIndex() bool { return true }
 
+func (r *sliceRanger) size() int { return r.v.Len() }
+
 type mapRanger struct {
 	iter    *reflect.MapIter
 	hasMore bool
+	len     int
 }
 
 var (
@@ -100,6 +128,12 @@ var (
 func (r *mapRanger) Setup(v reflect.Value) {
 	r.iter = v.MapRange()
 	r.hasMore = r.iter.Next()
+	r.len = v.Len()
+}
+
+func (r *mapRanger) Reset() {
+	r.iter = nil
+	r.hasMore = false
 }
 
 func (r *mapRanger) Range() (key, value reflect.Value, end bool) {
@@ -114,8 +148,11 @@ func (r *mapRanger) Range() (key, value reflect.Value, end bool) {
 
 func (r *mapRanger) ProvidesIndex() bool { return true }
 
+func (r *mapRanger) size() int { return r.len }
+
 type chanRanger struct {
-	v reflect.Value
+	v    reflect.Value
+	done <-chan struct{} // stops a blocked receive when the execution context is done
 }
 
 var (
@@ -125,16 +162,72 @@ var (
 
 func (r *chanRanger) Setup(v reflect.Value) {
 	r.v = v
+	r.done = nil
+}
+
+func (r *chanRanger) Reset() {
+	r.v = reflect.Value{}
+	r.done = nil
 }
 
 func (r *chanRanger) Range() (_, value reflect.Value, end bool) {
-	v, ok := r.v.Recv()
+	v, ok := recvContext(r.v, r.done)
 	value, end = v, !ok
 	return
 }
 
 func (r *chanRanger) ProvidesIndex() bool { return false }
 
+// funcRanger pulls the values of a range-over-func iterator: an iter.Seq, an iter.Seq2, or any function of the
+// form func(yield func(...) bool).
+type funcRanger struct {
+	next          func() (reflect.Value, reflect.Value, bool)
+	providesIndex bool
+}
+
+var _ Ranger = &funcRanger{}
+
+// newFuncRanger returns a ranger over the iterator v, and stop, which ends the iterator when the range exits
+// early. ok is false when v is not an iterator.
+func newFuncRanger(v reflect.Value) (r *funcRanger, stop func(), ok bool) {
+	t := v.Type()
+	switch {
+	case t.CanSeq2():
+		next, stop := iter.Pull2(v.Seq2())
+		return &funcRanger{next: next, providesIndex: true}, stop, true
+	case t.CanSeq():
+		return newSeqRanger(v.Seq())
+	case t.NumIn() == 1 && t.NumOut() == 0 && t.In(0).Kind() == reflect.Func:
+		// func(yield func() bool), which reflect does not turn into a sequence
+		yield := t.In(0)
+		if yield.NumIn() != 0 || yield.NumOut() != 1 || yield.Out(0).Kind() != reflect.Bool {
+			return nil, nil, false
+		}
+		return newSeqRanger(func(yieldValue func(reflect.Value) bool) {
+			v.Call([]reflect.Value{reflect.MakeFunc(yield, func([]reflect.Value) []reflect.Value {
+				return []reflect.Value{reflect.ValueOf(yieldValue(reflect.Value{}))}
+			})})
+		})
+	}
+	return nil, nil, false
+}
+
+func newSeqRanger(seq iter.Seq[reflect.Value]) (r *funcRanger, stop func(), ok bool) {
+	next, stop := iter.Pull(seq)
+	r = &funcRanger{next: func() (reflect.Value, reflect.Value, bool) {
+		value, ok := next()
+		return reflect.Value{}, value, ok
+	}}
+	return r, stop, true
+}
+
+func (r *funcRanger) Range() (index, value reflect.Value, end bool) {
+	index, value, ok := r.next()
+	return index, value, !ok
+}
+
+func (r *funcRanger) ProvidesIndex() bool { return r.providesIndex }
+
 // ranger pooling
 
 var (
@@ -174,6 +267,29 @@ func getRanger(v reflect.Value) (r Ranger, cleanup func(), err error) {
 		return nil, nil, fmt.Errorf("cannot range over nil pointer/interface (%s)", t)
 	}
 
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return newIntsRanger(0, max(v.Int(), 0)), func() {}, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if v.Uint() > math.MaxInt64 {
+			return nil, nil, fmt.Errorf("cannot range over %d (type %s): too large", v.Uint(), t)
+		}
+		return newIntsRanger(0, int64(v.Uint())), func() {}, nil
+	case reflect.Float32, reflect.Float64:
+		// number literals are floats
+		if f := v.Float(); f != math.Trunc(f) || f > math.MaxInt64 {
+			return nil, nil, fmt.Errorf("cannot range over %v (type %s): not an integer", f, t)
+		}
+		return newIntsRanger(0, max(int64(v.Float()), 0)), func() {}, nil
+	case reflect.Func:
+		if v.IsNil() {
+			return nil, nil, fmt.Errorf("cannot range over nil func (%s)", t)
+		}
+		if r, stop, ok := newFuncRanger(v); ok {
+			return r, stop, nil
+		}
+	}
+
 	pool, ok := poolsByKind[v.Kind()]
 	if !ok {
 		return nil, nil, fmt.Errorf("value %v (type %s) is not rangeable", v, t)
@@ -181,5 +297,108 @@ func getRanger(v reflect.Value) (r Ranger, cleanup func(), err error) {
 
 	pr := pool.Get().(pooledRanger)
 	pr.Setup(v)
-	return pr, func() { pool.Put(pr) }, nil
+	return pr, func() {
+		pr.Reset()
+		pool.Put(pr)
+	}, nil
+}
+
+// loopVariable is the name of the implicit variable describing the current iteration in the body of a range.
+const loopVariable = "loop"
+
+// loopIteration is the state of a range loop behind its loop variable, whose fields are computed when they are
+// read. Rangers that don't know their size are read one value ahead, and only when the template reads loop.last.
+type loopIteration struct {
+	ranger Ranger
+	parent *loopIteration // the iteration of the enclosing loop, nil in the outermost one
+	index  int
+	length int // -1 when the ranger doesn't know its size
+
+	peeked               bool // whether the next values were read ahead
+	nextIndex, nextValue reflect.Value
+	nextEnd              bool
+}
+
+var loopIterationType = reflect.TypeOf((*loopIteration)(nil))
+
+func newLoopIteration(ranger Ranger, parent *loopIteration) *loopIteration {
+	it := &loopIteration{ranger: ranger, parent: parent, index: -1, length: -1}
+	if sized, ok := ranger.(sizedRanger); ok {
+		it.length = sized.size()
+	}
+	return it
+}
+
+// next returns the next values of the range, like Ranger.Range.
+func (it *loopIteration) next() (index, value reflect.Value, end bool) {
+	if it.peeked {
+		it.peeked = false
+		index, value, end = it.nextIndex, it.nextValue, it.nextEnd
+		it.nextIndex, it.nextValue = reflect.Value{}, reflect.Value{}
+	} else {
+		index, value, end = it.ranger.Range()
+	}
+	it.index++
+	return
+}
+
+// last reports whether the current value is the last one.
+func (it *loopIteration) last() bool {
+	if it.length >= 0 {
+		return it.index == it.length-1
+	}
+	if !it.peeked {
+		it.nextIndex, it.nextValue, it.nextEnd = it.ranger.Range()
+		it.peeked = true
+	}
+	return it.nextEnd
+}
+
+// loopFields are the fields of the loop variable and their types.
+var loopFields = map[string]reflect.Type{
+	"index":    reflect.TypeOf(0),
+	"index1":   reflect.TypeOf(0),
+	"revindex": reflect.TypeOf(0),
+	"length":   reflect.TypeOf(0),
+	"first":    reflect.TypeOf(false),
+	"last":     reflect.TypeOf(false),
+	"parent":   loopIterationType,
+}
+
+// field returns a field of the loop variable: index and index1 count from 0 and from 1, revindex counts down to 0
+// at the last value, and parent is the loop variable of the enclosing range. length and revindex are missing when
+// the length is unknown, and parent in the outermost range.
+func (it *loopIteration) field(name string, lax bool) (reflect.Value, e.Error) {
+	var v any
+	switch name {
+	case "index":
+		v = it.index
+	case "index1":
+		v = it.index + 1
+	case "first":
+		v = it.index == 0
+	case "last":
+		v = it.last()
+	case "length":
+		if it.length >= 0 {
+			v = it.length
+		}
+	case "revindex":
+		if it.length >= 0 {
+			v = it.length - it.index - 1
+		}
+	case "parent":
+		if it.parent != nil {
+			v = it.parent
+		}
+	}
+	if v == nil {
+		if lax {
+			return reflect.Value{}, nil
+		}
+		return reflect.Value{}, e.New().
+			WithReason(e.NotFoundFieldOrMethodReason).
+			WithMessage(fmt.Sprintf("there is no field '%s' in %s", name, loopVariable))
+	}
+	return reflect.ValueOf(v), nil
 }
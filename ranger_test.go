@@ -0,0 +1,276 @@
+package jet
+
+import (
+	"iter"
+	"math"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLoopVariable(t *testing.T) {
+	tests := []struct {
+		template, want string
+	}{
+		{`{{ range items }}{{ loop.index }}{{ loop.index1 }}{{ loop.revindex }}{{ loop.length }};{{ end }}`, "0123;1213;2303;"},
+		{`{{ range items }}{{ if loop.first }}[{{ end }}{{ . }}{{ if loop.last }}]{{ else }},{{ end }}{{ end }}`, "[a,b,c]"},
+		{`{{ range items }}{{ range 2 }}{{ loop.parent.index }}{{ loop.index }} {{ end }}{{ end }}`, "00 01 10 11 20 21 "},
+		{`{{ range v := ch }}{{ v }}{{ if !loop.last }},{{ end }}{{ end }}`, "1,2"},
+		{`{{ range v := ch }}{{ loop.length ?? "?" }}{{ end }}`, "??"},
+		{`{{ loop := "mine" }}{{ range items }}{{ loop }}{{ end }}`, "mineminemine"},
+	}
+	for _, tt := range tests {
+		ch := make(chan int, 2)
+		ch <- 1
+		ch <- 2
+		close(ch)
+		tmpl, err := NewSet(NewInMemLoader()).parseString(tt.template)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.template, err)
+		}
+		var out strings.Builder
+		if err := tmpl.Execute(&out, VarMap{}.Set("items", []string{"a", "b", "c"}).Set("ch", ch), nil); err != nil {
+			t.Fatalf("%s: %v", tt.template, err)
+		}
+		if out.String() != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.template, out.String(), tt.want)
+		}
+	}
+}
+
+// signalWriter sends everything written to it on a channel.
+type signalWriter chan string
+
+func (w signalWriter) Write(p []byte) (int, error) {
+	w <- string(p)
+	return len(p), nil
+}
+
+func TestLoopVariableDoesNotReadAhead(t *testing.T) {
+	tmpl, err := NewSet(NewInMemLoader()).parseString(`{{ range v := ch }}{{ loop.index }}:{{ v }};{{ end }}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ch := make(chan int)
+	out := make(signalWriter, 16)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if err := tmpl.Execute(out, VarMap{}.Set("ch", ch), nil); err != nil {
+			t.Error(err)
+		}
+	}()
+	ch <- 1
+	var written string
+	for written != "0:1;" {
+		select {
+		case s := <-out:
+			written += s
+		case <-time.After(time.Second):
+			t.Fatalf("got %q before the second value was sent, want %q", written, "0:1;")
+		}
+	}
+	close(ch)
+	wg.Wait()
+}
+
+// countRanger is a custom Ranger producing the values 1 to n.
+type countRanger struct{ i, n int }
+
+func (r *countRanger) Range() (reflect.Value, reflect.Value, bool) {
+	if r.i == r.n {
+		return reflect.Value{}, reflect.Value{}, true
+	}
+	r.i++
+	return reflect.ValueOf(r.i - 1), reflect.ValueOf(r.i), false
+}
+
+func (r *countRanger) ProvidesIndex() bool { return true }
+
+func TestBreakContinue(t *testing.T) {
+	tests := []struct {
+		name, template, want string
+	}{
+		{"slice break", `{{ range ints(0, 5) }}{{ break if . == 3 }}{{ . }}{{ end }}`, "012"},
+		{"slice continue", `{{ range ints(0, 5) }}{{ if . % 2 == 0 }}{{ continue }}{{ end }}{{ . }}{{ end }}`, "13"},
+		{"nested lists", `{{ range ints(0, 5) }}{{ try }}{{ if . == 3 }}{{ break }}{{ end }}{{ end }}{{ . }}{{ end }}`, "012"},
+		{"inner loop", `{{ range ints(0, 3) }}{{ range ints(0, 3) }}{{ break if . == 1 }}{{ . }}{{ end }}{{ end }}`, "000"},
+		{"map break", `{{ n := 0 }}{{ range m }}{{ n = n + 1 }}{{ break }}{{ end }}{{ n }}`, "1"},
+		{"map continue", `{{ n := 0 }}{{ range m }}{{ continue if . == 2 }}{{ n = n + 1 }}{{ end }}{{ n }}`, "2"},
+		{"channel break", `{{ range v := ch }}{{ break if v == 2 }}{{ v }}{{ end }}`, "1"},
+		{"channel continue", `{{ range v := ch }}{{ continue if v == 2 }}{{ v }}{{ end }}`, "13"},
+		{"ranger break", `{{ range i, v := counter }}{{ break if i == 2 }}{{ v }}{{ end }}`, "12"},
+		{"ranger continue", `{{ range i, v := counter }}{{ continue if v == 2 }}{{ v }}{{ end }}`, "134"},
+		{"names", `{{ break := 1 }}{{ continue := 2 }}{{ sum := break + continue }}{{ sum }}`, "3"},
+	}
+	for _, closures := range []bool{false, true} {
+		for _, tt := range tests {
+			var opts []Option
+			if closures {
+				opts = append(opts, WithClosureCompilation())
+			}
+			tmpl, err := NewSet(NewInMemLoader(), opts...).parseString(tt.template)
+			if err != nil {
+				t.Fatalf("%s: %v", tt.name, err)
+			}
+			ch := make(chan int, 3)
+			ch <- 1
+			ch <- 2
+			ch <- 3
+			close(ch)
+			vars := VarMap{}.
+				Set("m", map[string]int{"a": 1, "b": 2, "c": 3}).
+				Set("ch", ch).
+				Set("counter", &countRanger{n: 4})
+			var out strings.Builder
+			if err := tmpl.Execute(&out, vars, nil); err != nil {
+				t.Fatalf("%s: %v", tt.name, err)
+			}
+			if out.String() != tt.want {
+				t.Errorf("closures %v, %s: got %q, want %q", closures, tt.name, out.String(), tt.want)
+			}
+		}
+	}
+}
+
+func TestBreakContinueOutsideRange(t *testing.T) {
+	for _, text := range []string{
+		`{{ break }}`,
+		`{{ continue if x }}`,
+		`{{ range ints(0, 2) }}{{ end }}{{ break }}`,
+		`{{ if x }}{{ continue }}{{ end }}`,
+	} {
+		_, err := NewSet(NewInMemLoader()).parseString(text)
+		if err == nil || !strings.Contains(err.Error(), "outside of range") {
+			t.Errorf("%s: got %v, want a parse error", text, err)
+		}
+	}
+}
+
+func TestPooledRangerResetAfterBreak(t *testing.T) {
+	tmpl, err := NewSet(NewInMemLoader()).parseString(`{{ range items }}{{ break }}{{ end }}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tmpl.Execute(&strings.Builder{}, VarMap{}.Set("items", []int{1, 2, 3}), nil); err != nil {
+		t.Fatal(err)
+	}
+	pool := poolsByKind[reflect.Slice]
+	r := pool.Get().(*sliceRanger)
+	defer pool.Put(r)
+	if r.v.IsValid() || r.i != 0 {
+		t.Errorf("the slice ranger went back to its pool holding %v at %d", r.v, r.i)
+	}
+}
+
+func TestRangeOverFunc(t *testing.T) {
+	var stopped []string
+	// generate yields the values 0 to n-1 and records in stopped whether the loop stopped it early.
+	generate := func(name string, n int, yield func(int) bool) {
+		for i := 0; i < n; i++ {
+			if !yield(i) {
+				stopped = append(stopped, name)
+				return
+			}
+		}
+	}
+	seq := iter.Seq[int](func(yield func(int) bool) { generate("seq", 3, yield) })
+	seq2 := iter.Seq2[string, int](func(yield func(string, int) bool) {
+		generate("seq2", 3, func(i int) bool { return yield(string(rune('a'+i)), i) })
+	})
+	times := func(yield func() bool) { generate("times", 3, func(int) bool { return yield() }) }
+
+	tests := []struct {
+		name, template, want string
+		stopped              bool
+	}{
+		{"iter.Seq", `{{ range seq }}{{ . }}{{ end }}`, "012", false},
+		{"iter.Seq with value", `{{ range v := seq }}{{ v }}{{ end }}`, "012", false},
+		{"iter.Seq2", `{{ range k, v := seq2 }}{{ k }}{{ v }}{{ end }}`, "a0b1c2", false},
+		{"func(yield func() bool)", `{{ range times }}x{{ end }}`, "xxx", false},
+		{"iter.Seq break", `{{ range seq }}{{ break if . == 1 }}{{ . }}{{ end }}`, "0", true},
+		{"iter.Seq2 break", `{{ range k, v := seq2 }}{{ break if v == 1 }}{{ k }}{{ end }}`, "a", true},
+		{"func(yield func() bool) break", `{{ range times }}x{{ break }}{{ end }}`, "x", true},
+	}
+	for _, closures := range []bool{false, true} {
+		var opts []Option
+		if closures {
+			opts = append(opts, WithClosureCompilation())
+		}
+		s := NewSet(NewInMemLoader(), opts...)
+		for _, tt := range tests {
+			tmpl, err := s.parseString(tt.template)
+			if err != nil {
+				t.Fatalf("%s: %v", tt.name, err)
+			}
+			stopped = nil
+			vars := VarMap{}.Set("seq", seq).Set("seq2", seq2).Set("times", times)
+			var out strings.Builder
+			if err := tmpl.Execute(&out, vars, nil); err != nil || out.String() != tt.want {
+				t.Errorf("closures %v, %s: got %q, %v, want %q", closures, tt.name, out.String(), err, tt.want)
+			}
+			if got := len(stopped) == 1; got != tt.stopped {
+				t.Errorf("closures %v, %s: the sequence was stopped early by %q", closures, tt.name, stopped)
+			}
+		}
+
+		// a loop failing stops the sequence too
+		tmpl, err := s.parseString(`{{ range seq }}{{ . }}{{ end }}`)
+		if err != nil {
+			t.Fatal(err)
+		}
+		stopped = nil
+		if err := tmpl.ExecuteLimits(&strings.Builder{}, Limits{MaxIterations: 1}, VarMap{}.Set("seq", seq), nil); err == nil {
+			t.Errorf("closures %v: the iteration limit was not applied", closures)
+		}
+		if len(stopped) != 1 {
+			t.Errorf("closures %v: a failing loop didn't stop the sequence", closures)
+		}
+	}
+}
+
+func TestRangeOverCount(t *testing.T) {
+	tests := []struct {
+		name  string
+		count any
+		want  string
+		err   string
+	}{
+		{"int", 3, "012", ""},
+		{"negative int", -3, "", ""},
+		{"int8", int8(2), "01", ""},
+		{"uint", uint(3), "012", ""},
+		{"uint64 max int", uint64(math.MaxInt64), "0", ""},
+		{"too large uint", uint64(math.MaxUint64), "", "too large"},
+		{"float", 3.0, "012", ""},
+		{"negative float", -2.0, "", ""},
+		{"float32", float32(2), "01", ""},
+		{"fractional float", 2.5, "", "not an integer"},
+		{"NaN", math.NaN(), "", "not an integer"},
+	}
+	for _, closures := range []bool{false, true} {
+		for _, tt := range tests {
+			var opts []Option
+			if closures {
+				opts = append(opts, WithClosureCompilation())
+			}
+			tmpl, err := NewSet(NewInMemLoader(), opts...).parseString(`{{ range n }}{{ . }}{{ break if . == 0 && n > 100 }}{{ end }}`)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var out strings.Builder
+			err = tmpl.Execute(&out, VarMap{}.Set("n", tt.count), nil)
+			switch {
+			case tt.err != "":
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Errorf("closures %v, %s: got %v, want an error containing %q", closures, tt.name, err, tt.err)
+				}
+			case err != nil || out.String() != tt.want:
+				t.Errorf("closures %v, %s: got %q, %v, want %q", closures, tt.name, out.String(), err, tt.want)
+			}
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package jet
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ReloadEvent reports the templates reloaded after their files changed.
+type ReloadEvent struct {
+	Changed  []string         // paths of the template files that changed or were removed
+	Reloaded []string         // templates parsed again: the changed ones and those depending on them
+	Errors   map[string]error // templates that could not be parsed again, by path
+}
+
+// fileTracker records the modification times of the files opened by an OSFileSystemLoader.
+type fileTracker struct {
+	lock     sync.Mutex
+	modTimes map[string]time.Time
+}
+
+func (ft *fileTracker) track(templatePath string, f *os.File) {
+	stat, err := f.Stat()
+	if err != nil {
+		return
+	}
+	ft.lock.Lock()
+	defer ft.lock.Unlock()
+	ft.modTimes[templatePath] = stat.ModTime()
+}
+
+// seed tracks the files of the templates loaded before tracking started, from their current modification time.
+func (ft *fileTracker) seed(dir string, templatePaths []string) {
+	ft.lock.Lock()
+	defer ft.lock.Unlock()
+	for _, templatePath := range templatePaths {
+		if _, ok := ft.modTimes[templatePath]; ok {
+			continue
+		}
+		if stat, err := os.Stat(filepath.Join(dir, filepath.FromSlash(templatePath))); err == nil {
+			ft.modTimes[templatePath] = stat.ModTime()
+		}
+	}
+}
+
+// changed returns the tracked files whose modification time changed or which can't be found anymore, and stops
+// tracking them until they are opened again.
+func (ft *fileTracker) changed(dir string) []string {
+	ft.lock.Lock()
+	defer ft.lock.Unlock()
+	var changed []string
+	for templatePath, modTime := range ft.modTimes {
+		stat, err := os.Stat(filepath.Join(dir, filepath.FromSlash(templatePath)))
+		if err != nil || !stat.ModTime().Equal(modTime) {
+			changed = append(changed, templatePath)
+			delete(ft.modTimes, templatePath)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+// Reload makes s reload the templates it got from l when their files change. Every interval, Reload compares the
+// modification times of the loaded files to the ones they had when they were loaded; for every changed file, it
+// invalidates the file's template together with the templates extending, importing or statically including it (see
+// Set.Invalidate), and parses them again so the cache stays warm. If onReload is not nil, it is called after each
+// reload with the templates reloaded and the parse errors.
+//
+// The templates s already holds in its cache are tracked from the modification times their files have when Reload is
+// called; changes made between loading them and the call are missed. Reload needs a Set using l as its Loader and
+// the cache (not in development mode), and is meant to be called once per Set; it panics if s doesn't use l. The
+// returned function stops the polling.
+func (l *OSFileSystemLoader) Reload(s *Set, interval time.Duration, onReload func(ReloadEvent)) (stop func()) {
+	if loader, ok := s.loader.(*OSFileSystemLoader); !ok || loader != l {
+		panic("jet: OSFileSystemLoader.Reload() must be called with a Set using the loader")
+	}
+	l.lock.Lock()
+	if l.tracker == nil {
+		l.tracker = &fileTracker{modTimes: map[string]time.Time{}}
+	}
+	tracker := l.tracker
+	l.lock.Unlock()
+	tracker.seed(l.dir, s.dependents.names())
+
+	done := make(chan struct{})
+	var once sync.Once
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if event, ok := l.reload(s); ok && onReload != nil {
+					onReload(event)
+				}
+			}
+		}
+	}()
+	return func() {
+		once.Do(func() { close(done) })
+	}
+}
+
+// reload invalidates and parses again the templates of s depending on changed files. It returns false when no file
+// changed.
+func (l *OSFileSystemLoader) reload(s *Set) (ReloadEvent, bool) {
+	changed := l.tracker.changed(l.dir)
+	if len(changed) == 0 {
+		return ReloadEvent{}, false
+	}
+	event := ReloadEvent{Changed: changed}
+	var evicted []string
+	for _, templatePath := range changed {
+		evicted = append(evicted, s.invalidateTemplate(templatePath)...)
+	}
+	sort.Strings(evicted)
+	for _, templatePath := range evicted {
+		if _, err := s.GetTemplate(templatePath); err != nil {
+			if event.Errors == nil {
+				event.Errors = map[string]error{}
+			}
+			event.Errors[templatePath] = err
+			continue
+		}
+		event.Reloaded = append(event.Reloaded, templatePath)
+	}
+	return event, true
+}
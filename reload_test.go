@@ -0,0 +1,62 @@
+package jet
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestReloadTracksCachedTemplates(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, text string, modTime time.Time) {
+		file := filepath.Join(dir, name)
+		if err := os.WriteFile(file, []byte(text), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(file, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	start := time.Now().Add(-time.Hour)
+	write("layout.jet", `[{{ yield body() }}]`, start)
+	write("page.jet", `{{ extends "layout.jet" }}{{ block body() }}page{{ end }}`, start)
+
+	loader := NewOSFileSystemLoader(dir)
+	s := NewSet(loader)
+	// loaded before Reload is called
+	if got := renderTemplate(t, s, "/page.jet"); got != "[page]" {
+		t.Fatalf("got %q, want %q", got, "[page]")
+	}
+
+	events := make(chan ReloadEvent, 1)
+	stop := loader.Reload(s, 5*time.Millisecond, func(event ReloadEvent) { events <- event })
+	defer stop()
+
+	write("layout.jet", `<{{ yield body() }}>`, start.Add(time.Minute))
+	select {
+	case event := <-events:
+		if want := []string{"/layout.jet"}; !reflect.DeepEqual(event.Changed, want) {
+			t.Errorf("changed: got %q, want %q", event.Changed, want)
+		}
+		if len(event.Errors) != 0 {
+			t.Errorf("errors: %v", event.Errors)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the change of a template cached before Reload was not noticed")
+	}
+	if got := renderTemplate(t, s, "/page.jet"); got != "<page>" {
+		t.Errorf("got %q, want %q", got, "<page>")
+	}
+}
+
+func TestReloadPanicsOnAnotherLoader(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Reload with a Set using another loader did not panic")
+		}
+	}()
+	s := NewSet(NewOSFileSystemLoader(t.TempDir()))
+	NewOSFileSystemLoader(t.TempDir()).Reload(s, time.Second, nil)
+}
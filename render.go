@@ -0,0 +1,108 @@
+package jet
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"reflect"
+)
+
+// RenderInput is the input of a render. Context is the value of `.`; Variables and Globals hold the variables of
+// the template and the globals added to those of the Set for this render only, which take precedence over them.
+// Variables and Globals can be a VarMap, a map of any type with string keys, or a struct or a pointer to a struct,
+// whose exported fields and methods become values named after them (see WithJSONTags); nil stands for none.
+//
+//	t.RenderString(jet.RenderInput{Context: order, Variables: map[string]string{"title": "Order"}})
+type RenderInput struct {
+	Context   any
+	Variables any
+	Globals   any
+}
+
+// Render executes the template with in and writes the result to w.
+func (t *Template) Render(w io.Writer, in RenderInput) error {
+	variables, err := inputVarMap("variables", in.Variables, t.set.jsonTags)
+	if err != nil {
+		return err
+	}
+	globals, err := inputVarMap("globals", in.Globals, t.set.jsonTags)
+	if err != nil {
+		return err
+	}
+	var setup func(*Runtime)
+	if globals != nil {
+		setup = func(rt *Runtime) {
+			rt.globals = globals
+		}
+	}
+	return t.execute(w, variables, in.Context, setup)
+}
+
+// RenderBytes executes the template with in and returns the result.
+func (t *Template) RenderBytes(in RenderInput) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := t.Render(&buf, in); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// RenderString executes the template with in and returns the result.
+func (t *Template) RenderString(in RenderInput) (string, error) {
+	var buf bytes.Buffer
+	if err := t.Render(&buf, in); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// RenderTemplate parses template, like ParseContent, and renders it with in.
+func (s *Set) RenderTemplate(template string, in RenderInput) (string, error) {
+	t, err := s.parseString(template)
+	if err != nil {
+		return "", err
+	}
+	return t.RenderString(in)
+}
+
+// Render parses template with the default Set and renders it with in.
+func Render(template string, in RenderInput) (string, error) {
+	return defaultSet.RenderTemplate(template, in)
+}
+
+// inputVarMap converts the variables or globals of a RenderInput into a VarMap.
+func inputVarMap(what string, data any, jsonTags bool) (VarMap, error) {
+	if data == nil {
+		return nil, nil
+	}
+	variables, _, ok := inputVariables(data, jsonTags)
+	if !ok {
+		return nil, fmt.Errorf("jet: %s of type %T are neither a map with string keys nor a struct", what, data)
+	}
+	return variables, nil
+}
+
+// inputVariables turns a VarMap, a map with string keys, or a struct or a pointer to a struct (see structData)
+// into variables, without converting the values. context is the struct, nil for maps. ok is false for other
+// values.
+func inputVariables(data any, jsonTags bool) (variables VarMap, context any, ok bool) {
+	if variables, ok := data.(VarMap); ok {
+		return variables, nil, true
+	}
+	v := reflect.ValueOf(data)
+	if v.Kind() != reflect.Map {
+		return structData(data, jsonTags)
+	}
+	if v.Type().Key().Kind() != reflect.String {
+		return nil, nil, false
+	}
+	variables = make(VarMap, v.Len())
+	for iter := v.MapRange(); iter.Next(); {
+		value := iter.Value()
+		if value.Kind() == reflect.Interface {
+			value = value.Elem()
+		}
+		variables[iter.Key().String()] = value
+	}
+	return variables, nil, true
+}
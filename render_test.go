@@ -0,0 +1,81 @@
+package jet
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	type page struct {
+		Title string
+	}
+	tests := []struct {
+		name, template string
+		in             RenderInput
+		want           string
+	}{
+		{"map[string]string variables", `{{ title }}-{{ lang }}`, RenderInput{Variables: map[string]string{"title": "T", "lang": "en"}}, "T-en"},
+		{"map[string]int variables", `{{ n + 1 }}`, RenderInput{Variables: map[string]int{"n": 1}}, "2"},
+		{"VarMap variables", `{{ title }}`, RenderInput{Variables: VarMap{}.Set("title", "T")}, "T"},
+		{"struct variables", `{{ Title }}`, RenderInput{Variables: page{Title: "T"}}, "T"},
+		{"map[string]string context", `{{ .title }}-{{ .["lang"] }}`, RenderInput{Context: map[string]string{"title": "T", "lang": "en"}}, "T-en"},
+		{"struct context", `{{ .Title }}`, RenderInput{Context: &page{Title: "T"}}, "T"},
+		{"globals", `{{ site }}:{{ title }}`, RenderInput{Variables: map[string]string{"title": "T"}, Globals: map[string]any{"site": "S"}}, "S:T"},
+		{"global function", `{{ twice("a") }}`, RenderInput{Globals: VarMap{}.SetFunc("twice", func(a Arguments) reflect.Value {
+			return reflect.ValueOf(strings.Repeat(a.Get(0).String(), 2))
+		})}, "aa"},
+		{"global overriding the Set's", `{{ version }}`, RenderInput{Globals: map[string]string{"version": "2"}}, "2"},
+		{"Set global", `{{ version }}`, RenderInput{}, "1"},
+	}
+	s := NewSet(NewInMemLoader())
+	s.AddGlobal("version", "1")
+	for _, tt := range tests {
+		tmpl, err := s.parseString(tt.template)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		var w strings.Builder
+		if err := tmpl.Render(&w, tt.in); err != nil || w.String() != tt.want {
+			t.Errorf("%s: Render: got %q, %v, want %q", tt.name, w.String(), err, tt.want)
+		}
+		if got, err := tmpl.RenderString(tt.in); err != nil || got != tt.want {
+			t.Errorf("%s: RenderString: got %q, %v, want %q", tt.name, got, err, tt.want)
+		}
+		if got, err := tmpl.RenderBytes(tt.in); err != nil || string(got) != tt.want {
+			t.Errorf("%s: RenderBytes: got %q, %v, want %q", tt.name, got, err, tt.want)
+		}
+		if got, err := s.RenderTemplate(tt.template, tt.in); err != nil || got != tt.want {
+			t.Errorf("%s: RenderTemplate: got %q, %v, want %q", tt.name, got, err, tt.want)
+		}
+	}
+}
+
+func TestRenderPackageLevel(t *testing.T) {
+	got, err := Render(`{{ greeting }}, {{ .name }}`, RenderInput{
+		Context:   map[string]string{"name": "Ann"},
+		Variables: map[string]string{"greeting": "Hi"},
+	})
+	if err != nil || got != "Hi, Ann" {
+		t.Errorf("got %q, %v, want %q", got, err, "Hi, Ann")
+	}
+}
+
+func TestRenderErrors(t *testing.T) {
+	tmpl, err := NewSet(NewInMemLoader()).parseString(`{{ x }}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, in := range []RenderInput{
+		{Variables: map[int]string{1: "a"}},
+		{Variables: "x"},
+		{Globals: []string{"x"}},
+	} {
+		if _, err := tmpl.RenderString(in); err == nil || !strings.HasPrefix(err.Error(), "jet: ") {
+			t.Errorf("%+v: got %v, want an input error", in, err)
+		}
+	}
+	if got, err := tmpl.RenderBytes(RenderInput{}); err == nil || got != nil {
+		t.Errorf("got %q, %v, want an undefined variable error", got, err)
+	}
+}
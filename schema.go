@@ -0,0 +1,560 @@
+package jet
+
+// SchemaDialect is the JSON Schema dialect of the documents returned by Template.InputSchema.
+const SchemaDialect = "https://json-schema.org/draft/2020-12/schema"
+
+// Schema is a JSON Schema document; it marshals to JSON with encoding/json. An empty Schema accepts any value.
+type Schema struct {
+	Schema     string             `json:"$schema,omitempty"`
+	Type       string             `json:"type,omitempty"`
+	Properties map[string]*Schema `json:"properties,omitempty"`
+	Required   []string           `json:"required,omitempty"`
+	Items      *Schema            `json:"items,omitempty"`
+}
+
+// InputSchema infers from the parsed tree of t the JSON Schema of the data it reads, i.e. of the map passed to
+// ParseMap, whose keys are both the variables and the fields of the context of the template. It follows the
+// templates t extends, the blocks it yields and the includes whose name is a string literal, and describes:
+//
+//   - the variables and the fields of the context the template reads, as properties of an object
+//   - the fields read from them, as nested objects
+//   - the values ranged over, as arrays whose items are described by what the loop reads from its elements
+//   - the values only used as conditions, as booleans, and the values used in numeric comparisons or in `*`, `/`
+//     and `%`, as numbers
+//
+// A property is required when it is read whenever its parent is, i.e. not only in a branch of an if, a ternary
+// expression, a `||` or `&&`, a try, the body of a range over another value, through `?.` or inside isset().
+// Values the template doesn't read are not constrained: the schema doesn't forbid additional properties, and
+// values read through a dynamic index or passed to a dynamic include are left out.
+//
+// InputSchema returns the error of loading an included template, if any.
+func (t *Template) InputSchema() (*Schema, error) {
+	w := &schemaWalker{set: t.set, root: &schemaNode{}, walking: map[*ListNode]bool{}}
+	if err := w.template(t, w.root); err != nil {
+		return nil, err
+	}
+	schema := w.root.schema()
+	schema.Schema = SchemaDialect
+	schema.Type = "object"
+	return schema, nil
+}
+
+// schemaUse is how a value is used by a template.
+type schemaUse int
+
+const (
+	useNone schemaUse = iota
+	useValue
+	useBoolean
+	useNumber
+)
+
+// schemaNode describes a value read by a template while its schema is inferred.
+type schemaNode struct {
+	names      []string // names of the properties, in order of first use
+	properties map[string]*schemaNode
+	required   map[string]bool
+	items      *schemaNode
+	depth      int // conditional depth at which the value is known to be present
+	value      bool
+	boolean    bool
+	number     bool
+}
+
+// property returns the node of the property name, which is required if it is read at depth.
+func (n *schemaNode) property(name string, depth int, optional bool) *schemaNode {
+	if n.properties == nil {
+		n.properties = map[string]*schemaNode{}
+		n.required = map[string]bool{}
+	}
+	child, ok := n.properties[name]
+	if !ok {
+		child = &schemaNode{depth: n.depth}
+		n.properties[name] = child
+		n.names = append(n.names, name)
+	}
+	if !optional && depth <= n.depth {
+		n.required[name] = true
+	}
+	return child
+}
+
+// elements returns the node of the items of the array n, whose fields read at depth are required.
+func (n *schemaNode) elements(depth int) *schemaNode {
+	if n.items == nil {
+		n.items = &schemaNode{depth: depth}
+	}
+	return n.items
+}
+
+func (n *schemaNode) use(use schemaUse) {
+	if n == nil {
+		return
+	}
+	switch use {
+	case useValue:
+		n.value = true
+	case useBoolean:
+		n.boolean = true
+	case useNumber:
+		n.number = true
+	}
+}
+
+func (n *schemaNode) schema() *Schema {
+	s := &Schema{}
+	switch {
+	case n.properties != nil:
+		s.Type = "object"
+		s.Properties = make(map[string]*Schema, len(n.properties))
+		for _, name := range n.names {
+			s.Properties[name] = n.properties[name].schema()
+			if n.required[name] {
+				s.Required = append(s.Required, name)
+			}
+		}
+	case n.items != nil:
+		s.Type = "array"
+		s.Items = n.items.schema()
+	case n.number:
+		s.Type = "number"
+	case n.boolean && !n.value:
+		s.Type = "boolean"
+	}
+	return s
+}
+
+type schemaWalker struct {
+	set     *Set
+	root    *schemaNode
+	blocks  map[string]*BlockNode
+	scopes  []map[string]*schemaNode // variables declared by the template; nil nodes are not inputs
+	context *schemaNode
+	depth   int // number of enclosing conditional branches
+	lenient int // > 0 inside isset and `?.`, where missing values are expected
+	walking map[*ListNode]bool
+}
+
+// template walks t as Execute runs it, with the given context.
+func (w *schemaWalker) template(t *Template, context *schemaNode) error {
+	blocks := w.blocks
+	w.blocks = t.processedBlocks
+	defer func() { w.blocks = blocks }()
+
+	for t.extends != nil {
+		t = t.extends
+	}
+	return w.list(t.Root, context, 0)
+}
+
+// list walks list in a new scope with the given context, entering depth more conditional branches.
+// Lists being walked are skipped, so that recursive blocks and includes end.
+func (w *schemaWalker) list(list *ListNode, context *schemaNode, depth int) error {
+	if list == nil || w.walking[list] {
+		return nil
+	}
+	w.walking[list] = true
+	outer := w.context
+	w.context = context
+	w.depth += depth
+	w.push()
+
+	err := w.nodes(list)
+
+	w.pop()
+	w.depth -= depth
+	w.context = outer
+	delete(w.walking, list)
+	return err
+}
+
+func (w *schemaWalker) push() {
+	w.scopes = append(w.scopes, map[string]*schemaNode{})
+}
+
+func (w *schemaWalker) pop() {
+	w.scopes = w.scopes[:len(w.scopes)-1]
+}
+
+func (w *schemaWalker) declare(node Expression, value *schemaNode) {
+	if ident, ok := node.(*IdentifierNode); ok {
+		w.scopes[len(w.scopes)-1][ident.Ident] = value
+	}
+}
+
+// declareLoop declares the loop variable in the body of a range, unless a variable hides it.
+func (w *schemaWalker) declareLoop() {
+	for _, scope := range w.scopes {
+		if _, ok := scope[loopVariable]; ok {
+			return
+		}
+	}
+	w.scopes[len(w.scopes)-1][loopVariable] = nil
+}
+
+// lookup returns the node of the variable name, reading it from the input if it isn't declared.
+func (w *schemaWalker) lookup(name string) *schemaNode {
+	for i := len(w.scopes) - 1; i >= 0; i-- {
+		if value, ok := w.scopes[i][name]; ok {
+			return value
+		}
+	}
+	if isGlobal(w.set, name) {
+		return nil
+	}
+	return w.root.property(name, w.depth, w.lenient > 0)
+}
+
+func (w *schemaWalker) nodes(list *ListNode) error {
+	for _, node := range list.Nodes {
+		var err error
+		switch node := node.(type) {
+		case *ActionNode:
+			if node.Set != nil {
+				w.setList(node.Set)
+			}
+			if node.Pipe != nil {
+				w.read(node.Pipe, useValue)
+			}
+		case *IfNode:
+			w.push()
+			if node.Set != nil {
+				w.setList(node.Set)
+			}
+			w.read(node.Expression, useBoolean)
+			err = w.list(node.List, w.context, 1)
+			if err == nil {
+				err = w.list(node.ElseList, w.context, 1)
+			}
+			w.pop()
+		case *SwitchNode:
+			err = w.switchNode(node)
+		case *LoopControlNode:
+			if node.Condition != nil {
+				w.read(node.Condition, useBoolean)
+			}
+		case *RangeNode:
+			err = w.rangeNode(node)
+		case *TryNode:
+			err = w.list(node.List, w.context, 1)
+			if err == nil && node.Catch != nil {
+				w.push()
+				if node.Catch.Err != nil {
+					w.declare(node.Catch.Err, nil)
+				}
+				err = w.list(node.Catch.List, w.context, 1)
+				w.pop()
+			}
+		case *YieldNode:
+			err = w.yield(node)
+		case *BlockNode:
+			block, ok := w.blocks[node.Name]
+			if !ok {
+				block = node
+			}
+			if err = w.list(node.Content, w.context, 0); err == nil {
+				err = w.block(block, block.Parameters, block.Expression)
+			}
+		case *IncludeNode:
+			err = w.include(node)
+		case *ReturnNode:
+			w.read(node.Value, useValue)
+		case *TransNode:
+			if node.Key != nil {
+				w.read(node.Key, useValue)
+			}
+			for _, arg := range node.Args {
+				w.read(arg, useValue)
+			}
+			for _, arg := range node.Named {
+				w.read(arg.Value, useValue)
+			}
+			err = w.list(node.List, w.context, 0)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *schemaWalker) setList(set *SetNode) {
+	for i, left := range set.Left {
+		var value *schemaNode
+		if i < len(set.Right) {
+			value = w.read(set.Right[i], useValue)
+		}
+		if _, isIdent := left.(*IdentifierNode); isIdent {
+			if set.Let {
+				w.declare(left, value)
+			}
+			continue
+		}
+		// assignments to fields and indexes read the value they are assigned into
+		w.expr(left)
+	}
+}
+
+func (w *schemaWalker) switchNode(node *SwitchNode) error {
+	use := useBoolean
+	if node.Expression != nil {
+		w.read(node.Expression, useValue)
+		use = useValue
+	}
+	// only the first value is always evaluated, the next ones when the previous ones don't match
+	evaluated := 0
+	for _, caseNode := range node.Cases {
+		for _, value := range caseNode.Values {
+			w.read(value, use)
+			if evaluated++; evaluated == 1 {
+				w.depth++
+			}
+		}
+	}
+	if evaluated > 0 {
+		w.depth--
+	}
+	for _, caseNode := range node.Cases {
+		if err := w.list(caseNode.List, w.context, 1); err != nil {
+			return err
+		}
+	}
+	return w.list(node.Default, w.context, 1)
+}
+
+func (w *schemaWalker) rangeNode(node *RangeNode) error {
+	var target Expression = node.Expression
+	if node.Set != nil {
+		target = node.Set.Right[0]
+	}
+	var element *schemaNode
+	if collection := w.expr(target); collection != nil {
+		element = collection.elements(w.depth + 1)
+	}
+
+	w.push()
+	defer w.pop()
+	w.declareLoop()
+	context := w.context
+	if node.Set == nil {
+		context = element
+	} else if left := node.Set.Left; len(left) > 1 {
+		w.declare(left[0], nil)
+		w.declare(left[1], element)
+	} else {
+		// a single variable gets the index, the element becomes the context
+		w.declare(left[0], nil)
+		context = element
+	}
+	if err := w.list(node.List, context, 1); err != nil {
+		return err
+	}
+	return w.list(node.ElseList, w.context, 1)
+}
+
+func (w *schemaWalker) yield(node *YieldNode) error {
+	if node.IsContent {
+		if node.Expression != nil {
+			w.read(node.Expression, useValue)
+		}
+		return nil
+	}
+	block, ok := w.blocks[node.Name]
+	if !ok || block == nil {
+		return nil
+	}
+	if err := w.list(node.Content, w.context, 0); err != nil {
+		return err
+	}
+	return w.block(block, node.Parameters, node.Expression)
+}
+
+// block walks the body of block as executeYieldBlock runs it, with the parameters passed in params.
+func (w *schemaWalker) block(block *BlockNode, params *BlockParameterList, expression Expression) error {
+	context := w.context
+	if expression != nil {
+		context = w.read(expression, useNone)
+	}
+	scope := map[string]*schemaNode{}
+	for _, p := range params.List {
+		if p.Expression != nil {
+			scope[p.Identifier] = w.read(p.Expression, useValue)
+		}
+	}
+	for _, p := range block.Parameters.List {
+		if _, ok := scope[p.Identifier]; !ok {
+			scope[p.Identifier] = w.read(p.Expression, useValue)
+		}
+	}
+	w.scopes = append(w.scopes, scope)
+	defer w.pop()
+	return w.list(block.List, context, 0)
+}
+
+func (w *schemaWalker) include(node *IncludeNode) error {
+	w.read(node.Name, useValue)
+	context := w.context
+	if node.Context != nil {
+		context = w.read(node.Context, useNone)
+	}
+	name, ok := node.Name.(*StringNode)
+	if !ok {
+		return nil
+	}
+	t, err := w.set.getSiblingTemplate(name.Text, node.TemplatePath, true)
+	if err != nil {
+		return err
+	}
+	return w.template(t, context)
+}
+
+// read returns the node of the value of an expression, recording how it is used.
+func (w *schemaWalker) read(node Expression, use schemaUse) *schemaNode {
+	if node == nil {
+		return nil
+	}
+	value := w.expr(node)
+	value.use(use)
+	return value
+}
+
+// expr returns the node of the value of an expression, or nil if it isn't read from the input.
+func (w *schemaWalker) expr(node Expression) *schemaNode {
+	switch node := node.(type) {
+	case *IdentifierNode:
+		if node.Ident == "." {
+			return w.context
+		}
+		return w.lookup(node.Ident)
+	case *FieldNode:
+		return w.fields(w.context, node.Idents)
+	case *ChainNode:
+		return w.fields(w.expr(node.Node), node.Field)
+	case *PipeNode:
+		value := w.command(node.Cmds[0], false)
+		for _, cmd := range node.Cmds[1:] {
+			value = w.command(cmd, true)
+		}
+		return value
+	case *CommandNode:
+		return w.command(node, false)
+	case *CallExprNode:
+		return w.call(node.BaseExpr, node.CallArgs)
+	case *IndexExprNode:
+		base := w.expr(node.Base)
+		if node.Nullable {
+			w.lenient++
+			defer func() { w.lenient-- }()
+		}
+		switch index := node.Index.(type) {
+		case *StringNode:
+			if base != nil {
+				return base.property(index.Text, w.depth, w.lenient > 0)
+			}
+		case *NumberNode:
+			if base != nil {
+				return base.elements(w.depth)
+			}
+		default:
+			w.read(node.Index, useValue)
+		}
+		return nil
+	case *SliceExprNode:
+		w.read(node.Index, useNumber)
+		w.read(node.EndIndex, useNumber)
+		base := w.expr(node.Base)
+		if base != nil {
+			base.elements(w.depth)
+		}
+		return base
+	case *AdditiveExprNode:
+		if node.Left == nil {
+			w.read(node.Right, useNumber)
+			return nil
+		}
+		w.read(node.Left, useValue)
+		w.read(node.Right, useValue)
+	case *MultiplicativeExprNode:
+		w.read(node.Left, useNumber)
+		w.read(node.Right, useNumber)
+	case *ComparativeExprNode:
+		w.read(node.Left, useValue)
+		w.read(node.Right, useValue)
+	case *NumericComparativeExprNode:
+		w.read(node.Left, useNumber)
+		w.read(node.Right, useNumber)
+	case *LogicalExprNode:
+		w.read(node.Left, useBoolean)
+		w.depth++
+		w.read(node.Right, useBoolean)
+		w.depth--
+	case *CoalesceExprNode:
+		// the left side may be missing, and the right side is only read when it is
+		w.lenient++
+		w.read(node.Left, useValue)
+		w.lenient--
+		w.depth++
+		w.read(node.Right, useValue)
+		w.depth--
+	case *NotExprNode:
+		w.read(node.Expr, useBoolean)
+	case *TernaryExprNode:
+		w.read(node.Boolean, useBoolean)
+		w.depth++
+		w.read(node.Left, useValue)
+		w.read(node.Right, useValue)
+		w.depth--
+	}
+	return nil
+}
+
+// fields returns the node of the fields idents of value.
+func (w *schemaWalker) fields(value *schemaNode, idents Idents) *schemaNode {
+	lenient := w.lenient
+	for _, ident := range idents {
+		if value == nil {
+			break
+		}
+		if ident.lax {
+			lenient++
+		}
+		value = value.property(ident.name, w.depth, lenient > 0)
+	}
+	return value
+}
+
+func (w *schemaWalker) command(node *CommandNode, isPiped bool) *schemaNode {
+	if node.Exprs == nil && !isPiped {
+		return w.expr(node.BaseExpr)
+	}
+	return w.call(node.BaseExpr, node.CallArgs)
+}
+
+// call walks a call of base; the result of a call isn't read from the input.
+func (w *schemaWalker) call(base Expression, args CallArgs) *schemaNode {
+	use := useValue
+	if ident, ok := base.(*IdentifierNode); ok && ident.Ident == "isset" && w.isBuiltin(ident.Ident) {
+		use = useNone
+		w.lenient++
+		defer func() { w.lenient-- }()
+	}
+	if _, ok := base.(*IdentifierNode); !ok {
+		w.expr(base)
+	}
+	for _, arg := range args.Exprs {
+		if arg.Type() != NodeUnderscore {
+			w.read(arg, use)
+		}
+	}
+	return nil
+}
+
+// isBuiltin tells whether name refers to a global or default variable rather than to a variable of the template.
+func (w *schemaWalker) isBuiltin(name string) bool {
+	for i := len(w.scopes) - 1; i >= 0; i-- {
+		if _, ok := w.scopes[i][name]; ok {
+			return false
+		}
+	}
+	return isGlobal(w.set, name)
+}
@@ -0,0 +1,63 @@
+package jet
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestInputSchema(t *testing.T) {
+	tests := []struct {
+		name, template, want string
+	}{
+		{"variables and context", `{{ name }}{{ .title }}`,
+			`{"properties":{"name":{},"title":{}},"required":["name","title"]}`},
+		{"nested fields", `{{ user.address.city }}{{ if user.admin }}{{ user.email }}{{ end }}`,
+			`{"properties":{"user":{"type":"object","properties":{"address":{"type":"object","properties":{"city":{}},"required":["city"]},"admin":{"type":"boolean"},"email":{}},"required":["address","admin"]}},"required":["user"]}`},
+		{"range context", `{{ range items }}{{ .price * 2 }}{{ end }}`,
+			`{"properties":{"items":{"type":"array","items":{"type":"object","properties":{"price":{"type":"number"}},"required":["price"]}}},"required":["items"]}`},
+		{"range variable", `{{ range i, it := items }}{{ i }}{{ it.name }}{{ end }}`,
+			`{"properties":{"items":{"type":"array","items":{"type":"object","properties":{"name":{}},"required":["name"]}}},"required":["items"]}`},
+		{"booleans and numbers", `{{ if active }}x{{ end }}{{ count > 1 }}`,
+			`{"properties":{"active":{"type":"boolean"},"count":{"type":"number"}},"required":["active","count"]}`},
+		{"optional", `{{ isset(x.y) }}{{ a?.b }}{{ try }}{{ c }}{{ end }}{{ d ? e : f }}`,
+			`{"properties":{"a":{"type":"object","properties":{"b":{}}},"c":{},"d":{"type":"boolean"},"e":{},"f":{},"x":{"type":"object","properties":{"y":{}}}},"required":["a","d"]}`},
+		{"locals and builtins", `{{ x := 1 }}{{ x }}{{ len(items) }}`,
+			`{"properties":{"items":{}},"required":["items"]}`},
+		{"include", `{{ include "/partial.jet" user }}`,
+			`{"properties":{"user":{"type":"object","properties":{"name":{}},"required":["name"]}},"required":["user"]}`},
+		{"yield", `{{ block card(u=user) }}{{ u.name }}{{ end }}`,
+			`{"properties":{"user":{"type":"object","properties":{"name":{}},"required":["name"]}},"required":["user"]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loader := NewInMemLoader()
+			loader.Set("/partial.jet", `{{ .name }}`)
+			tmpl, err := NewSet(loader).parseString(tt.template)
+			if err != nil {
+				t.Fatal(err)
+			}
+			schema, err := tmpl.InputSchema()
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := json.Marshal(schema)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := `{"$schema":"` + SchemaDialect + `","type":"object",` + tt.want[1:]
+			if string(got) != want {
+				t.Errorf("got  %s\nwant %s", got, want)
+			}
+		})
+	}
+}
+
+func TestInputSchemaIncludeError(t *testing.T) {
+	tmpl, err := NewSet(NewInMemLoader()).parseString(`{{ include "/missing.jet" }}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tmpl.InputSchema(); err == nil {
+		t.Error("expected the error of loading /missing.jet")
+	}
+}
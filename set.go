@@ -7,7 +7,6 @@ import (
 	"path"
 	"path/filepath"
 	"reflect"
-	"regexp"
 	"sync"
 	"text/template"
 
@@ -17,16 +16,28 @@ import (
 // Set is responsible to load, parse and cache templates.
 // Every Jet template is associated with a Set.
 type Set struct {
-	loader            Loader
-	cache             Cache
-	escapee           SafeWriter    // escapee to use at runtime
-	globals           VarMap        // global scope for this template set
-	gmx               *sync.RWMutex // global variables map mutex
-	extensions        []string
-	developmentMode   bool
-	leftDelim         string
-	rightDelim        string
-	placeholderParser *regexp.Regexp
+	loader          Loader
+	cache           Cache
+	escapee         SafeWriter    // escapee to use at runtime
+	globals         VarMap        // global scope for this template set
+	filters         VarMap        // filters registered with RegisterFilter, guarded by gmx
+	gmx             *sync.RWMutex // global variables map mutex
+	extensions      []string
+	developmentMode bool
+	leftDelim       string
+	rightDelim      string
+	bindStyle       BindStyle
+	translator      Translator
+	locale          string
+	missingKey      MissingKey
+	limits          Limits
+	parseCacheSize  int
+	parseCache      *parseCache // templates parsed from strings, nil when disabled
+	dependents      dependents  // reverse dependencies of cached templates, for Invalidate
+	revalidate      bool
+	closures        bool // compile templates to closures, see WithClosureCompilation
+	jsonTags        bool // look struct fields up by the names in their json tags, see WithJSONTags
+	goFieldNames    bool // WithoutJSONTags was given: ParseMap keeps Go names too
 }
 
 // Option is the type of option functions that can be used in NewSet().
@@ -39,25 +50,26 @@ func NewSet(loader Loader, opts ...Option) *Set {
 	}
 
 	s := &Set{
-		loader:     loader,
-		cache:      &cache{},
-		escapee:    template.HTMLEscape,
-		globals:    VarMap{},
-		gmx:        &sync.RWMutex{},
-		extensions: defaultExtensions,
+		loader:         loader,
+		cache:          &cache{},
+		escapee:        template.HTMLEscape,
+		globals:        VarMap{},
+		filters:        VarMap{},
+		gmx:            &sync.RWMutex{},
+		extensions:     defaultExtensions,
+		parseCacheSize: DefaultParseCacheSize,
 	}
 
 	for _, opt := range opts {
 		opt(s)
 	}
+	s.parseCache = newParseCache(s.parseCacheSize)
 	if s.leftDelim == "" {
 		s.leftDelim = DefaultLeftDelim
 	}
 	if s.rightDelim == "" {
 		s.rightDelim = DefaultRightDelim
 	}
-	pattern := fmt.Sprintf(`\%s([^}]+)\%s`, s.leftDelim, s.rightDelim)
-	s.placeholderParser = regexp.MustCompile(pattern)
 	return s
 }
 
@@ -70,6 +82,9 @@ func WithCache(c Cache) Option {
 	}
 	return func(s *Set) {
 		s.cache = c
+		if dc, ok := c.(*DiskCache); ok {
+			dc.set = s
+		}
 	}
 }
 
@@ -103,6 +118,30 @@ func WithTemplateNameExtensions(extensions []string) Option {
 	}
 }
 
+// WithJSONTags returns an option function that makes templates look up the fields of structs by the names
+// encoding/json gives them, and by those only: `{{ first_name }}` and `{{ .user.first_name }}` read a field
+// FirstName tagged json:"first_name", which `{{ FirstName }}` doesn't find anymore, and fields tagged json:"-" can't
+// be read. Methods keep their Go name.
+func WithJSONTags() Option {
+	return func(s *Set) {
+		s.jsonTags, s.goFieldNames = true, false
+	}
+}
+
+// WithoutJSONTags returns an option function that makes templates look up the fields of structs by their Go name,
+// undoing WithJSONTags. It also applies to ParseMap and ParseMapSQL, which otherwise use the json names whatever the
+// Set.
+func WithoutJSONTags() Option {
+	return func(s *Set) {
+		s.jsonTags, s.goFieldNames = false, true
+	}
+}
+
+// dataJSONTags reports whether ParseMap and ParseMapSQL look struct fields up by their json names.
+func (s *Set) dataJSONTags() bool {
+	return !s.goFieldNames
+}
+
 // InDevelopmentMode returns an option function that toggles development mode on, meaning the cache will
 // always be bypassed and every template lookup will go to the loader.
 func InDevelopmentMode() Option {
@@ -144,13 +183,17 @@ func (s *Set) getTemplate(templatePath string, cacheAfterParsing bool) (t *Templ
 	if !s.developmentMode {
 		t, found := s.getTemplateFromCache(templatePath)
 		if found {
-			return t, nil
+			if !s.revalidate || s.fresh(t) {
+				return t, nil
+			}
+			s.Invalidate(templatePath)
 		}
 	}
 
 	t, err = s.getTemplateFromLoader(templatePath, cacheAfterParsing)
 	if err == nil && cacheAfterParsing && !s.developmentMode {
 		s.cache.Put(templatePath, t)
+		s.dependents.add(templatePath, t)
 	}
 	return t, err
 }
@@ -178,6 +221,13 @@ func (s *Set) getTemplateFromLoader(templatePath string, cacheAfterParsing bool)
 }
 
 func (s *Set) loadFromFile(templatePath string, cacheAfterParsing bool) (template *Template, err error) {
+	// stat before reading, so that a concurrent change leaves the template with an outdated version
+	var version string
+	if loader, ok := s.loader.(VersionedLoader); ok {
+		if version, _, err = loader.Stat(templatePath); err != nil {
+			return nil, err
+		}
+	}
 	f, err := s.loader.Open(templatePath)
 	if err != nil {
 		return nil, err
@@ -187,7 +237,12 @@ func (s *Set) loadFromFile(templatePath string, cacheAfterParsing bool) (templat
 	if err != nil {
 		return nil, err
 	}
-	return s.parse(templatePath, lib.FromByte(content), cacheAfterParsing)
+	t, parseErr := s.parse(templatePath, lib.FromByte(content), cacheAfterParsing)
+	if parseErr != nil {
+		return nil, parseErr
+	}
+	t.version = version
+	return t, nil
 }
 
 // Parse parses `contents` as if it were located at `templatePath`, but won't put the result into the cache.
@@ -206,11 +261,13 @@ func (s *Set) Parse(templatePath, contents string) (template *Template, err erro
 }
 
 func (s *Set) ParseContent(contents string) (template *Template, err error) {
-	return s.parse("", contents, true)
+	return s.parseString(contents)
 }
 
+// ParseTemplate parses template, like ParseContent, and executes it with data as ParseMap does; see RenderTemplate
+// to set the context and the variables explicitly.
 func (s *Set) ParseTemplate(template string, data any, asMap ...bool) (result string, err error) {
-	tmpl, err := s.parse("", template, true)
+	tmpl, err := s.parseString(template)
 	if err != nil {
 		return
 	}
@@ -224,26 +281,45 @@ type Tmpl struct {
 
 func NewTemplate(template string) (tmpl *Tmpl, err error) {
 	var t *Template
-	t, err = defaultSet.parse("", template, true)
+	t, err = defaultSet.parseString(template)
 	tmpl = &Tmpl{}
 	tmpl.Template = t
 	return
 }
 
+// Parse executes the template with data as ParseMap does.
 func (tmpl *Tmpl) Parse(data any, asMap ...bool) (result string, err error) {
 	return tmpl.Template.ParseMap(data, asMap...)
 }
 
+// Placeholders parses template with the default Set and returns its placeholders as strings, context fields
+// prefixed with a dot; it returns nil if the template does not parse. See ParsePlaceholders for the details of
+// each placeholder.
 func Placeholders(template string) []string {
-	tmpl, err := defaultSet.parse("", template, true)
+	placeholders, err := ParsePlaceholders(template)
 	if err != nil {
 		return nil
 	}
-	return tmpl.placeholders
+	names := make([]string, len(placeholders))
+	for i, p := range placeholders {
+		names[i] = p.String()
+	}
+	return names
+}
+
+// ParsePlaceholders parses template with the default Set and returns its placeholders.
+func ParsePlaceholders(template string) ([]Placeholder, error) {
+	tmpl, err := defaultSet.parseString(template)
+	if err != nil {
+		return nil, err
+	}
+	return tmpl.Placeholders(), nil
 }
 
+// Parse parses template with the default Set and executes it with data as ParseMap does; see Render to set the
+// context and the variables explicitly.
 func Parse(template string, data any, asMap ...bool) (result string, err error) {
-	tmpl, err := defaultSet.parse("", template, true)
+	tmpl, err := defaultSet.parseString(template)
 	if err != nil {
 		return
 	}
@@ -0,0 +1,540 @@
+package jet
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/oarkflow/jet/utils/e"
+)
+
+// pureBuiltins lists the default variables whose result only depends on their arguments.
+var pureBuiltins = map[string]bool{
+	"lower": true, "upper": true, "hasPrefix": true, "hasSuffix": true, "repeat": true, "replace": true,
+	"split": true, "trimSpace": true, "html": true, "string": true, "sprint": true, "url": true,
+	"safeHtml": true, "safeJs": true, "raw": true, "unsafe": true, "writeJson": true, "json": true,
+	"map": true, "slice": true, "array": true, "isset": true, "len": true, "ints": true,
+}
+
+// Specialize returns a copy of t evaluated ahead of time against vars: actions that only depend on vars, constants
+// and side-effect free builtins are replaced by their output, if conditions and switches that only depend on them
+// are resolved to the branch taken, and such sub-expressions of the remaining actions are replaced by literals. Everything else
+// is kept, so executing the result with the remaining variables and context renders the same as executing t with
+// vars added to them.
+//
+// Names that are declared or assigned anywhere in the template are never specialized, and neither are block bodies
+// or the templates t extends: the vars they read are still placeholders of the result and must still be passed.
+// Values folded into text are not bound by ExecuteSQL.
+func (t *Template) Specialize(vars VarMap) (*Template, error) {
+	sp := &specializer{t: t, vars: make(VarMap, len(vars)), dynamic: map[string]bool{}}
+	for name, value := range vars {
+		sp.vars[name] = value
+	}
+	sp.leftDelims = DefaultLeftDelim[:1]
+	if t.set != nil && t.set.leftDelim != "" {
+		sp.leftDelims += t.set.leftDelim[:1]
+	}
+	sp.declarations(t.Root)
+
+	specialized := *t
+	specialized.Root = sp.list(t.Root)
+	specialized.placeholders = specialized.findPlaceholders()
+	if t.set != nil && t.set.closures {
+		if err := specialized.compileClosures(); err != nil {
+			return nil, err
+		}
+	}
+	return &specialized, nil
+}
+
+type specializer struct {
+	t          *Template
+	vars       VarMap
+	dynamic    map[string]bool // names declared or assigned by the template
+	leftDelims string          // folded text must not contain these, so String() stays valid source
+}
+
+// declarations marks every name the template declares or assigns as dynamic.
+func (sp *specializer) declarations(node Node) {
+	declare := func(set *SetNode) {
+		if set == nil {
+			return
+		}
+		for _, left := range set.Left {
+			if ident, ok := left.(*IdentifierNode); ok {
+				sp.dynamic[ident.Ident] = true
+			}
+		}
+	}
+	params := func(params *BlockParameterList) {
+		if params == nil {
+			return
+		}
+		for _, param := range params.List {
+			sp.dynamic[param.Identifier] = true
+		}
+	}
+
+	switch node := node.(type) {
+	case *ListNode:
+		if node == nil {
+			return
+		}
+		for _, n := range node.Nodes {
+			sp.declarations(n)
+		}
+	case *ActionNode:
+		declare(node.Set)
+	case *IfNode:
+		declare(node.Set)
+		sp.declarations(node.List)
+		sp.declarations(node.ElseList)
+	case *RangeNode:
+		declare(node.Set)
+		if _, ok := sp.vars[loopVariable]; !ok {
+			// the loop variable, unless the variable hides it
+			sp.dynamic[loopVariable] = true
+		}
+		sp.declarations(node.List)
+		sp.declarations(node.ElseList)
+	case *BlockNode:
+		params(node.Parameters)
+		sp.declarations(node.List)
+		sp.declarations(node.Content)
+	case *YieldNode:
+		params(node.Parameters)
+		sp.declarations(node.Content)
+	case *TryNode:
+		sp.declarations(node.List)
+		if node.Catch != nil {
+			if node.Catch.Err != nil {
+				sp.dynamic[node.Catch.Err.Ident] = true
+			}
+			sp.declarations(node.Catch.List)
+		}
+	case *TransNode:
+		sp.declarations(node.List)
+	case *SwitchNode:
+		for _, c := range node.Cases {
+			sp.declarations(c.List)
+		}
+		sp.declarations(node.Default)
+	}
+}
+
+func (sp *specializer) known(name string) bool {
+	if sp.dynamic[name] {
+		return false
+	}
+	if _, ok := sp.vars[name]; ok {
+		return true
+	}
+	if !pureBuiltins[name] {
+		return false
+	}
+	if sp.t.set == nil {
+		return true
+	}
+	sp.t.set.gmx.RLock()
+	defer sp.t.set.gmx.RUnlock()
+	_, shadowed := sp.t.set.globals[name]
+	return !shadowed
+}
+
+// static reports whether node only depends on the known variables, constants and pure builtins.
+func (sp *specializer) static(node Node) bool {
+	switch node := node.(type) {
+	case nil:
+		return true
+	case *StringNode, *NumberNode, *BoolNode, *NilNode, *UnderscoreNode:
+		return true
+	case *IdentifierNode:
+		return sp.known(node.Ident)
+	case *ChainNode:
+		return sp.static(node.Node)
+	case *IndexExprNode:
+		return sp.static(node.Base) && sp.static(node.Index)
+	case *SliceExprNode:
+		return sp.static(node.Base) && sp.static(node.Index) && sp.static(node.EndIndex)
+	case *AdditiveExprNode:
+		return sp.static(node.Left) && sp.static(node.Right)
+	case *MultiplicativeExprNode:
+		return sp.static(node.Left) && sp.static(node.Right)
+	case *ComparativeExprNode:
+		return sp.static(node.Left) && sp.static(node.Right)
+	case *NumericComparativeExprNode:
+		return sp.static(node.Left) && sp.static(node.Right)
+	case *LogicalExprNode:
+		return sp.static(node.Left) && sp.static(node.Right)
+	case *CoalesceExprNode:
+		return sp.static(node.Left) && sp.static(node.Right)
+	case *NotExprNode:
+		return sp.static(node.Expr)
+	case *TernaryExprNode:
+		return sp.static(node.Boolean) && sp.static(node.Left) && sp.static(node.Right)
+	case *CallExprNode:
+		return sp.staticCall(&node.CallArgs, node.BaseExpr)
+	case *CommandNode:
+		if node.filter.IsValid() {
+			// filters can be impure
+			return false
+		}
+		return sp.staticCall(&node.CallArgs, node.BaseExpr)
+	case *PipeNode:
+		for _, cmd := range node.Cmds {
+			if !sp.static(cmd) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+func (sp *specializer) staticCall(args *CallArgs, base Expression) bool {
+	if !sp.static(base) {
+		return false
+	}
+	for _, arg := range args.Exprs {
+		if !sp.static(arg) {
+			return false
+		}
+	}
+	return true
+}
+
+// run executes fn with a Runtime holding only the known variables; it fails if fn reads a missing value.
+func (sp *specializer) run(w io.Writer, fn func(rt *Runtime) e.Error) (err error) {
+	rt := pool_State.Get().(*Runtime)
+	defer rt.recover(&err)
+
+	rt.blocks = sp.t.processedBlocks
+	rt.variables = sp.vars
+	rt.set = sp.t.set
+	rt.jsonTags = sp.t.set.jsonTags
+	rt.Writer = w
+	if err := fn(rt); err != nil {
+		return err
+	}
+	if rt.missing {
+		return e.New().WithReason(e.InvalidValueReason).WithMessage("missing value")
+	}
+	return nil
+}
+
+func (sp *specializer) eval(expr Expression) (v reflect.Value, ok bool) {
+	err := sp.run(io.Discard, func(rt *Runtime) (err e.Error) {
+		v, err = rt.evalPrimaryExpressionGroup(expr)
+		return err
+	})
+	return v, err == nil
+}
+
+// render returns the output of a static action, or false if it can't be folded into text.
+func (sp *specializer) render(node *ActionNode) (string, bool) {
+	var buf bytes.Buffer
+	list := &ListNode{NodeBase: NodeBase{NodeType: NodeList}, Nodes: []Node{node}}
+	err := sp.run(&buf, func(rt *Runtime) e.Error {
+		_, err := rt.executeList(list)
+		return err
+	})
+	if err != nil || strings.ContainsAny(buf.String(), sp.leftDelims) {
+		return "", false
+	}
+	return buf.String(), true
+}
+
+// literal returns a constant node for v, or nil if v's type has no literal that evaluates to the same type.
+func (sp *specializer) literal(node Node, v reflect.Value) Expression {
+	if !v.IsValid() {
+		return nil
+	}
+	switch v.Type() {
+	case reflect.TypeOf(""):
+		return sp.t.newString(node.Position(), strconv.Quote(v.String()), v.String())
+	case reflect.TypeOf(false):
+		return sp.t.newBool(node.Position(), v.Bool())
+	case reflect.TypeOf(float64(0)):
+		f := v.Float()
+		if math.IsInf(f, 0) || math.IsNaN(f) {
+			return nil
+		}
+		number, err := sp.t.newNumber(node.Position(), strconv.FormatFloat(f, 'g', -1, 64), itemNumber)
+		if err != nil {
+			return nil
+		}
+		return number
+	}
+	return nil
+}
+
+// fold replaces the static sub-expressions of expr by literals, copying the nodes it changes.
+func (sp *specializer) fold(expr Expression) Expression {
+	switch expr.(type) {
+	case nil, *StringNode, *NumberNode, *BoolNode, *NilNode, *UnderscoreNode:
+		return expr
+	}
+	_, isCommand := expr.(*CommandNode)
+	_, isPipe := expr.(*PipeNode)
+	if !isCommand && !isPipe && sp.static(expr) {
+		if v, ok := sp.eval(expr); ok {
+			if literal := sp.literal(expr, v); literal != nil {
+				return literal
+			}
+		}
+		return expr
+	}
+
+	switch node := expr.(type) {
+	case *ChainNode:
+		c := *node
+		c.Node = sp.fold(node.Node)
+		return &c
+	case *IndexExprNode:
+		c := *node
+		c.Base, c.Index = sp.fold(node.Base), sp.fold(node.Index)
+		return &c
+	case *SliceExprNode:
+		c := *node
+		c.Base, c.Index, c.EndIndex = sp.fold(node.Base), sp.fold(node.Index), sp.fold(node.EndIndex)
+		return &c
+	case *AdditiveExprNode:
+		c := *node
+		c.Left, c.Right = sp.fold(node.Left), sp.fold(node.Right)
+		return &c
+	case *MultiplicativeExprNode:
+		c := *node
+		c.Left, c.Right = sp.fold(node.Left), sp.fold(node.Right)
+		return &c
+	case *ComparativeExprNode:
+		c := *node
+		c.Left, c.Right = sp.fold(node.Left), sp.fold(node.Right)
+		return &c
+	case *NumericComparativeExprNode:
+		c := *node
+		c.Left, c.Right = sp.fold(node.Left), sp.fold(node.Right)
+		return &c
+	case *LogicalExprNode:
+		c := *node
+		c.Left, c.Right = sp.fold(node.Left), sp.fold(node.Right)
+		return &c
+	case *CoalesceExprNode:
+		c := *node
+		c.Left, c.Right = sp.fold(node.Left), sp.fold(node.Right)
+		return &c
+	case *NotExprNode:
+		c := *node
+		c.Expr = sp.fold(node.Expr)
+		return &c
+	case *TernaryExprNode:
+		if sp.static(node.Boolean) {
+			if v, ok := sp.eval(node.Boolean); ok {
+				if isTrue(v) {
+					return sp.fold(node.Left)
+				}
+				return sp.fold(node.Right)
+			}
+		}
+		c := *node
+		c.Boolean, c.Left, c.Right = sp.fold(node.Boolean), sp.fold(node.Left), sp.fold(node.Right)
+		return &c
+	case *CallExprNode:
+		c := *node
+		c.Exprs = sp.foldAll(node.Exprs)
+		return &c
+	case *CommandNode:
+		c := *node
+		if !node.filter.IsValid() {
+			c.BaseExpr = sp.fold(node.BaseExpr)
+		}
+		c.Exprs = sp.foldAll(node.Exprs)
+		return &c
+	case *PipeNode:
+		c := *node
+		c.Cmds = make([]*CommandNode, len(node.Cmds))
+		for i, cmd := range node.Cmds {
+			c.Cmds[i] = sp.fold(cmd).(*CommandNode)
+		}
+		return &c
+	}
+	return expr
+}
+
+func (sp *specializer) foldAll(exprs []Expression) []Expression {
+	if exprs == nil {
+		return nil
+	}
+	folded := make([]Expression, len(exprs))
+	for i, expr := range exprs {
+		folded[i] = sp.fold(expr)
+	}
+	return folded
+}
+
+func (sp *specializer) foldSet(set *SetNode) *SetNode {
+	if set == nil || set.IndexExprGetLookup {
+		return set
+	}
+	c := *set
+	c.Right = sp.foldAll(set.Right)
+	return &c
+}
+
+func (sp *specializer) list(list *ListNode) *ListNode {
+	if list == nil {
+		return nil
+	}
+	specialized := sp.t.newList(list.Pos)
+	specialized.TemplatePath = list.TemplatePath
+	for _, node := range list.Nodes {
+		switch node := node.(type) {
+		case *ActionNode:
+			if node.Set == nil && sp.static(node.Pipe) {
+				if text, ok := sp.render(node); ok {
+					if text != "" {
+						specialized.append(sp.t.newText(node.Pos, text))
+					}
+					continue
+				}
+			}
+			c := *node
+			c.Set = sp.foldSet(node.Set)
+			if node.Pipe != nil {
+				c.Pipe = sp.fold(node.Pipe).(*PipeNode)
+			}
+			specialized.append(&c)
+		case *IfNode:
+			sp.ifNode(specialized, node)
+		case *RangeNode:
+			c := *node
+			c.Set = sp.foldSet(node.Set)
+			c.Expression = sp.fold(node.Expression)
+			c.List, c.ElseList = sp.list(node.List), sp.list(node.ElseList)
+			specialized.append(&c)
+		case *TryNode:
+			c := *node
+			c.List = sp.list(node.List)
+			if node.Catch != nil {
+				catch := *node.Catch
+				catch.List = sp.list(node.Catch.List)
+				c.Catch = &catch
+			}
+			specialized.append(&c)
+		case *ReturnNode:
+			c := *node
+			c.Value = sp.fold(node.Value)
+			specialized.append(&c)
+		case *SwitchNode:
+			sp.switchNode(specialized, node)
+		case *LoopControlNode:
+			c := *node
+			if node.Condition != nil && sp.static(node.Condition) {
+				if v, ok := sp.eval(node.Condition); ok {
+					if !isTrue(v) {
+						continue
+					}
+					c.Condition = nil
+				}
+			}
+			c.Condition = sp.fold(c.Condition)
+			specialized.append(&c)
+		default:
+			specialized.append(node)
+		}
+	}
+	return specialized
+}
+
+func (sp *specializer) ifNode(specialized *ListNode, node *IfNode) {
+	if node.Set == nil && sp.static(node.Expression) {
+		if v, ok := sp.eval(node.Expression); ok {
+			branch := node.ElseList
+			if isTrue(v) {
+				branch = node.List
+			}
+			sp.branch(specialized, node.Pos, node.Line, branch)
+			return
+		}
+	}
+	c := *node
+	c.Set = sp.foldSet(node.Set)
+	c.Expression = sp.fold(node.Expression)
+	c.List, c.ElseList = sp.list(node.List), sp.list(node.ElseList)
+	specialized.append(&c)
+}
+
+func (sp *specializer) switchNode(specialized *ListNode, node *SwitchNode) {
+	if branch, ok := sp.switchBranch(node); ok {
+		sp.branch(specialized, node.Pos, node.Line, branch)
+		return
+	}
+	c := *node
+	c.Expression = sp.fold(node.Expression)
+	c.Cases = make([]*CaseNode, len(node.Cases))
+	for i, caseNode := range node.Cases {
+		specializedCase := *caseNode
+		specializedCase.Values = sp.foldAll(caseNode.Values)
+		specializedCase.List = sp.list(caseNode.List)
+		c.Cases[i] = &specializedCase
+	}
+	c.Default = sp.list(node.Default)
+	specialized.append(&c)
+}
+
+// switchBranch returns the list node executes, when its subject and the values of its cases up to the matching
+// one are static.
+func (sp *specializer) switchBranch(node *SwitchNode) (*ListNode, bool) {
+	var subject reflect.Value
+	if node.Expression != nil {
+		if !sp.static(node.Expression) {
+			return nil, false
+		}
+		var ok bool
+		if subject, ok = sp.eval(node.Expression); !ok {
+			return nil, false
+		}
+	}
+	for _, c := range node.Cases {
+		for _, value := range c.Values {
+			if !sp.static(value) {
+				return nil, false
+			}
+			v, ok := sp.eval(value)
+			if !ok {
+				return nil, false
+			}
+			if node.Expression == nil && isTrue(v) || node.Expression != nil && checkEquality(subject, v) {
+				return c.List, true
+			}
+		}
+	}
+	return node.Default, true
+}
+
+// branch appends the specialized nodes of branch, the list a control statement was found to execute, in place of
+// the statement.
+func (sp *specializer) branch(specialized *ListNode, pos Pos, line int, branch *ListNode) {
+	if branch == nil {
+		return
+	}
+	branch = sp.list(branch)
+	if !declaresVariables(branch) {
+		specialized.Nodes = append(specialized.Nodes, branch.Nodes...)
+		return
+	}
+	// keep the branch in its own scope
+	specialized.append(sp.t.newIf(pos, line, nil, sp.t.newBool(pos, true), branch, nil))
+}
+
+// declaresVariables reports whether list declares variables in its own scope.
+func declaresVariables(list *ListNode) bool {
+	for _, node := range list.Nodes {
+		if action, ok := node.(*ActionNode); ok && action.Set != nil && action.Set.Let {
+			return true
+		}
+	}
+	return false
+}
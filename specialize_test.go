@@ -0,0 +1,93 @@
+package jet
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSpecialize(t *testing.T) {
+	vars := VarMap{}.Set("name", "ab").Set("admin", true).Set("lang", "fr").Set("count", 2)
+	rest := VarMap{}.Set("user", "bob").Set("n", 4).Set("name2", "cd")
+	tests := []struct {
+		template, want string
+	}{
+		{`{{ upper(name) }} {{ .title }}`, `AB {{.title}}`},
+		{`{{ if admin }}A{{ else }}B{{ end }}{{ user }}`, `A{{user}}`},
+		{`{{ if user }}A{{ else if admin }}B{{ end }}`, `{{if user}}A{{else}}B{{end}}`},
+		{`{{ switch lang }}{{ case "en" }}hi{{ case "fr" }}salut{{ end }}`, `salut`},
+		{`{{ count + 1 + n }}`, `{{3 + n}}`},
+		{`{{ "<b>" }}{{ name }}`, `&lt;b&gt;ab`},
+		{`{{ name := "x" }}{{ name }}`, `{{name:="x"}}{{name}}`},
+		{`{{ name2 }}{{ name }}`, `{{name2}}ab`},
+		{`{{ greet(name) }}`, `{{greet("ab")}}`},
+		{`{{ block b() }}{{ name }}{{ end }}`, `{{block b()}}{{name}}{{end}}`},
+	}
+	for _, closures := range []bool{false, true} {
+		for _, tt := range tests {
+			var opts []Option
+			if closures {
+				opts = append(opts, WithClosureCompilation())
+			}
+			set := NewSet(NewInMemLoader(), opts...)
+			set.AddGlobal("greet", func(s string) string { return "hi " + s })
+			tmpl, err := set.parseString(tt.template)
+			if err != nil {
+				t.Fatal(err)
+			}
+			specialized, err := tmpl.Specialize(vars)
+			if err != nil {
+				t.Fatalf("%s: %v", tt.template, err)
+			}
+			if got := specialized.String(); got != tt.want {
+				t.Errorf("%s: got %q, want %q", tt.template, got, tt.want)
+			}
+
+			all := VarMap{}
+			for name, value := range vars {
+				all[name] = value
+			}
+			for name, value := range rest {
+				all[name] = value
+			}
+			// block bodies are kept as is, so the variables they read are still placeholders to pass
+			remaining := VarMap{}
+			for name, value := range rest {
+				remaining[name] = value
+			}
+			for _, p := range specialized.Placeholders() {
+				if value, ok := vars[p.Path]; ok {
+					remaining[p.Path] = value
+				}
+			}
+			var want, got strings.Builder
+			context := map[string]string{"title": "T"}
+			if err := tmpl.Execute(&want, all, context); err != nil {
+				t.Fatalf("%s: %v", tt.template, err)
+			}
+			if err := specialized.Execute(&got, remaining, context); err != nil {
+				t.Fatalf("%s specialized: %v", tt.template, err)
+			}
+			if got.String() != want.String() {
+				t.Errorf("%s: specialized renders %q, want %q", tt.template, got.String(), want.String())
+			}
+		}
+	}
+}
+
+func TestSpecializePlaceholders(t *testing.T) {
+	tmpl, err := NewSet(NewInMemLoader()).parseString(`{{ name }} {{ user.email }}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	specialized, err := tmpl.Specialize(VarMap{}.Set("name", "ab"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	placeholders := specialized.Placeholders()
+	if len(placeholders) != 1 || placeholders[0].Path != "user.email" {
+		t.Errorf("got %+v, want only user.email", placeholders)
+	}
+	if len(tmpl.Placeholders()) != 2 {
+		t.Errorf("Specialize changed the placeholders of the original template: %+v", tmpl.Placeholders())
+	}
+}
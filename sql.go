@@ -0,0 +1,160 @@
+package jet
+
+import (
+	"bytes"
+	"database/sql"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// BindStyle selects the bind placeholder syntax ExecuteSQL writes in place of the value of an action.
+type BindStyle int
+
+const (
+	// BindQuestion writes `?` for every value (MySQL, SQLite).
+	BindQuestion BindStyle = iota
+	// BindDollar writes numbered placeholders `$1`, `$2`, ... (PostgreSQL).
+	BindDollar
+	// BindColon writes named placeholders such as `:user_id`; the name is taken from the last identifier of the
+	// action's expression and made unique within the query. Arguments are returned as sql.NamedArg values.
+	BindColon
+)
+
+// WithBindStyle returns an option function that sets the placeholder syntax used by ExecuteSQL and ParseSQL.
+// The default is BindQuestion.
+func WithBindStyle(style BindStyle) Option {
+	return func(s *Set) {
+		s.bindStyle = style
+	}
+}
+
+// sqlBinder replaces the output of actions with bind placeholders and collects the values as arguments.
+type sqlBinder struct {
+	style BindStyle
+	args  []interface{}
+	names map[string]int
+}
+
+func (b *sqlBinder) len() int {
+	if b == nil {
+		return 0
+	}
+	return len(b.args)
+}
+
+// truncate drops the arguments bound after the first n.
+func (b *sqlBinder) truncate(n int) {
+	if b != nil && n < len(b.args) {
+		b.args = b.args[:n]
+	}
+}
+
+// bind writes the placeholder for v into w and records v as the next argument.
+// Invalid values are bound as SQL NULL.
+func (b *sqlBinder) bind(w io.Writer, node *ActionNode, v reflect.Value) error {
+	var arg interface{}
+	if v.IsValid() && v.CanInterface() {
+		arg = v.Interface()
+	}
+
+	var placeholder string
+	switch b.style {
+	case BindDollar:
+		placeholder = "$" + strconv.Itoa(len(b.args)+1)
+	case BindColon:
+		name := b.uniqueName(bindName(node))
+		placeholder = ":" + name
+		arg = sql.Named(name, arg)
+	default:
+		placeholder = "?"
+	}
+
+	b.args = append(b.args, arg)
+	_, err := io.WriteString(w, placeholder)
+	return err
+}
+
+func (b *sqlBinder) uniqueName(name string) string {
+	if b.names == nil {
+		b.names = make(map[string]int)
+	}
+	b.names[name]++
+	if n := b.names[name]; n > 1 {
+		return name + "_" + strconv.Itoa(n)
+	}
+	return name
+}
+
+// bindName derives a placeholder name from the base expression of the action's pipeline.
+func bindName(node *ActionNode) string {
+	name := ""
+	if len(node.Pipe.Cmds) > 0 {
+		switch expr := node.Pipe.Cmds[0].BaseExpr.(type) {
+		case *IdentifierNode:
+			name = expr.Ident
+		case *FieldNode:
+			name = expr.Idents[len(expr.Idents)-1].name
+		case *ChainNode:
+			name = expr.Field[len(expr.Field)-1].name
+		}
+	}
+	name = strings.Map(func(r rune) rune {
+		if r == '_' || ('a' <= r && r <= 'z') || ('A' <= r && r <= 'Z') || ('0' <= r && r <= '9') {
+			return r
+		}
+		return -1
+	}, name)
+	if name == "" || ('0' <= name[0] && name[0] <= '9') {
+		name = "p" + name
+	}
+	return name
+}
+
+// ExecuteSQL executes the template like Execute, but every value an action would print is replaced by a bind
+// placeholder in the style configured with WithBindStyle, and the value is returned in args, in order.
+// Control flow (if, range, include, yield, ...) works as in Execute, and text between actions is copied as-is.
+// Values written by safe writers such as raw are not bound; use them for trusted fragments like identifiers.
+func (t *Template) ExecuteSQL(variables VarMap, data interface{}) (query string, args []interface{}, err error) {
+	return t.executeSQL(variables, data, nil)
+}
+
+// executeSQL runs ExecuteSQL; setup, if not nil, is called on the Runtime like in execute.
+func (t *Template) executeSQL(variables VarMap, data interface{}, setup func(*Runtime)) (query string, args []interface{}, err error) {
+	var buf bytes.Buffer
+	binder := &sqlBinder{style: t.set.bindStyle}
+	err = t.execute(&buf, variables, data, func(rt *Runtime) {
+		rt.binder = binder
+		if setup != nil {
+			setup(rt)
+		}
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	return buf.String(), binder.args, nil
+}
+
+// ParseMapSQL is the ExecuteSQL counterpart of ParseMap.
+func (t *Template) ParseMapSQL(data any, asMap ...bool) (query string, args []interface{}, err error) {
+	variables, context, err := splitData(data, t.set.dataJSONTags(), asMap...)
+	if err != nil {
+		return "", nil, err
+	}
+	return t.executeSQL(variables, context, t.set.dataSetup())
+}
+
+// ParseSQL parses template with the Set and renders it with ExecuteSQL.
+func (s *Set) ParseSQL(template string, data any, asMap ...bool) (query string, args []interface{}, err error) {
+	tmpl, err := s.parseString(template)
+	if err != nil {
+		return "", nil, err
+	}
+	return tmpl.ParseMapSQL(data, asMap...)
+}
+
+// ParseSQL parses template with the default Set and renders it with ExecuteSQL.
+func ParseSQL(template string, data any, asMap ...bool) (query string, args []interface{}, err error) {
+	return defaultSet.ParseSQL(template, data, asMap...)
+}
@@ -0,0 +1,78 @@
+package jet
+
+import (
+	"database/sql"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestExecuteSQL(t *testing.T) {
+	const query = `SELECT * FROM users WHERE id = {{ id }}{{ if name }} AND name = {{ name }}{{ end }}` +
+		`{{ range ids }} OR id = {{ . }}{{ end }} AND deleted = {{ nothing }} ORDER BY {{ raw: order }}`
+	vars := VarMap{}.Set("id", 1).Set("name", "ann").Set("ids", []int{2, 3}).Set("nothing", nil).Set("order", "name")
+	tests := []struct {
+		style BindStyle
+		query string
+		args  []interface{}
+	}{
+		{
+			BindQuestion,
+			"SELECT * FROM users WHERE id = ? AND name = ? OR id = ? OR id = ? AND deleted = ? ORDER BY name",
+			[]interface{}{1, "ann", 2, 3, nil},
+		},
+		{
+			BindDollar,
+			"SELECT * FROM users WHERE id = $1 AND name = $2 OR id = $3 OR id = $4 AND deleted = $5 ORDER BY name",
+			[]interface{}{1, "ann", 2, 3, nil},
+		},
+		{
+			BindColon,
+			"SELECT * FROM users WHERE id = :id AND name = :name OR id = :p OR id = :p_2 AND deleted = :nothing ORDER BY name",
+			[]interface{}{sql.Named("id", 1), sql.Named("name", "ann"), sql.Named("p", 2), sql.Named("p_2", 3), sql.Named("nothing", nil)},
+		},
+	}
+	for _, tt := range tests {
+		tmpl, err := NewSet(NewInMemLoader(), WithBindStyle(tt.style)).parseString(query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, args, err := tmpl.ExecuteSQL(vars, nil)
+		if err != nil {
+			t.Fatalf("style %d: %v", tt.style, err)
+		}
+		if got != tt.query {
+			t.Errorf("style %d: query = %q, want %q", tt.style, got, tt.query)
+		}
+		if !reflect.DeepEqual(args, tt.args) {
+			t.Errorf("style %d: args = %#v, want %#v", tt.style, args, tt.args)
+		}
+	}
+}
+
+func TestExecuteSQLDropsArgumentsOfFailedTry(t *testing.T) {
+	s := NewSet(NewInMemLoader())
+	s.AddGlobal("fail", func() int { panic(errors.New("failed")) })
+	tmpl, err := s.parseString(`{{ a }}{{ try }}{{ b }}{{ fail() }}{{ catch }}{{ c }}{{ end }}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	query, args, err := tmpl.ExecuteSQL(VarMap{}.Set("a", 1).Set("b", 2).Set("c", 3), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the output of the failed try is dropped, and so are its arguments
+	if query != "??" || !reflect.DeepEqual(args, []interface{}{1, 3}) {
+		t.Errorf("got %q, %v, want %q, %v", query, args, "??", []interface{}{1, 3})
+	}
+}
+
+func TestParseSQLWithMap(t *testing.T) {
+	query, args, err := ParseSQL(`DELETE FROM t WHERE id = {{ id }}`, map[string]any{"id": 7})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if query != "DELETE FROM t WHERE id = ?" || !reflect.DeepEqual(args, []interface{}{7}) {
+		t.Errorf("got %q, %v", query, args)
+	}
+}
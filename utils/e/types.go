@@ -5,6 +5,7 @@ type Reason = string
 const (
 	TemplateErrorReason Reason = "jet.template.error"
 	RuntimeErrorReason  Reason = "jet.runtime.error"
+	CanceledReason      Reason = "jet.runtime.canceled"
 
 	InvalidValueReason             Reason = "invalid.value"
 	InvalidIndexReason             Reason = "invalid.index"
@@ -20,6 +21,11 @@ const (
 	UnexpectedClauseReason         Reason = "unexpected.clause"
 
 	NotFoundFieldOrMethodReason Reason = "not_found.field_or_method"
+
+	LimitOutputReason     Reason = "limit.output"
+	LimitIterationsReason Reason = "limit.iterations"
+	LimitDepthReason      Reason = "limit.depth"
+	LimitNodesReason      Reason = "limit.nodes"
 )
 
 type (
@@ -46,6 +46,12 @@ func (vc VisitorContext) Visit(node jet.Node) {
 		vc.visitCommandNode(node)
 	case *jet.IfNode:
 		vc.visitIfNode(node)
+	case *jet.SwitchNode:
+		vc.visitSwitchNode(node)
+	case *jet.CaseNode:
+		vc.visitCaseNode(node)
+	case *jet.LoopControlNode:
+		vc.visitLoopControlNode(node)
 	case *jet.PipeNode:
 		vc.visitPipeNode(node)
 	case *jet.RangeNode:
@@ -68,6 +74,8 @@ func (vc VisitorContext) Visit(node jet.Node) {
 		vc.visitNumericComparativeExprNode(node)
 	case *jet.LogicalExprNode:
 		vc.visitLogicalExprNode(node)
+	case *jet.CoalesceExprNode:
+		vc.visitCoalesceExprNode(node)
 	case *jet.CallExprNode:
 		vc.visitCallExprNode(node)
 	case *jet.NotExprNode:
@@ -78,6 +86,8 @@ func (vc VisitorContext) Visit(node jet.Node) {
 		vc.visitIndexExprNode(node)
 	case *jet.SliceExprNode:
 		vc.visitSliceExprNode(node)
+	case *jet.TransNode:
+		vc.visitTransNode(node)
 	case *jet.TextNode:
 	case *jet.IdentifierNode:
 	case *jet.StringNode:
@@ -127,6 +137,31 @@ func (vc VisitorContext) visitIfNode(ifNode *jet.IfNode) {
 	vc.visitBranchNode(&ifNode.BranchNode)
 }
 
+func (vc VisitorContext) visitSwitchNode(switchNode *jet.SwitchNode) {
+	if switchNode.Expression != nil {
+		vc.visitNode(switchNode.Expression)
+	}
+	for _, node := range switchNode.Cases {
+		vc.visitNode(node)
+	}
+	if switchNode.Default != nil {
+		vc.visitNode(switchNode.Default)
+	}
+}
+
+func (vc VisitorContext) visitCaseNode(caseNode *jet.CaseNode) {
+	for _, node := range caseNode.Values {
+		vc.visitNode(node)
+	}
+	vc.visitNode(caseNode.List)
+}
+
+func (vc VisitorContext) visitLoopControlNode(loopControlNode *jet.LoopControlNode) {
+	if loopControlNode.Condition != nil {
+		vc.visitNode(loopControlNode.Condition)
+	}
+}
+
 func (vc VisitorContext) visitBranchNode(branchNode *jet.BranchNode) {
 	if branchNode.Set != nil {
 		vc.visitNode(branchNode.Set)
@@ -190,6 +225,11 @@ func (vc VisitorContext) visitLogicalExprNode(logicalExprNode *jet.LogicalExprNo
 	vc.visitNode(logicalExprNode.Right)
 }
 
+func (vc VisitorContext) visitCoalesceExprNode(coalesceExprNode *jet.CoalesceExprNode) {
+	vc.visitNode(coalesceExprNode.Left)
+	vc.visitNode(coalesceExprNode.Right)
+}
+
 func (vc VisitorContext) visitCallExprNode(callExprNode *jet.CallExprNode) {
 	vc.visitNode(callExprNode.BaseExpr)
 	for _, node := range callExprNode.Exprs {
@@ -218,6 +258,21 @@ func (vc VisitorContext) visitSliceExprNode(sliceExprNode *jet.SliceExprNode) {
 	vc.visitNode(sliceExprNode.EndIndex)
 }
 
+func (vc VisitorContext) visitTransNode(transNode *jet.TransNode) {
+	if transNode.Key != nil {
+		vc.visitNode(transNode.Key)
+	}
+	for _, node := range transNode.Args {
+		vc.visitNode(node)
+	}
+	for _, arg := range transNode.Named {
+		vc.visitNode(arg.Value)
+	}
+	if transNode.List != nil {
+		vc.visitNode(transNode.List)
+	}
+}
+
 func (vc VisitorContext) visitCommandNode(commandNode *jet.CommandNode) {
 	vc.visitNode(commandNode.BaseExpr)
 	for _, node := range commandNode.Exprs {
@@ -0,0 +1,135 @@
+package jet
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// VersionedLoader is implemented by loaders that can tell which version of a template they hold without reading it.
+// Sets configured with WithRevalidation use it to detect cached templates that went stale.
+type VersionedLoader interface {
+	Loader
+
+	// Stat returns an opaque version of the template, which changes whenever its contents change, and its
+	// modification time. An empty version means the version is unknown and the template is considered unchanged.
+	Stat(templatePath string) (version string, modTime time.Time, err error)
+}
+
+// compile time checks that we implement VersionedLoader
+var (
+	_ VersionedLoader = (*OSFileSystemLoader)(nil)
+	_ VersionedLoader = (*InMemLoader)(nil)
+	_ VersionedLoader = (*FSLoader)(nil)
+	_ VersionedLoader = (*MultiLoader)(nil)
+	_ VersionedLoader = (*PrefixLoader)(nil)
+)
+
+// WithRevalidation returns an option function that makes the Set check, each time a template is taken from the
+// cache, that the template and the templates it extends or imports are still at the version they were parsed from.
+// Stale templates are invalidated (see Set.Invalidate) and parsed again. It requires a Loader implementing
+// VersionedLoader and costs a Stat call per template in the extends/import chain on every access.
+func WithRevalidation() Option {
+	return func(s *Set) {
+		s.revalidate = true
+	}
+}
+
+// Version returns the version of the template's source as reported by a VersionedLoader when the template was loaded,
+// or "" if unknown.
+func (t *Template) Version() string {
+	return t.version
+}
+
+// fresh reports whether t and the templates it extends or imports are at the version the loader holds.
+func (s *Set) fresh(t *Template) bool {
+	loader, ok := s.loader.(VersionedLoader)
+	if !ok || t.Name == "" {
+		return true
+	}
+	version, _, err := loader.Stat(t.Name)
+	if err != nil || version != t.version {
+		return false
+	}
+	if t.extends != nil && !s.fresh(t.extends) {
+		return false
+	}
+	for _, _import := range t.imports {
+		if !s.fresh(_import) {
+			return false
+		}
+	}
+	return true
+}
+
+// fileVersion derives a version from the modification time and size of a file.
+func fileVersion(stat fs.FileInfo) string {
+	return strconv.FormatInt(stat.ModTime().UnixNano(), 36) + "-" + strconv.FormatInt(stat.Size(), 36)
+}
+
+// Stat returns a version derived from the file's modification time and size.
+func (l *OSFileSystemLoader) Stat(templatePath string) (version string, modTime time.Time, err error) {
+	stat, err := os.Stat(filepath.Join(l.dir, filepath.FromSlash(templatePath)))
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return fileVersion(stat), stat.ModTime(), nil
+}
+
+// Stat returns a version derived from the file's modification time and size.
+func (l *FSLoader) Stat(templatePath string) (version string, modTime time.Time, err error) {
+	stat, err := fs.Stat(l.fsys, l.name(templatePath))
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return fileVersion(stat), stat.ModTime(), nil
+}
+
+// Stat returns the version the template got when it was last added with Set, and the time it was added.
+func (l *InMemLoader) Stat(templatePath string) (version string, modTime time.Time, err error) {
+	templatePath = l.normalize(templatePath)
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+	f, ok := l.files[templatePath]
+	if !ok {
+		return "", time.Time{}, fmt.Errorf("%s does not exist", templatePath)
+	}
+	return strconv.FormatUint(f.version, 36), f.modTime, nil
+}
+
+// Stat returns the version reported by the first loader having the template, prefixed by the loader's position so
+// that a template appearing in an earlier loader changes the version. Loaders not implementing VersionedLoader
+// report an empty version.
+func (l *MultiLoader) Stat(templatePath string) (version string, modTime time.Time, err error) {
+	for i, loader := range l.loaders {
+		if !loader.Exists(templatePath) {
+			continue
+		}
+		versioned, ok := loader.(VersionedLoader)
+		if !ok {
+			return "", time.Time{}, nil
+		}
+		version, modTime, err = versioned.Stat(templatePath)
+		if err != nil || version == "" {
+			return version, modTime, err
+		}
+		return strconv.Itoa(i) + ":" + version, modTime, nil
+	}
+	return "", time.Time{}, fmt.Errorf("%s does not exist", normalizePath(templatePath))
+}
+
+// Stat returns the version reported by the loader mounted for the path, or an empty version if that loader doesn't
+// implement VersionedLoader.
+func (l *PrefixLoader) Stat(templatePath string) (version string, modTime time.Time, err error) {
+	loader, rest := l.find(templatePath)
+	if loader == nil {
+		return "", time.Time{}, fmt.Errorf("%s does not exist", normalizePath(templatePath))
+	}
+	if versioned, ok := loader.(VersionedLoader); ok {
+		return versioned.Stat(rest)
+	}
+	return "", time.Time{}, nil
+}
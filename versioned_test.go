@@ -0,0 +1,43 @@
+package jet
+
+import "testing"
+
+func TestRevalidation(t *testing.T) {
+	for _, revalidate := range []bool{false, true} {
+		loader := NewInMemLoader()
+		loader.Set("/layout.jet", `[{{ yield body() }}]`)
+		loader.Set("/page.jet", `{{ extends "layout.jet" }}{{ block body() }}page{{ end }}`)
+		var opts []Option
+		if revalidate {
+			opts = append(opts, WithRevalidation())
+		}
+		s := NewSet(loader, opts...)
+		if got := renderTemplate(t, s, "/page.jet"); got != "[page]" {
+			t.Fatalf("got %q, want %q", got, "[page]")
+		}
+		page, err := s.GetTemplate("/page.jet")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if page.Version() == "" {
+			t.Error("a template loaded from an InMemLoader has no version")
+		}
+
+		tests := []struct {
+			name, text, want, cached string
+		}{
+			{"/page.jet", `{{ extends "layout.jet" }}{{ block body() }}new page{{ end }}`, "[new page]", "[page]"},
+			{"/layout.jet", `<{{ yield body() }}>`, "<new page>", "[page]"},
+		}
+		for _, tt := range tests {
+			loader.Set(tt.name, tt.text)
+			want := tt.cached
+			if revalidate {
+				want = tt.want
+			}
+			if got := renderTemplate(t, s, "/page.jet"); got != want {
+				t.Errorf("revalidate %v, after changing %s: got %q, want %q", revalidate, tt.name, got, want)
+			}
+		}
+	}
+}